@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"tenant-terraform-generator/duplosdk"
+	"tenant-terraform-generator/tf-generator/common"
+	"time"
+)
+
+// Status of a tenant exported by a batch run.
+const (
+	tenantExported = "exported"
+	// tenantPartial is the status of a tenant written without some objects, with --keep-going.
+	tenantPartial = "partial"
+	tenantFailed  = "failed"
+	// tenantSkipped is the status of the tenants left when the run was canceled.
+	tenantSkipped = "skipped"
+)
+
+// tenantResult is the outcome of the export of a tenant by a batch run.
+type tenantResult struct {
+	tenant   string
+	status   string
+	duration time.Duration
+	err      error
+}
+
+// batchFailedError is returned when some tenants of a batch run could not be exported.
+type batchFailedError struct {
+	failed int
+	total  int
+	// partial is set when the projects of some tenants were written.
+	partial bool
+}
+
+func (e *batchFailedError) Error() string {
+	return fmt.Sprintf("%d of %d tenant(s) could not be exported", e.failed, e.total)
+}
+
+// isBatch reports whether a --tenant value selects several tenants: a comma separated list or glob patterns.
+func isBatch(tenant string) bool {
+	return strings.ContainsAny(tenant, ",*?[")
+}
+
+// tenantPatterns splits a --tenant value into its names and glob patterns.
+func tenantPatterns(tenant string) []string {
+	patterns := []string{}
+	for _, p := range strings.Split(tenant, ",") {
+		if p = strings.TrimSpace(p); len(p) > 0 {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// selectTenants returns the names of the tenants matching one of the patterns, sorted, and the patterns which match
+// no tenant. Names are matched case insensitively, like render matches the tenant of a snapshot.
+func selectTenants(tenants []duplosdk.DuploTenant, patterns []string) ([]string, []string, error) {
+	selected := map[string]bool{}
+	unmatched := []string{}
+	for _, p := range patterns {
+		found := false
+		for _, t := range tenants {
+			ok, err := path.Match(strings.ToLower(p), strings.ToLower(t.AccountName))
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid tenant pattern %q: %s", p, err)
+			}
+			if ok {
+				selected[t.AccountName] = true
+				found = true
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, p)
+		}
+	}
+	names := make([]string, 0, len(selected))
+	for name := range selected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, unmatched, nil
+}
+
+// generateTenants exports the tenants of the plan planID (every plan when empty) matching the patterns, one after
+// the other with the same client. newConfig returns the configuration of the export of a tenant.
+// A tenant which fails is reported in its result and does not stop the others.
+func generateTenants(ctx context.Context, client *duplosdk.Client, patterns []string, planID string, newConfig func(tenantName string) *common.Config) ([]*tenantResult, error) {
+	tenants, clientErr := client.WithContext(ctx).ListTenantsForUserByPlan(planID)
+	if clientErr != nil {
+		return nil, fmt.Errorf("error listing tenants from duplo: %s", clientErr)
+	}
+	names, unmatched, err := selectTenants(*tenants, patterns)
+	if err != nil {
+		return nil, err
+	}
+	results := []*tenantResult{}
+	for _, p := range unmatched {
+		results = append(results, &tenantResult{tenant: p, status: tenantFailed, err: errors.New("no tenant matches")})
+	}
+	for i, name := range names {
+		if ctx.Err() != nil {
+			results = append(results, &tenantResult{tenant: name, status: tenantSkipped, err: ctx.Err()})
+			continue
+		}
+		log.Printf("[TRACE] <====== Export of tenant %s (%d/%d) started. =====>", name, i+1, len(names))
+		start := time.Now()
+		err := generate(ctx, newConfig(name), client)
+		result := &tenantResult{tenant: name, status: tenantExported, duration: time.Since(start), err: err}
+		var gerr *generationFailedError
+		if errors.As(err, &gerr) && gerr.partial {
+			result.status = tenantPartial
+		} else if err != nil {
+			result.status = tenantFailed
+		}
+		log.Printf("[TRACE] <====== Export of tenant %s done: %s. =====>", name, result.status)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// batchError returns the error of a batch run with the given results, nil when every tenant was exported.
+func batchError(results []*tenantResult) error {
+	failed, written := 0, false
+	for _, r := range results {
+		if r.status == tenantExported || r.status == tenantPartial {
+			written = true
+		}
+		if r.status != tenantExported {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return &batchFailedError{failed: failed, total: len(results), partial: written}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const binaryName = "tenant-terraform-generator"
+
+// Exit codes returned by the CLI.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+	// exitPartial is returned with --keep-going when the projects were written but some objects could not be exported,
+	// and by batch runs which exported some of the tenants.
+	exitPartial = 3
+	// exitChanges is returned by diff --check when the compared directories differ.
+	exitChanges = 4
+	// exitInterrupted is returned when the run was canceled with Ctrl-C or SIGTERM, like shells do for SIGINT.
+	exitInterrupted = 130
+)
+
+// command is a single sub command of the CLI.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// usageError is returned for invalid command line input, it makes the CLI print a hint on how to get help.
+type usageError struct {
+	command string
+	err     error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func newUsageError(command string, format string, a ...interface{}) error {
+	return &usageError{command: command, err: fmt.Errorf(format, a...)}
+}
+
+// changesFoundError is returned by diff --check when there are changes.
+type changesFoundError struct {
+	count int
+}
+
+func (e *changesFoundError) Error() string {
+	return fmt.Sprintf("%d change(s) found", e.count)
+}
+
+func commands() []*command {
+	return []*command{
+		{name: "generate", summary: "Generate terraform projects for a DuploCloud tenant.", run: runGenerate},
+		{name: "import", summary: "Generate terraform projects and import the existing resources into terraform state.", run: runImport},
+		{name: "snapshot", summary: "Read the objects of a DuploCloud tenant into a JSON snapshot file.", run: runSnapshot},
+		{name: "render", summary: "Generate terraform projects from a snapshot file, without contacting DuploCloud.", run: runRender},
+		{name: "clone", summary: "Generate terraform projects for a new tenant from the objects of an existing tenant and a mapping file.", run: runClone},
+		{name: "list", summary: "List the tenants visible with the given DuploCloud credentials.", run: runList},
+		{name: "list-generators", summary: "List the registered terraform generators with the resources they produce.", run: runListGenerators},
+		{name: "diff", summary: "Compare the resources, modules, locals, variables and outputs of two tenant exports.", run: runDiff},
+		{name: "version", summary: "Print the version of this utility.", run: runVersion},
+	}
+}
+
+func findCommand(name string) *command {
+	for _, c := range commands() {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// runCLI runs the command named by the first argument and returns the process exit code.
+// Commands stop once ctx is done.
+func runCLI(ctx context.Context, args []string, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return exitUsage
+	}
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" || name == "-help" {
+		if len(args) > 1 && findCommand(args[1]) != nil {
+			return runCLI(ctx, []string{args[1], "--help"}, stderr)
+		}
+		printUsage(stderr)
+		return exitOK
+	}
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(stderr, "Error - Unknown command %q.\n\n", name)
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	err := cmd.run(ctx, args[1:])
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	var cerr *changesFoundError
+	if errors.As(err, &cerr) {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return exitChanges
+	}
+	fmt.Fprintf(stderr, "Error - %s\n", err)
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return exitInterrupted
+	}
+	var berr *batchFailedError
+	if errors.As(err, &berr) {
+		if berr.partial {
+			return exitPartial
+		}
+		return exitError
+	}
+	var gerr *generationFailedError
+	if errors.As(err, &gerr) {
+		printFailureReport(stderr, "Failed objects", gerr.failures)
+		if gerr.partial {
+			return exitPartial
+		}
+		return exitError
+	}
+	var uerr *usageError
+	if errors.As(err, &uerr) {
+		fmt.Fprintf(stderr, "Run '%s %s --help' for usage.\n", binaryName, uerr.command)
+		return exitUsage
+	}
+	return exitError
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", binaryName)
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %-16s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s <command> --help' for the flags of a command.\n", binaryName)
+	fmt.Fprintf(w, "Every flag falls back to the environment variable shown in its help text.\n")
+}
+
+// newFlagSet creates a flag set for a command which prints the command summary along with its flags.
+func newFlagSet(name string, positional string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s %s [flags] %s\n\n", binaryName, name, positional)
+		if cmd := findCommand(name); cmd != nil {
+			fmt.Fprintf(stderr, "%s\n\n", cmd.summary)
+		}
+		fmt.Fprintf(stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the flags of a command, turning parse failures into usage errors.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return &usageError{command: fs.Name(), err: err}
+	}
+	return err
+}
+
+// envFlags registers flags whose default values are read from environment variables.
+// Malformed environment values are remembered and only reported when the flag was not given explicitly.
+type envFlags struct {
+	fs      *flag.FlagSet
+	fromEnv map[string]bool
+	envErrs map[string]error
+}
+
+func newEnvFlags(fs *flag.FlagSet) *envFlags {
+	return &envFlags{fs: fs, fromEnv: map[string]bool{}, envErrs: map[string]error{}}
+}
+
+func (ef *envFlags) String(p *string, name, env, def, usage string) {
+	if val := os.Getenv(env); len(val) > 0 {
+		def = val
+		ef.fromEnv[name] = true
+	}
+	ef.fs.StringVar(p, name, def, fmt.Sprintf("%s (env: %s)", usage, env))
+}
+
+func (ef *envFlags) Bool(p *bool, name, env string, def bool, usage string) {
+	if val := os.Getenv(env); len(val) > 0 {
+		ef.fromEnv[name] = true
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			ef.envErrs[name] = fmt.Errorf("invalid value %q for env variable \"%s\": %s", val, env, err)
+		} else {
+			def = b
+		}
+	}
+	ef.fs.BoolVar(p, name, def, fmt.Sprintf("%s (env: %s)", usage, env))
+}
+
+func (ef *envFlags) Int(p *int, name, env string, def int, usage string) {
+	if val := os.Getenv(env); len(val) > 0 {
+		ef.fromEnv[name] = true
+		i, err := strconv.Atoi(val)
+		if err != nil {
+			ef.envErrs[name] = fmt.Errorf("invalid value %q for env variable \"%s\": %s", val, env, err)
+		} else {
+			def = i
+		}
+	}
+	ef.fs.IntVar(p, name, def, fmt.Sprintf("%s (env: %s)", usage, env))
+}
+
+func (ef *envFlags) Duration(p *time.Duration, name, env string, def time.Duration, usage string) {
+	if val := os.Getenv(env); len(val) > 0 {
+		ef.fromEnv[name] = true
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			ef.envErrs[name] = fmt.Errorf("invalid value %q for env variable \"%s\": %s", val, env, err)
+		} else {
+			def = d
+		}
+	}
+	ef.fs.DurationVar(p, name, def, fmt.Sprintf("%s (env: %s)", usage, env))
+}
+
+// isSet reports whether a flag was given on the command line or through its env variable.
+func (ef *envFlags) isSet(name string) bool {
+	if ef.fromEnv[name] {
+		return true
+	}
+	set := false
+	ef.fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// fallback sets a flag to a value, typically from a profile, unless the flag was given on the command line or through its env variable.
+func (ef *envFlags) fallback(name, value string) error {
+	if len(value) == 0 || ef.isSet(name) {
+		return nil
+	}
+	return ef.fs.Set(name, value)
+}
+
+// validate reports malformed environment values for the flags that were not set on the command line.
+func (ef *envFlags) validate() error {
+	set := map[string]bool{}
+	ef.fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	names := make([]string, 0, len(ef.envErrs))
+	for name := range ef.envErrs {
+		if !set[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, ef.envErrs[name].Error())
+	}
+	return &usageError{command: ef.fs.Name(), err: errors.New(strings.Join(msgs, "; "))}
+}
+
+// requireFlags returns a usage error listing the required flags that have no value.
+func requireFlags(command string, values map[string]string) error {
+	missing := []string{}
+	for name, val := range values {
+		if len(val) == 0 {
+			missing = append(missing, "--"+name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return newUsageError(command, "missing required flag(s) %s, provide them on the command line or through the env variables listed in --help.", strings.Join(missing, ", "))
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// cliEnv lists the env variables read by the tests of the generate flags, they are cleared before each case.
+var cliEnv = []string{"duplo_host", "duplo_token", "ssl_no_verify", "duplo_tf_config", "duplo_profile", "tenant_name",
+	"customer_name", "cert_arn", "parallelism", "duplo_tf_timeout", "keep_going", "duplo_provider_version"}
+
+// TestParseGenerateOptions checks a flag takes precedence over its env variable, which takes precedence over the profile.
+func TestParseGenerateOptions(t *testing.T) {
+	const profile = `default_profile: prod
+profiles:
+  prod:
+    host: https://profile.duplocloud.net
+    token:
+      value: profile-token
+    customer: profile-customer
+    cert_arn: profile-cert
+    provider_version: 0.9.0
+`
+	required := []string{"--tenant", "test", "--customer", "duplo-masp", "--cert-arn", "cert"}
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		profile string
+		// want holds the expected values of the options by flag name.
+		want map[string]string
+		// wantErr is part of the expected error, empty when the options are valid.
+		wantErr string
+	}{
+		{
+			name: "flags",
+			args: append([]string{"--host", "https://flag.duplocloud.net", "--token", "flag-token", "--parallelism", "3"}, required...),
+			want: map[string]string{"host": "https://flag.duplocloud.net", "token": "flag-token", "parallelism": "3", "customer": "duplo-masp"},
+		},
+		{
+			name: "env",
+			args: required,
+			env:  map[string]string{"duplo_host": "https://env.duplocloud.net", "duplo_token": "env-token", "parallelism": "5", "keep_going": "true"},
+			want: map[string]string{"host": "https://env.duplocloud.net", "token": "env-token", "parallelism": "5", "keep-going": "true"},
+		},
+		{
+			name: "flag over env",
+			args: append([]string{"--host", "https://flag.duplocloud.net", "--parallelism", "3", "--keep-going=false"}, required...),
+			env:  map[string]string{"duplo_host": "https://env.duplocloud.net", "duplo_token": "env-token", "parallelism": "5", "keep_going": "true"},
+			want: map[string]string{"host": "https://flag.duplocloud.net", "token": "env-token", "parallelism": "3", "keep-going": "false"},
+		},
+		{
+			name:    "profile",
+			args:    []string{"--tenant", "test"},
+			profile: profile,
+			want: map[string]string{"host": "https://profile.duplocloud.net", "token": "profile-token", "customer": "profile-customer",
+				"cert-arn": "profile-cert", "provider-version": "0.9.0"},
+		},
+		{
+			name:    "env over profile",
+			args:    []string{"--tenant", "test"},
+			env:     map[string]string{"duplo_host": "https://env.duplocloud.net", "customer_name": "env-customer"},
+			profile: profile,
+			want:    map[string]string{"host": "https://env.duplocloud.net", "token": "profile-token", "customer": "env-customer"},
+		},
+		{
+			name:    "flag over env and profile",
+			args:    []string{"--tenant", "test", "--host", "https://flag.duplocloud.net", "--token", "flag-token"},
+			env:     map[string]string{"duplo_host": "https://env.duplocloud.net"},
+			profile: profile,
+			want:    map[string]string{"host": "https://flag.duplocloud.net", "token": "flag-token", "customer": "profile-customer"},
+		},
+		{
+			name:    "malformed env",
+			args:    append([]string{"--host", "https://flag.duplocloud.net", "--token", "flag-token"}, required...),
+			env:     map[string]string{"parallelism": "many", "ssl_no_verify": "maybe"},
+			wantErr: `invalid value "many" for env variable "parallelism"`,
+		},
+		{
+			name: "malformed env overridden by its flag",
+			args: append([]string{"--host", "https://flag.duplocloud.net", "--token", "flag-token", "--parallelism", "2", "--ssl-no-verify"}, required...),
+			env:  map[string]string{"parallelism": "many", "ssl_no_verify": "maybe"},
+			want: map[string]string{"parallelism": "2", "ssl-no-verify": "true"},
+		},
+		{
+			name:    "missing required flags",
+			args:    []string{"--tenant", "test"},
+			wantErr: "missing required flag(s) --host, --token",
+		},
+		{
+			name:    "invalid parallelism",
+			args:    append([]string{"--host", "https://flag.duplocloud.net", "--token", "flag-token", "--parallelism", "0"}, required...),
+			wantErr: "--parallelism must be at least 1",
+		},
+		{
+			name:    "unknown profile",
+			args:    append([]string{"--profile", "qa"}, required...),
+			profile: profile,
+			wantErr: `profile "qa" not found, available profiles: prod`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCLIEnv(t, tt.env)
+			if len(tt.profile) > 0 {
+				if err := os.WriteFile(filepath.Join(os.Getenv("HOME"), ".tenant-terraform-generator.yaml"), []byte(tt.profile), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			opts, err := parseGenerateOptions("generate", tt.args, false, io.Discard)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parse returned %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]string{
+				"host":             opts.duplo.host,
+				"token":            opts.duplo.token,
+				"ssl-no-verify":    strconv.FormatBool(opts.duplo.sslNoVerify),
+				"parallelism":      strconv.Itoa(opts.parallelism),
+				"keep-going":       strconv.FormatBool(opts.keepGoing),
+				"customer":         opts.customerName,
+				"cert-arn":         opts.certArn,
+				"provider-version": opts.duploProviderVersion,
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("--%s is %q, want %q", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+// TestParseCloneOptions checks the certificate of the mapping is the default of --cert-arn, over the one of the profile.
+func TestParseCloneOptions(t *testing.T) {
+	const profile = `default_profile: prod
+profiles:
+  prod:
+    host: https://profile.duplocloud.net
+    token:
+      value: profile-token
+    cert_arn: profile-cert
+`
+	required := []string{"--host", "https://flag.duplocloud.net", "--token", "flag-token", "--customer", "duplo-masp", "--from", "dev", "--to", "prod"}
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		profile string
+		// mapping is the certificate of the mapping file, which has none when empty.
+		mapping string
+		want    string
+	}{
+		{name: "flag", args: append([]string{"--cert-arn", "flag-cert"}, required...), want: "flag-cert"},
+		{name: "mapping", args: required, mapping: "mapping-cert", want: "mapping-cert"},
+		{name: "flag over mapping", args: append([]string{"--cert-arn", "flag-cert"}, required...), mapping: "mapping-cert", want: "flag-cert"},
+		{name: "env over mapping", args: required, env: map[string]string{"cert_arn": "env-cert"}, mapping: "mapping-cert", want: "env-cert"},
+		{name: "mapping over profile", args: []string{"--from", "dev", "--to", "prod", "--customer", "duplo-masp"}, profile: profile,
+			mapping: "mapping-cert", want: "mapping-cert"},
+		{name: "profile", args: []string{"--from", "dev", "--to", "prod", "--customer", "duplo-masp"}, profile: profile, want: "profile-cert"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCLIEnv(t, tt.env)
+			if len(tt.profile) > 0 {
+				if err := os.WriteFile(filepath.Join(os.Getenv("HOME"), ".tenant-terraform-generator.yaml"), []byte(tt.profile), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			mapping := "plan: prod\n"
+			if len(tt.mapping) > 0 {
+				mapping += "certificate: " + tt.mapping + "\n"
+			}
+			mappingFile := filepath.Join(t.TempDir(), "clone.yaml")
+			if err := os.WriteFile(mappingFile, []byte(mapping), 0600); err != nil {
+				t.Fatal(err)
+			}
+			opts, err := parseCloneOptions(append([]string{"--mapping", mappingFile}, tt.args...), io.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts.certArn != tt.want {
+				t.Errorf("--cert-arn is %q, want %q", opts.certArn, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunCLIExitCodes checks the exit codes of the command line errors which do not reach DuploCloud.
+func TestRunCLIExitCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "no command", args: []string{}, want: exitUsage},
+		{name: "unknown command", args: []string{"export"}, want: exitUsage},
+		{name: "help", args: []string{"help", "generate"}, want: exitOK},
+		{name: "unknown flag", args: []string{"generate", "--tenants", "test"}, want: exitUsage},
+		{name: "missing flags", args: []string{"generate", "--tenant", "test"}, want: exitUsage},
+		{name: "unexpected argument", args: []string{"generate", "test"}, want: exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCLIEnv(t, nil)
+			if code := runCLI(context.Background(), tt.args, io.Discard); code != tt.want {
+				t.Errorf("exit code is %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
+
+// setCLIEnv replaces the env variables read by the generate flags with env, and the home directory with an empty
+// one, so the profile file of the user is not read.
+func setCLIEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	for _, name := range cliEnv {
+		t.Setenv(name, env[name])
+	}
+}
@@ -0,0 +1,921 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
+	"tenant-terraform-generator/tf-generator/common"
+
+	"github.com/hashicorp/go-version"
+)
+
+// buildVersion is set at build time with -ldflags "-X main.buildVersion=<version>".
+var buildVersion = "dev"
+
+// duploOptions holds the flags needed to talk to a DuploCloud portal.
+type duploOptions struct {
+	host           string
+	token          string
+	sslNoVerify    bool
+	profileFile    string
+	profileName    string
+	requestTimeout time.Duration
+	record         string
+	replay         string
+
+	recorder *duplosdk.Recorder
+	replayer *duplosdk.Replayer
+}
+
+func (o *duploOptions) register(ef *envFlags) {
+	ef.String(&o.host, "host", "duplo_host", "", "DuploCloud portal URL, e.g. https://msp.duplocloud.net")
+	ef.String(&o.token, "token", "duplo_token", "", "DuploCloud API token")
+	ef.Bool(&o.sslNoVerify, "ssl-no-verify", "ssl_no_verify", false, "Skip TLS certificate verification of the DuploCloud portal")
+	o.registerProfile(ef)
+	ef.Duration(&o.requestTimeout, "request-timeout", "duplo_request_timeout", duplosdk.DefaultRequestTimeout, "Timeout of every DuploCloud API request, 0 disables it")
+	ef.String(&o.record, "record", "duplo_record", "", "Record the DuploCloud API responses to a fixture bundle, with the token and secret values masked")
+	ef.String(&o.replay, "replay", "duplo_replay", "", "Answer the DuploCloud API requests from a fixture bundle written by --record, without contacting the portal")
+}
+
+// registerProfile registers the flags selecting a profile, render uses profiles without talking to the portal.
+func (o *duploOptions) registerProfile(ef *envFlags) {
+	ef.String(&o.profileFile, "config", "duplo_tf_config", common.DefaultProfileFilePath(), "Path of the profile file")
+	ef.String(&o.profileName, "profile", "duplo_profile", "", "Name of the profile to use from the profile file, defaults to its default_profile")
+}
+
+// loadProfile returns the selected profile, or nil when no profile file exists and no profile was asked for.
+func (o *duploOptions) loadProfile(ef *envFlags) (*common.Profile, error) {
+	if !duplosdk.Exists(o.profileFile) {
+		if ef.isSet("config") || len(o.profileName) > 0 {
+			return nil, newUsageError(ef.fs.Name(), "profile file %s does not exist", o.profileFile)
+		}
+		return nil, nil
+	}
+	pf, err := common.LoadProfileFile(o.profileFile)
+	if err != nil {
+		return nil, err
+	}
+	p, err := pf.Profile(o.profileName)
+	if err != nil {
+		return nil, newUsageError(ef.fs.Name(), "%s", err)
+	}
+	return p, nil
+}
+
+func (o *duploOptions) applyProfile(ef *envFlags, p *common.Profile) error {
+	if err := ef.fallback("host", p.Host); err != nil {
+		return err
+	}
+	if p.SSLNoVerify != nil {
+		if err := ef.fallback("ssl-no-verify", strconv.FormatBool(*p.SSLNoVerify)); err != nil {
+			return err
+		}
+	}
+	if p.Token != nil && !ef.isSet("token") {
+		token, err := p.Token.Resolve()
+		if err != nil {
+			return err
+		}
+		return ef.fallback("token", token)
+	}
+	return nil
+}
+
+func (o *duploOptions) validate(command string) error {
+	if len(o.replay) > 0 {
+		if len(o.record) > 0 {
+			return newUsageError(command, "--record and --replay cannot be used together")
+		}
+		return nil
+	}
+	return requireFlags(command, map[string]string{
+		"host":  o.host,
+		"token": o.token,
+	})
+}
+
+func (o *duploOptions) newClient() (*duplosdk.Client, error) {
+	if len(o.replay) > 0 {
+		bundle, err := duplosdk.LoadFixtureBundle(o.replay)
+		if err != nil {
+			return nil, err
+		}
+		c := duplosdk.NewReplayClient(bundle)
+		o.replayer = c.HTTPClient.Transport.(*duplosdk.Replayer)
+		return c, nil
+	}
+	c, err := duplosdk.NewClient(o.host, o.token)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating duplo client: %s", err)
+	}
+	c.RequestTimeout = o.requestTimeout
+	if o.sslNoVerify {
+		c.HTTPClient.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	if len(o.record) > 0 {
+		o.recorder = c.Record()
+	}
+	return c, nil
+}
+
+// closeClient saves the recorded responses, and reports the requests which were missing from the replayed bundle.
+func (o *duploOptions) closeClient() error {
+	if o.replayer != nil {
+		if missing := o.replayer.Missing(); len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "%d requests were not recorded in %s and got a 404 response:\n  %s\n", len(missing), o.replay, strings.Join(missing, "\n  "))
+		}
+	}
+	if o.recorder == nil {
+		return nil
+	}
+	if err := o.recorder.Save(o.record); err != nil {
+		return fmt.Errorf("error saving the recorded responses: %s", err)
+	}
+	fmt.Fprintf(os.Stderr, "DuploCloud API responses recorded to %s, check it before sharing: only the token and the known secret fields are masked.\n", o.record)
+	return nil
+}
+
+// generateOptions holds the flags of the generate and import commands.
+type generateOptions struct {
+	duplo                duploOptions
+	tenantName           string
+	allTenants           bool
+	planID               string
+	customerName         string
+	certArn              string
+	duploProviderVersion string
+	infraProject         string
+	tenantProject        string
+	awsServicesProject   string
+	appProject           string
+	generateTfState      bool
+	generateInfra        bool
+	s3Backend            bool
+	backend              common.Backend
+	parallelism          int
+	timeout              time.Duration
+	keepGoing            bool
+	output               string
+	merge                bool
+	importBlocks         bool
+	terraform            common.TerraformOptions
+	validateMode         string
+	secrets              string
+	tfVars               bool
+	modules              bool
+	redactSecrets        bool
+	strictSecrets        bool
+}
+
+func (o *generateOptions) register(ef *envFlags, withTfStateFlag bool) {
+	o.duplo.register(ef)
+	o.registerRender(ef)
+	ef.Bool(&o.allTenants, "all", "all_tenants", false, "Export every tenant visible with the DuploCloud credentials")
+	ef.String(&o.planID, "plan", "plan_id", "", "Only export the tenants of this plan, with --all or a list of tenants")
+	if withTfStateFlag {
+		ef.Bool(&o.generateTfState, "generate-tf-state", "generate_tf_state", false, "Import the existing resources into terraform state")
+	}
+}
+
+// registerRender registers the flags controlling how the projects are written, the only ones render takes.
+func (o *generateOptions) registerRender(ef *envFlags) {
+	ef.String(&o.tenantName, "tenant", "tenant_name", "", "Name of the tenant to export, generate and import also take a comma separated list of names and glob patterns, e.g. \"dev*,qa\"")
+	ef.String(&o.customerName, "customer", "customer_name", "", "Customer name, used as the output folder under target/")
+	ef.String(&o.certArn, "cert-arn", "cert_arn", "", "Certificate ARN used as default for the cert_arn variable")
+	ef.String(&o.duploProviderVersion, "provider-version", "duplo_provider_version", "0.8.0", "Version of the duplocloud terraform provider")
+	ef.Bool(&o.generateInfra, "infra", "generate_infra", false, "Also generate the project of the infrastructure (plan) of the tenant, which needs an admin token")
+	ef.String(&o.infraProject, "infra-project", "infra_project", "admin-infra", "Name of the infrastructure terraform project")
+	ef.String(&o.tenantProject, "tenant-project", "tenant_project", "admin-tenant", "Name of the tenant terraform project")
+	ef.String(&o.awsServicesProject, "aws-services-project", "aws_services_project", "aws-services", "Name of the AWS services terraform project")
+	ef.String(&o.appProject, "app-project", "app_project", "app", "Name of the app terraform project")
+	ef.Bool(&o.s3Backend, "s3-backend", "s3_backend", true, "Generate a backend for the terraform projects, false is the same as --backend none")
+	ef.String(&o.backend.Type, "backend", "tf_backend", common.BackendS3, "Backend of the terraform projects: "+strings.Join(common.BackendTypes, ", "))
+	ef.String(&o.backend.Bucket, "backend-bucket", "tf_backend_bucket", "", "Bucket of the s3 and gcs backends, the s3 bucket defaults to duplo-tfstate-<account id> given to terraform init")
+	ef.String(&o.backend.Region, "backend-region", "tf_backend_region", common.DefaultBackendRegion, "Region of the bucket of the s3 backend")
+	ef.String(&o.backend.LockTable, "backend-lock-table", "tf_backend_lock_table", "", "DynamoDB table locking the states of the s3 backend")
+	ef.String(&o.backend.KeyPrefix, "backend-key-prefix", "tf_backend_key_prefix", "", "Prefix of the state keys of the projects, e.g. duplo/")
+	ef.String(&o.backend.Path, "backend-path", "tf_backend_path", common.DefaultBackendPath, "Directory of the states of the local backend, relative to the project directories")
+	ef.String(&o.backend.Address, "backend-address", "tf_backend_address", "", "Base URL of the http backend, the states are at <address>/<tenant>/<project>")
+	ef.String(&o.backend.ResourceGroup, "backend-resource-group", "tf_backend_resource_group", "", "Resource group of the storage account of the azurerm backend")
+	ef.String(&o.backend.StorageAccount, "backend-storage-account", "tf_backend_storage_account", "", "Storage account of the azurerm backend")
+	ef.String(&o.backend.Container, "backend-container", "tf_backend_container", "", "Blob container of the azurerm backend")
+	ef.String(&o.backend.Organization, "backend-organization", "tf_backend_organization", "", "Terraform Cloud organization of the cloud backend, the workspaces are named <tenant>-<project>")
+	ef.String(&o.backend.Hostname, "backend-hostname", "tf_backend_hostname", "", "Hostname of the Terraform Enterprise instance of the cloud backend, app.terraform.io by default")
+	ef.Duration(&o.timeout, "timeout", "duplo_tf_timeout", 0, "Maximum duration of the whole run, e.g. 30m, 0 means no limit")
+	ef.Int(&o.parallelism, "parallelism", "parallelism", common.DefaultParallelism, "Number of generators and DuploCloud API requests run concurrently")
+	ef.String(&o.output, "output", "output", "target", "Where the projects are written: a directory, a .tar.gz or .zip archive, or - for stdout")
+	ef.Bool(&o.merge, "merge", "merge", false, "Keep the files which were not generated or were edited since the last run, see "+common.ManifestFile)
+	ef.Bool(&o.keepGoing, "keep-going", "keep_going", false, "Skip the objects which fail to export and write the rest, the failures are reported at the end")
+	ef.Bool(&o.importBlocks, "import-blocks", "import_blocks", false, "Write the existing resources as import blocks in "+common.ImportBlocksFile+" (terraform 1.5+) with a manifest, to import them with terraform plan")
+	ef.String(&o.terraform.Path, "terraform", "terraform_path", "", "Path of the terraform or tofu binary, by default terraform or tofu is looked for on PATH, then in --terraform-install-dir")
+	ef.String(&o.terraform.VersionConstraint, "terraform-version", "terraform_version", "", "Version constraint of the terraform binary, e.g. \"~> 1.5.0\", "+common.DefaultTerraformVersion+" is downloaded when none is found and no constraint is given")
+	ef.String(&o.terraform.InstallDir, "terraform-install-dir", "terraform_install_dir", common.DefaultTerraformInstallDir(), "Directory caching the downloaded terraform versions, in one directory per version")
+	ef.String(&o.validateMode, "validate", "validate", common.ValidateTerraform, "How the generated projects are validated: "+common.ValidateTerraform+" runs terraform validate and fmt after the in process checks, "+common.ValidateStatic+" only runs the in process checks and does not need terraform")
+	ef.String(&o.secrets, "secrets", "secrets", common.SecretsInline, "How secret values are written: "+common.SecretsInline+" writes them in the resources, "+common.SecretsVariables+" writes them to a git-ignored "+common.SecretsFile+" read through sensitive variables, no other mode is supported")
+	ef.Bool(&o.tfVars, "tfvars", "tfvars", false, "Declare the variables without defaults and write their values to config/<workspace>/<project>.tfvars.json, read by the scripts")
+	ef.Bool(&o.modules, "modules", "modules", false, "Write the duplo services and S3 buckets as local modules in terraform/modules, instantiated with for_each over a map variable")
+	ef.Bool(&o.redactSecrets, "redact-secrets", "redact_secrets", false, "Replace the possible secrets found in the generated files with "+duplosdk.MaskedValue)
+	ef.Bool(&o.strictSecrets, "strict-secrets", "strict_secrets", false, "Fail without writing the projects when they contain possible secrets which are not redacted")
+	ef.Bool(&o.terraform.Offline, "terraform-offline", "terraform_offline", false, "Never download terraform, fail when no binary matching --terraform-version is found")
+}
+
+func (o *generateOptions) applyProfile(ef *envFlags, p *common.Profile) error {
+	if err := o.duplo.applyProfile(ef, p); err != nil {
+		return err
+	}
+	return o.applyRenderProfile(ef, p)
+}
+
+func (o *generateOptions) applyRenderProfile(ef *envFlags, p *common.Profile) error {
+	fallbacks := [][2]string{
+		{"customer", p.Customer},
+		{"cert-arn", p.CertArn},
+		{"provider-version", p.ProviderVersion},
+		{"infra-project", p.Projects.Infra},
+		{"tenant-project", p.Projects.Tenant},
+		{"aws-services-project", p.Projects.AwsServices},
+		{"app-project", p.Projects.App},
+		{"backend", p.Backend.Type},
+		{"backend-bucket", p.Backend.Bucket},
+		{"backend-region", p.Backend.Region},
+		{"backend-lock-table", p.Backend.LockTable},
+		{"backend-key-prefix", p.Backend.KeyPrefix},
+		{"backend-path", p.Backend.Path},
+		{"backend-address", p.Backend.Address},
+		{"backend-resource-group", p.Backend.ResourceGroup},
+		{"backend-storage-account", p.Backend.StorageAccount},
+		{"backend-container", p.Backend.Container},
+		{"backend-organization", p.Backend.Organization},
+		{"backend-hostname", p.Backend.Hostname},
+	}
+	if p.Backend.S3 != nil {
+		fallbacks = append(fallbacks, [2]string{"s3-backend", strconv.FormatBool(*p.Backend.S3)})
+	}
+	for _, fb := range fallbacks {
+		if err := ef.fallback(fb[0], fb[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *generateOptions) validate(command string) error {
+	if err := o.duplo.validate(command); err != nil {
+		return err
+	}
+	if o.allTenants {
+		if len(o.tenantName) > 0 {
+			return newUsageError(command, "--all and --tenant cannot be used together")
+		}
+		return o.validateRender(command, map[string]string{})
+	}
+	if len(o.planID) > 0 && !isBatch(o.tenantName) {
+		return newUsageError(command, "--plan needs --all or a list of tenants")
+	}
+	return o.validateRender(command, map[string]string{
+		"tenant": o.tenantName,
+	})
+}
+
+// validateRender checks the flags registered by registerRender and the required flags of the command,
+// the tenant is optional when rendering a snapshot.
+func (o *generateOptions) validateRender(command string, required map[string]string) error {
+	if o.parallelism < 1 {
+		return newUsageError(command, "--parallelism must be at least 1, got %d", o.parallelism)
+	}
+	if o.timeout < 0 || o.duplo.requestTimeout < 0 {
+		return newUsageError(command, "--timeout and --request-timeout must not be negative")
+	}
+	if o.importBlocks && o.generateTfState {
+		return newUsageError(command, "--import-blocks cannot be used while importing the terraform state")
+	}
+	if o.validateMode != common.ValidateTerraform && o.validateMode != common.ValidateStatic {
+		return newUsageError(command, "--validate must be %s or %s, got %q", common.ValidateTerraform, common.ValidateStatic, o.validateMode)
+	}
+	if o.s3Backend {
+		if err := o.backend.Validate(); err != nil {
+			return newUsageError(command, "invalid --backend: %s", err)
+		}
+	}
+	if o.secrets != common.SecretsInline && o.secrets != common.SecretsVariables {
+		return newUsageError(command, "--secrets must be %s or %s, got %q", common.SecretsInline, common.SecretsVariables, o.secrets)
+	}
+	if len(o.terraform.VersionConstraint) > 0 {
+		if _, err := version.NewConstraint(o.terraform.VersionConstraint); err != nil {
+			return newUsageError(command, "invalid --terraform-version: %s", err)
+		}
+	}
+	required["customer"] = o.customerName
+	required["cert-arn"] = o.certArn
+	return requireFlags(command, required)
+}
+
+// config returns the configuration of a run writing the projects to out.
+func (o *generateOptions) config(out common.Output) *common.Config {
+	config := &common.Config{
+		TenantName:           o.tenantName,
+		CustomerName:         o.customerName,
+		DuploProviderVersion: o.duploProviderVersion,
+		InfraProject:         o.infraProject,
+		TenantProject:        o.tenantProject,
+		AwsServicesProject:   o.awsServicesProject,
+		AppProject:           o.appProject,
+		GenerateTfState:      o.generateTfState,
+		GenerateInfra:        o.generateInfra,
+		Backend:              o.backend,
+		CertArn:              o.certArn,
+		Parallelism:          o.parallelism,
+		KeepGoing:            o.keepGoing,
+		Merge:                o.merge,
+		ImportBlocks:         o.importBlocks,
+		TerraformOptions:     o.terraform,
+		Validate:             o.validateMode,
+		Secrets:              o.secrets,
+		TfVars:               o.tfVars,
+		Modules:              o.modules,
+		RedactSecrets:        o.redactSecrets,
+		StrictSecrets:        o.strictSecrets,
+		Output:               out,
+	}
+	if !o.s3Backend {
+		config.Backend.Type = common.BackendNone
+	}
+	// terraform fmt only runs on directories, the files of the other outputs are formatted as they are written.
+	if _, ok := out.(*common.DirOutput); !ok {
+		config.Output = common.FormattedOutput(out)
+	}
+	return config
+}
+
+// newOutput opens the output given with --output.
+func (o *generateOptions) newOutput(command string) (common.Output, error) {
+	out, err := common.NewOutput(o.output)
+	if err != nil {
+		return nil, newUsageError(command, "%s", err)
+	}
+	// Importing runs terraform in the generated projects, they have to be written to disk.
+	if _, ok := out.(*common.DirOutput); !ok && (o.generateTfState || o.merge) {
+		return nil, newUsageError(command, "importing the terraform state and --merge need --output to be a directory")
+	}
+	return out, nil
+}
+
+// closeOutput writes what was generated by a run which ended with err.
+// Nothing is written when the run failed, only when it completed, skipped some objects with --keep-going
+// or exported some of the tenants of a batch.
+func closeOutput(out common.Output, err error) error {
+	var gerr *generationFailedError
+	var berr *batchFailedError
+	if err != nil && !(errors.As(err, &gerr) && gerr.partial) && !(errors.As(err, &berr) && berr.partial) {
+		return err
+	}
+	if closeErr := out.Close(); closeErr != nil {
+		return fmt.Errorf("error writing output: %s", closeErr)
+	}
+	if archive, ok := out.(*common.ArchiveOutput); ok {
+		log.Printf("[TRACE] Terraform projects are written to archive - %s", archive.Path)
+	}
+	return err
+}
+
+func runGenerate(ctx context.Context, args []string) error {
+	return generateCommand(ctx, "generate", args, false)
+}
+
+func runImport(ctx context.Context, args []string) error {
+	return generateCommand(ctx, "import", args, true)
+}
+
+// parseGenerateOptions reads the options of the generate and import commands from args, the env variables
+// and the selected profile, in this order of precedence.
+func parseGenerateOptions(name string, args []string, importState bool, stderr io.Writer) (*generateOptions, error) {
+	opts := &generateOptions{}
+	fs := newFlagSet(name, "", stderr)
+	ef := newEnvFlags(fs)
+	opts.register(ef, !importState)
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, newUsageError(name, "unexpected arguments: %v", fs.Args())
+	}
+	profile, err := opts.duplo.loadProfile(ef)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		if err := opts.applyProfile(ef, profile); err != nil {
+			return nil, err
+		}
+	}
+	if err := ef.validate(); err != nil {
+		return nil, err
+	}
+	if importState {
+		opts.generateTfState = true
+	}
+	if err := opts.validate(name); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+func generateCommand(ctx context.Context, name string, args []string, importState bool) error {
+	opts, err := parseGenerateOptions(name, args, importState, os.Stderr)
+	if err != nil {
+		return err
+	}
+	out, err := opts.newOutput(name)
+	if err != nil {
+		return err
+	}
+
+	client, err := opts.duplo.newClient()
+	if err != nil {
+		return err
+	}
+	client.SetMaxConcurrentRequests(opts.parallelism)
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	if opts.allTenants || isBatch(opts.tenantName) {
+		err = opts.generateBatch(ctx, out, client)
+	} else {
+		err = generate(ctx, opts.config(out), client)
+	}
+	// The responses are saved even when the generation failed, reproducing the failure is what they are for.
+	recordErr := opts.duplo.closeClient()
+	if recordErr != nil && err != nil {
+		fmt.Fprintln(os.Stderr, recordErr)
+	}
+	if err = closeOutput(out, err); err == nil {
+		return recordErr
+	}
+	return err
+}
+
+// generateBatch exports every tenant selected by --all or --tenant into its own directory of out,
+// the portal wide objects shared by the tenants are read once.
+func (o *generateOptions) generateBatch(ctx context.Context, out common.Output, client *duplosdk.Client) error {
+	client.CacheResponses()
+	patterns := tenantPatterns(o.tenantName)
+	if o.allTenants {
+		patterns = []string{"*"}
+	}
+	results, err := generateTenants(ctx, client, patterns, o.planID, func(tenantName string) *common.Config {
+		config := o.config(out)
+		config.TenantName = tenantName
+		return config
+	})
+	if err != nil {
+		return err
+	}
+	printBatchReport(os.Stderr, results)
+	return batchError(results)
+}
+
+// snapshotOptions holds the flags of the snapshot command.
+type snapshotOptions struct {
+	duplo       duploOptions
+	tenantName  string
+	infra       bool
+	parallelism int
+	timeout     time.Duration
+	keepGoing   bool
+	output      string
+}
+
+func (o *snapshotOptions) register(ef *envFlags) {
+	o.duplo.register(ef)
+	ef.String(&o.tenantName, "tenant", "tenant_name", "", "Name of the tenant to read")
+	ef.Bool(&o.infra, "infra", "generate_infra", false, "Also read the infrastructure (plan) of the tenant, to render it with --infra")
+	ef.Duration(&o.timeout, "timeout", "duplo_tf_timeout", 0, "Maximum duration of the whole run, e.g. 30m, 0 means no limit")
+	ef.Int(&o.parallelism, "parallelism", "parallelism", common.DefaultParallelism, "Number of generators and DuploCloud API requests run concurrently")
+	ef.String(&o.output, "output", "snapshot_output", "snapshot.json", "Path of the snapshot file")
+	ef.Bool(&o.keepGoing, "keep-going", "keep_going", false, "Write the snapshot without the objects which fail to read, the failures are reported at the end")
+}
+
+func (o *snapshotOptions) validate(command string) error {
+	if err := o.duplo.validate(command); err != nil {
+		return err
+	}
+	if o.parallelism < 1 {
+		return newUsageError(command, "--parallelism must be at least 1, got %d", o.parallelism)
+	}
+	if o.timeout < 0 || o.duplo.requestTimeout < 0 {
+		return newUsageError(command, "--timeout and --request-timeout must not be negative")
+	}
+	return requireFlags(command, map[string]string{
+		"tenant": o.tenantName,
+		"output": o.output,
+	})
+}
+
+func runSnapshot(ctx context.Context, args []string) error {
+	opts := &snapshotOptions{}
+	fs := newFlagSet("snapshot", "", os.Stderr)
+	ef := newEnvFlags(fs)
+	opts.register(ef)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return newUsageError("snapshot", "unexpected arguments: %v", fs.Args())
+	}
+	profile, err := opts.duplo.loadProfile(ef)
+	if err != nil {
+		return err
+	}
+	if profile != nil {
+		if err := opts.duplo.applyProfile(ef, profile); err != nil {
+			return err
+		}
+	}
+	if err := ef.validate(); err != nil {
+		return err
+	}
+	if err := opts.validate("snapshot"); err != nil {
+		return err
+	}
+	// Every generator reads its objects, so the snapshot can be rendered with any options.
+	// The infrastructure is only read when asked for, it needs an admin token.
+	all, err := tfgenerator.Registrations()
+	if err != nil {
+		return err
+	}
+	regs := []tfgenerator.Registration{}
+	for _, r := range all {
+		if r.Project != tfgenerator.ProjectInfra || opts.infra {
+			regs = append(regs, r)
+		}
+	}
+
+	client, err := opts.duplo.newClient()
+	if err != nil {
+		return err
+	}
+	client.SetMaxConcurrentRequests(opts.parallelism)
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	config := &common.Config{TenantName: opts.tenantName, Parallelism: opts.parallelism}
+	snapshot, err := fetchSnapshot(ctx, config, client, regs)
+	recordErr := opts.duplo.closeClient()
+	if err == nil {
+		err = opts.save(snapshot)
+	}
+	if recordErr != nil && err != nil {
+		fmt.Fprintln(os.Stderr, recordErr)
+	}
+	if err == nil {
+		return recordErr
+	}
+	return err
+}
+
+// save writes a snapshot unless some objects could not be read without --keep-going.
+func (o *snapshotOptions) save(snapshot *common.Snapshot) error {
+	failures := snapshot.Failures()
+	if len(failures) > 0 && !o.keepGoing {
+		return &generationFailedError{failures: failures, snapshot: true}
+	}
+	if err := common.SaveSnapshot(o.output, snapshot); err != nil {
+		return fmt.Errorf("error writing the snapshot: %s", err)
+	}
+	fmt.Fprintf(os.Stderr, "Snapshot of tenant %s written to %s, it contains the secret values of the tenant.\n", snapshot.Tenant.Name, o.output)
+	if len(failures) > 0 {
+		return &generationFailedError{failures: failures, partial: true, snapshot: true}
+	}
+	return nil
+}
+
+func runRender(ctx context.Context, args []string) error {
+	opts := &generateOptions{}
+	var from string
+	fs := newFlagSet("render", "", os.Stderr)
+	ef := newEnvFlags(fs)
+	opts.duplo.registerProfile(ef)
+	opts.registerRender(ef)
+	ef.String(&from, "from", "snapshot_file", "", "Snapshot written by the snapshot command")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return newUsageError("render", "unexpected arguments: %v", fs.Args())
+	}
+	profile, err := opts.duplo.loadProfile(ef)
+	if err != nil {
+		return err
+	}
+	if profile != nil {
+		if err := opts.applyRenderProfile(ef, profile); err != nil {
+			return err
+		}
+	}
+	if err := ef.validate(); err != nil {
+		return err
+	}
+	if err := opts.validateRender("render", map[string]string{"from": from}); err != nil {
+		return err
+	}
+	snapshot, err := common.LoadSnapshot(from)
+	if err != nil {
+		return err
+	}
+	if len(opts.tenantName) > 0 && !strings.EqualFold(opts.tenantName, snapshot.Tenant.Name) {
+		return newUsageError("render", "--tenant %s does not match tenant %s of snapshot %s", opts.tenantName, snapshot.Tenant.Name, from)
+	}
+	out, err := opts.newOutput("render")
+	if err != nil {
+		return err
+	}
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	return closeOutput(out, render(ctx, opts.config(out), snapshot))
+}
+
+// cloneOptions holds the flags of the clone command.
+type cloneOptions struct {
+	generateOptions
+	from    string
+	to      string
+	mapping *common.CloneMapping
+}
+
+func parseCloneOptions(args []string, stderr io.Writer) (*cloneOptions, error) {
+	opts := &cloneOptions{mapping: &common.CloneMapping{}}
+	var mappingFile string
+	fs := newFlagSet("clone", "", stderr)
+	ef := newEnvFlags(fs)
+	opts.duplo.register(ef)
+	opts.registerRender(ef)
+	ef.String(&opts.from, "from", "clone_from", "", "Name of the tenant to clone")
+	ef.String(&opts.to, "to", "clone_to", "", "Name of the new tenant")
+	ef.String(&mappingFile, "mapping", "clone_mapping", "", "YAML file describing the new tenant: plan, certificate, account, region and substitutions")
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, newUsageError("clone", "unexpected arguments: %v", fs.Args())
+	}
+	if len(mappingFile) > 0 {
+		var err error
+		if opts.mapping, err = common.LoadCloneMapping(mappingFile); err != nil {
+			return nil, err
+		}
+		// The certificate of the mapping is the default of --cert-arn, it takes precedence over the profile.
+		if err := ef.fallback("cert-arn", opts.mapping.Certificate); err != nil {
+			return nil, err
+		}
+	}
+	profile, err := opts.duplo.loadProfile(ef)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		if err := opts.applyProfile(ef, profile); err != nil {
+			return nil, err
+		}
+	}
+	if err := ef.validate(); err != nil {
+		return nil, err
+	}
+	if err := opts.duplo.validate("clone"); err != nil {
+		return nil, err
+	}
+	if len(opts.tenantName) > 0 {
+		return nil, newUsageError("clone", "--tenant cannot be used, the tenants are given with --from and --to")
+	}
+	if opts.importBlocks {
+		return nil, newUsageError("clone", "--import-blocks cannot be used, the resources of the new tenant do not exist yet")
+	}
+	if len(opts.from) > 0 && strings.EqualFold(opts.from, opts.to) {
+		return nil, newUsageError("clone", "--from and --to must be different tenants")
+	}
+	if err := opts.validateRender("clone", map[string]string{"from": opts.from, "to": opts.to, "mapping": mappingFile}); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+func runClone(ctx context.Context, args []string) error {
+	opts, err := parseCloneOptions(args, os.Stderr)
+	if err != nil {
+		return err
+	}
+	out, err := opts.newOutput("clone")
+	if err != nil {
+		return err
+	}
+
+	client, err := opts.duplo.newClient()
+	if err != nil {
+		return err
+	}
+	client.SetMaxConcurrentRequests(opts.parallelism)
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	config := opts.config(out)
+	config.TenantName = opts.from
+	err = clone(ctx, config, client, opts.to, opts.mapping)
+	recordErr := opts.duplo.closeClient()
+	if recordErr != nil && err != nil {
+		fmt.Fprintln(os.Stderr, recordErr)
+	}
+	if err = closeOutput(out, err); err == nil {
+		return recordErr
+	}
+	return err
+}
+
+func runList(ctx context.Context, args []string) error {
+	opts := &duploOptions{}
+	fs := newFlagSet("list", "", os.Stderr)
+	ef := newEnvFlags(fs)
+	opts.register(ef)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	profile, err := opts.loadProfile(ef)
+	if err != nil {
+		return err
+	}
+	if profile != nil {
+		if err := opts.applyProfile(ef, profile); err != nil {
+			return err
+		}
+	}
+	if err := ef.validate(); err != nil {
+		return err
+	}
+	if err := opts.validate("list"); err != nil {
+		return err
+	}
+	client, err := opts.newClient()
+	if err != nil {
+		return err
+	}
+	tenants, clientErr := client.WithContext(ctx).ListTenantsForUser()
+	if err := opts.closeClient(); err != nil {
+		return err
+	}
+	if clientErr != nil {
+		return fmt.Errorf("error listing tenants from duplo: %s", clientErr)
+	}
+	sort.Slice(*tenants, func(i, j int) bool {
+		return (*tenants)[i].AccountName < (*tenants)[j].AccountName
+	})
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTENANT ID\tPLAN")
+	for _, t := range *tenants {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.AccountName, t.TenantID, t.PlanID)
+	}
+	return w.Flush()
+}
+
+// generatorInfo is the JSON form of a generator registration printed by list-generators.
+type generatorInfo struct {
+	Name          string   `json:"name"`
+	Project       string   `json:"project"`
+	ResourceTypes []string `json:"resource_types"`
+	DuploAPIs     []string `json:"duplo_apis"`
+	DependsOn     []string `json:"depends_on"`
+	Conditional   bool     `json:"conditional"`
+}
+
+func runListGenerators(ctx context.Context, args []string) error {
+	var project string
+	var asJSON bool
+	fs := newFlagSet("list-generators", "", os.Stderr)
+	fs.StringVar(&project, "project", "", fmt.Sprintf("Only list the generators of a project, one of %s", strings.Join(tfgenerator.Projects, ", ")))
+	fs.BoolVar(&asJSON, "json", false, "Print the generators as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return newUsageError("list-generators", "unexpected arguments: %v", fs.Args())
+	}
+	regs, err := tfgenerator.Registrations()
+	if err != nil {
+		return err
+	}
+	infos := []generatorInfo{}
+	for _, r := range regs {
+		if len(project) > 0 && r.Project != project {
+			continue
+		}
+		infos = append(infos, generatorInfo{
+			Name:          r.Name,
+			Project:       r.Project,
+			ResourceTypes: nonNil(r.ResourceTypes),
+			DuploAPIs:     nonNil(r.DuploAPIs),
+			DependsOn:     nonNil(r.DependsOn),
+			Conditional:   r.Enabled != nil,
+		})
+	}
+	if len(infos) == 0 && len(project) > 0 {
+		return newUsageError("list-generators", "unknown project %q, expected one of %s", project, strings.Join(tfgenerator.Projects, ", "))
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPROJECT\tRESOURCES\tDUPLO APIS\tDEPENDS ON")
+	for _, info := range infos {
+		name := info.Name
+		if info.Conditional {
+			name += "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, info.Project, joinOrDash(info.ResourceTypes), joinOrDash(info.DuploAPIs), joinOrDash(info.DependsOn))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println("\n* generated only when enabled by the configuration, e.g. backends with --s3-backend.")
+	return nil
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func joinOrDash(s []string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return strings.Join(s, ",")
+}
+
+func runDiff(ctx context.Context, args []string) error {
+	fs := newFlagSet("diff", "<old-dir> <new-dir>", os.Stderr)
+	asJSON := fs.Bool("json", false, "Print the changes as JSON")
+	check := fs.Bool("check", false, "Exit with code 4 when there are changes, e.g. to detect drift in CI")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return newUsageError("diff", "expected exactly two directories to compare, got %d", fs.NArg())
+	}
+	changes, err := diffTerraformDirs(fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(struct {
+			Changes []diffChange `json:"changes"`
+			Summary diffSummary  `json:"summary"`
+		}{changes, summarizeChanges(changes)})
+		if err != nil {
+			return err
+		}
+	} else {
+		printChanges(os.Stdout, changes)
+	}
+	if *check && len(changes) > 0 {
+		return &changesFoundError{count: len(changes)}
+	}
+	return nil
+}
+
+func runVersion(ctx context.Context, args []string) error {
+	fs := newFlagSet("version", "", os.Stderr)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	fmt.Printf("%s %s\n", binaryName, buildVersion)
+	return nil
+}
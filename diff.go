@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Actions of a diffChange or an attributeChange.
+const (
+	actionAdded    = "added"
+	actionRemoved  = "removed"
+	actionModified = "modified"
+)
+
+// diffChange is an added, removed or modified resource, data source, module, local value, variable or output of a terraform project.
+type diffChange struct {
+	// Project is the directory of the terraform project, relative to the compared directories.
+	Project string `json:"project"`
+	Kind    string `json:"kind"`
+	// Address is the terraform address of the object, e.g. duplocloud_tenant.tenant, local.tenant_id or var.region.
+	Address    string            `json:"address"`
+	Action     string            `json:"action"`
+	Attributes []attributeChange `json:"attributes,omitempty"`
+}
+
+// attributeChange is a changed attribute of a modified object.
+// Nested blocks are flattened into the name, e.g. setting[0].value.
+type attributeChange struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// diffSummary counts the changes by action.
+type diffSummary struct {
+	Added    int `json:"added"`
+	Removed  int `json:"removed"`
+	Modified int `json:"modified"`
+}
+
+// tfObject is a top level block of a terraform project with its attributes flattened.
+type tfObject struct {
+	kind       string
+	attributes map[string]string
+	// values are the attributes formatted for display.
+	values map[string]string
+}
+
+// diffTerraformDirs compares the resources, data sources, modules, local values, variables and outputs of the terraform files
+// below two directories. Formatting and the split of blocks between files do not matter, only the expressions are compared.
+func diffTerraformDirs(oldDir, newDir string) ([]diffChange, error) {
+	oldObjects, err := parseTerraformDir(oldDir)
+	if err != nil {
+		return nil, err
+	}
+	newObjects, err := parseTerraformDir(newDir)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for k := range oldObjects {
+		keys = append(keys, k)
+	}
+	for k := range newObjects {
+		if _, ok := oldObjects[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := []diffChange{}
+	for _, k := range keys {
+		project, address := splitObjectKey(k)
+		oldObj, inOld := oldObjects[k]
+		newObj, inNew := newObjects[k]
+		switch {
+		case !inOld:
+			changes = append(changes, diffChange{Project: project, Kind: newObj.kind, Address: address, Action: actionAdded})
+		case !inNew:
+			changes = append(changes, diffChange{Project: project, Kind: oldObj.kind, Address: address, Action: actionRemoved})
+		default:
+			if attrs := diffAttributes(oldObj, newObj); len(attrs) > 0 {
+				changes = append(changes, diffChange{Project: project, Kind: newObj.kind, Address: address, Action: actionModified, Attributes: attrs})
+			}
+		}
+	}
+	return changes, nil
+}
+
+func diffAttributes(oldObj, newObj *tfObject) []attributeChange {
+	names := []string{}
+	for n := range oldObj.attributes {
+		names = append(names, n)
+	}
+	for n := range newObj.attributes {
+		if _, ok := oldObj.attributes[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	changes := []attributeChange{}
+	for _, n := range names {
+		oldVal, inOld := oldObj.attributes[n]
+		newVal, inNew := newObj.attributes[n]
+		switch {
+		case !inOld:
+			changes = append(changes, attributeChange{Name: n, Action: actionAdded, New: newObj.values[n]})
+		case !inNew:
+			changes = append(changes, attributeChange{Name: n, Action: actionRemoved, Old: oldObj.values[n]})
+		case oldVal != newVal:
+			changes = append(changes, attributeChange{Name: n, Action: actionModified, Old: oldObj.values[n], New: newObj.values[n]})
+		}
+	}
+	return changes
+}
+
+// parseTerraformDir parses every .tf file below dir, the objects are keyed by project directory and address.
+func parseTerraformDir(dir string) (map[string]*tfObject, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	objects := map[string]*tfObject{}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".terraform" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".tf" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		file, diags := hclwrite.ParseConfig(data, path, hcl.InitialPos)
+		if diags.HasErrors() {
+			return fmt.Errorf("error parsing %s: %s", path, diags.Error())
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		project := filepath.ToSlash(rel)
+		for _, block := range file.Body().Blocks() {
+			// Every local value is an object, whichever locals block declares it.
+			if block.Type() == "locals" && len(block.Labels()) == 0 {
+				for name, attr := range block.Body().Attributes() {
+					tokens := attr.Expr().BuildTokens(nil)
+					objects[project+"\x00local."+name] = &tfObject{kind: "local",
+						attributes: map[string]string{"value": compactTokens(tokens)}, values: map[string]string{"value": displayTokens(tokens)}}
+				}
+				continue
+			}
+			kind, address := objectAddress(block)
+			if len(address) == 0 {
+				continue
+			}
+			obj := &tfObject{kind: kind, attributes: map[string]string{}, values: map[string]string{}}
+			flattenBody(obj, "", block.Body())
+			objects[project+"\x00"+address] = obj
+		}
+		return nil
+	})
+	return objects, err
+}
+
+func splitObjectKey(key string) (string, string) {
+	parts := strings.SplitN(key, "\x00", 2)
+	return parts[0], parts[1]
+}
+
+// objectAddress returns the kind and terraform address of a top level block, or an empty address for the blocks which are not compared.
+func objectAddress(block *hclwrite.Block) (string, string) {
+	labels := block.Labels()
+	switch {
+	case block.Type() == "resource" && len(labels) == 2:
+		return "resource", labels[0] + "." + labels[1]
+	case block.Type() == "data" && len(labels) == 2:
+		return "data", "data." + labels[0] + "." + labels[1]
+	case block.Type() == "module" && len(labels) == 1:
+		return "module", "module." + labels[0]
+	case block.Type() == "variable" && len(labels) == 1:
+		return "variable", "var." + labels[0]
+	case block.Type() == "output" && len(labels) == 1:
+		return "output", "output." + labels[0]
+	}
+	return "", ""
+}
+
+func flattenBody(obj *tfObject, prefix string, body *hclwrite.Body) {
+	for name, attr := range body.Attributes() {
+		tokens := attr.Expr().BuildTokens(nil)
+		obj.attributes[prefix+name] = compactTokens(tokens)
+		obj.values[prefix+name] = displayTokens(tokens)
+	}
+	counts := map[string]int{}
+	for _, block := range body.Blocks() {
+		name := strings.Join(append([]string{block.Type()}, block.Labels()...), ".")
+		flattenBody(obj, fmt.Sprintf("%s%s[%d].", prefix, name, counts[name]), block.Body())
+		counts[name]++
+	}
+}
+
+// compactTokens returns the expression without comments, newlines and spaces between tokens.
+func compactTokens(tokens hclwrite.Tokens) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		if t.Type == hclsyntax.TokenComment || t.Type == hclsyntax.TokenNewline {
+			continue
+		}
+		sb.Write(t.Bytes)
+	}
+	return sb.String()
+}
+
+// displayTokens returns the expression formatted on a single line.
+func displayTokens(tokens hclwrite.Tokens) string {
+	return strings.Join(strings.Fields(string(hclwrite.Format(tokens.Bytes()))), " ")
+}
+
+func summarizeChanges(changes []diffChange) diffSummary {
+	s := diffSummary{}
+	for _, c := range changes {
+		switch c.Action {
+		case actionAdded:
+			s.Added++
+		case actionRemoved:
+			s.Removed++
+		case actionModified:
+			s.Modified++
+		}
+	}
+	return s
+}
+
+var actionSymbols = map[string]string{actionAdded: "+", actionRemoved: "-", actionModified: "~"}
+
+// printChanges writes the changes in a plan like format.
+func printChanges(w io.Writer, changes []diffChange) {
+	project := ""
+	for i, c := range changes {
+		if i == 0 || c.Project != project {
+			project = c.Project
+			fmt.Fprintf(w, "%s:\n", project)
+		}
+		fmt.Fprintf(w, "  %s %s\n", actionSymbols[c.Action], c.Address)
+		for _, a := range c.Attributes {
+			switch a.Action {
+			case actionAdded:
+				fmt.Fprintf(w, "      + %s = %s\n", a.Name, a.New)
+			case actionRemoved:
+				fmt.Fprintf(w, "      - %s = %s\n", a.Name, a.Old)
+			default:
+				fmt.Fprintf(w, "      ~ %s = %s -> %s\n", a.Name, a.Old, a.New)
+			}
+		}
+	}
+	s := summarizeChanges(changes)
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+	fmt.Fprintf(w, "\n%d added, %d removed, %d modified.\n", s.Added, s.Removed, s.Modified)
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// diffOld and diffNew are two generations of the same tenant, the blocks of app are split differently between files.
+var (
+	diffOld = map[string]string{
+		"tenant/main.tf": `resource "duplocloud_tenant" "tenant" {
+  account_name = "test"
+  plan_id      = "default"
+}
+
+variable "region" {
+  default = "us-west-2"
+}
+
+output "tenant_id" {
+  value = duplocloud_tenant.tenant.tenant_id
+}
+`,
+		"app/services.tf": `resource "duplocloud_duplo_service" "web" {
+  name          = "web"
+  replicas      = 1
+  agent_platform = 0
+  other_docker_config = jsonencode({ "Env" : [] })
+
+  setting {
+    key   = "a"
+    value = "1"
+  }
+  setting {
+    key   = "b"
+    value = "2"
+  }
+}
+
+resource "duplocloud_duplo_service" "worker" {
+  name = "worker"
+}
+
+data "duplocloud_tenant" "tenant" {
+  name = "test"
+}
+
+variable "old_var" {}
+
+output "worker" {
+  value = duplocloud_duplo_service.worker.name
+}
+`,
+	}
+	diffNew = map[string]string{
+		"tenant/main.tf": `# Formatting and comments do not matter.
+resource "duplocloud_tenant" "tenant" {
+  plan_id = "default"
+  account_name = "test"
+}
+
+variable "region" {
+  default = "us-east-1"
+}
+
+output "tenant_id" {
+  value = duplocloud_tenant.tenant.tenant_id
+  description = "The tenant"
+}
+`,
+		"app/services.tf": `resource "duplocloud_duplo_service" "web" {
+  name     = "web"
+  replicas = 2
+  other_docker_config = jsonencode({ "Env" : [] })
+  lb_synced = true
+
+  setting {
+    key   = "a"
+    value = "1"
+  }
+  setting {
+    key   = "b"
+    value = "3"
+  }
+}
+
+resource "duplocloud_duplo_service" "api" {
+  name = "api"
+}
+`,
+		"app/data.tf": `data "duplocloud_tenant" "tenant" {
+  name = "test"
+}
+
+variable "new_var" {}
+`,
+	}
+)
+
+func writeTerraformDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDiffTerraformDirs(t *testing.T) {
+	changes, err := diffTerraformDirs(writeTerraformDir(t, diffOld), writeTerraformDir(t, diffNew))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []diffChange{
+		{Project: "app", Kind: "resource", Address: "duplocloud_duplo_service.api", Action: actionAdded},
+		{Project: "app", Kind: "resource", Address: "duplocloud_duplo_service.web", Action: actionModified, Attributes: []attributeChange{
+			{Name: "agent_platform", Action: actionRemoved, Old: "0"},
+			{Name: "lb_synced", Action: actionAdded, New: "true"},
+			{Name: "replicas", Action: actionModified, Old: "1", New: "2"},
+			{Name: "setting[1].value", Action: actionModified, Old: `"2"`, New: `"3"`},
+		}},
+		{Project: "app", Kind: "resource", Address: "duplocloud_duplo_service.worker", Action: actionRemoved},
+		{Project: "app", Kind: "output", Address: "output.worker", Action: actionRemoved},
+		{Project: "app", Kind: "variable", Address: "var.new_var", Action: actionAdded},
+		{Project: "app", Kind: "variable", Address: "var.old_var", Action: actionRemoved},
+		{Project: "tenant", Kind: "output", Address: "output.tenant_id", Action: actionModified, Attributes: []attributeChange{
+			{Name: "description", Action: actionAdded, New: `"The tenant"`},
+		}},
+		{Project: "tenant", Kind: "variable", Address: "var.region", Action: actionModified, Attributes: []attributeChange{
+			{Name: "default", Action: actionModified, Old: `"us-west-2"`, New: `"us-east-1"`},
+		}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes are\n%+v\nwant\n%+v", changes, want)
+	}
+	if got, want := summarizeChanges(changes), (diffSummary{Added: 2, Removed: 3, Modified: 3}); got != want {
+		t.Errorf("summary is %+v, want %+v", got, want)
+	}
+}
+
+// TestDiffTerraformDirsModulesAndLocals checks the module calls are compared like resources, and every local value on its own.
+func TestDiffTerraformDirsModulesAndLocals(t *testing.T) {
+	oldDir := writeTerraformDir(t, map[string]string{
+		"app/main.tf": `locals {
+  tenant_id = "1234"
+  region    = "us-west-2"
+  old       = true
+}
+module "duplo_services" {
+  source   = "../modules/duplo-service"
+  for_each = var.duplo_services
+  settings = each.value
+}
+module "old_services" {
+  source = "../modules/duplo-service"
+}
+`,
+	})
+	newDir := writeTerraformDir(t, map[string]string{
+		"app/main.tf": `locals {
+  tenant_id = "1234"
+}
+module "duplo_services" {
+  source    = "../modules/duplo-service"
+  for_each  = var.duplo_services
+  settings  = each.value
+  tenant_id = local.tenant_id
+}
+module "new_services" {
+  source = "../modules/duplo-service"
+}
+`,
+		"app/locals.tf": `locals {
+  region = "us-east-1"
+}
+`,
+	})
+	changes, err := diffTerraformDirs(oldDir, newDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []diffChange{
+		{Project: "app", Kind: "local", Address: "local.old", Action: actionRemoved},
+		{Project: "app", Kind: "local", Address: "local.region", Action: actionModified, Attributes: []attributeChange{
+			{Name: "value", Action: actionModified, Old: `"us-west-2"`, New: `"us-east-1"`},
+		}},
+		{Project: "app", Kind: "module", Address: "module.duplo_services", Action: actionModified, Attributes: []attributeChange{
+			{Name: "tenant_id", Action: actionAdded, New: "local.tenant_id"},
+		}},
+		{Project: "app", Kind: "module", Address: "module.new_services", Action: actionAdded},
+		{Project: "app", Kind: "module", Address: "module.old_services", Action: actionRemoved},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes are\n%+v\nwant\n%+v", changes, want)
+	}
+}
+
+func TestDiffTerraformDirsErrors(t *testing.T) {
+	dir := writeTerraformDir(t, diffOld)
+	if _, err := diffTerraformDirs(dir, filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("a missing directory was compared")
+	}
+	if _, err := diffTerraformDirs(dir, filepath.Join(dir, "app", "services.tf")); err == nil {
+		t.Errorf("a file was compared as a directory")
+	}
+	invalid := writeTerraformDir(t, map[string]string{"app/main.tf": `resource "duplocloud_tenant" {`})
+	if _, err := diffTerraformDirs(dir, invalid); err == nil {
+		t.Errorf("an invalid terraform file was compared")
+	}
+}
+
+// TestDiffJSON checks the shape of diff --json, which is read by CI scripts.
+func TestDiffJSON(t *testing.T) {
+	oldDir := writeTerraformDir(t, map[string]string{"app/main.tf": `variable "region" {
+  default = "us-west-2"
+}
+`})
+	newDir := writeTerraformDir(t, map[string]string{"app/main.tf": `variable "region" {
+  default = "us-east-1"
+}
+
+output "region" {
+  value = var.region
+}
+`})
+	code, stdout := runCLIStdout(t, []string{"diff", "--json", oldDir, newDir})
+	if code != exitOK {
+		t.Fatalf("exit code is %d, want %d", code, exitOK)
+	}
+	got := map[string]interface{}{}
+	if err := json.Unmarshal(stdout, &got); err != nil {
+		t.Fatalf("error parsing the output: %s\n%s", err, stdout)
+	}
+	want := map[string]interface{}{
+		"changes": []interface{}{
+			map[string]interface{}{"project": "app", "kind": "output", "address": "output.region", "action": "added"},
+			map[string]interface{}{"project": "app", "kind": "variable", "address": "var.region", "action": "modified",
+				"attributes": []interface{}{
+					map[string]interface{}{"name": "default", "action": "modified", "old": `"us-west-2"`, "new": `"us-east-1"`},
+				}},
+		},
+		"summary": map[string]interface{}{"added": 1.0, "removed": 0.0, "modified": 1.0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("output is\n%s\nwant\n%+v", stdout, want)
+	}
+}
+
+func TestDiffCheck(t *testing.T) {
+	oldDir := writeTerraformDir(t, diffOld)
+	newDir := writeTerraformDir(t, diffNew)
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "changes", args: []string{"diff", "--check", oldDir, newDir}, want: exitChanges},
+		{name: "no changes", args: []string{"diff", "--check", oldDir, oldDir}, want: exitOK},
+		{name: "changes as json", args: []string{"diff", "--check", "--json", oldDir, newDir}, want: exitChanges},
+		{name: "changes without check", args: []string{"diff", oldDir, newDir}, want: exitOK},
+		{name: "missing directory", args: []string{"diff", "--check", oldDir}, want: exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := runCLIStdout(t, tt.args); code != tt.want {
+				t.Errorf("exit code is %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
+
+// runCLIStdout runs the command line with args and returns its exit code and what it wrote to stdout.
+func runCLIStdout(t *testing.T, args []string) (int, []byte) {
+	t.Helper()
+	stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	saved := os.Stdout
+	os.Stdout = stdout
+	code := runCLI(context.Background(), args, io.Discard)
+	os.Stdout = saved
+	data, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, data
+}
@@ -0,0 +1,46 @@
+package duplosdk
+
+import (
+	"strings"
+	"sync"
+)
+
+// cachedPrefixes are the paths of the portal wide APIs, whose responses are the same for every tenant.
+var cachedPrefixes = []string{"admin/", "adminproxy/", "v2/admin/"}
+
+// responseCache keeps the bodies of the GET responses of the portal wide APIs, keyed by path.
+type responseCache struct {
+	mu     sync.Mutex
+	bodies map[string][]byte
+}
+
+// CacheResponses makes the client answer the GET requests of the portal wide APIs, like the tenant list and the
+// infrastructures, from the responses it already received. The copies made by WithContext share the cache,
+// so the tenants exported by a run read these objects once.
+func (c *Client) CacheResponses() {
+	c.cache = &responseCache{bodies: map[string][]byte{}}
+}
+
+func (r *responseCache) get(apiPath string) ([]byte, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	body, ok := r.bodies[apiPath]
+	return body, ok
+}
+
+func (r *responseCache) put(apiPath string, body []byte) {
+	if r == nil {
+		return
+	}
+	for _, prefix := range cachedPrefixes {
+		if strings.HasPrefix(apiPath, prefix) {
+			r.mu.Lock()
+			r.bodies[apiPath] = body
+			r.mu.Unlock()
+			return
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package duplosdk
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -112,11 +114,23 @@ func responseHttpError(req *http.Request, res *http.Response) ClientError {
 	return clientError{status: res.StatusCode, url: url, message: message, response: response}
 }
 
+// DefaultRequestTimeout is the RequestTimeout of the clients created by NewClient.
+const DefaultRequestTimeout = 20 * time.Second
+
 // Client is a Duplo API client
 type Client struct {
 	HTTPClient *http.Client
 	HostURL    string
 	Token      string
+	// RequestTimeout bounds the round trip of every API request, not the wait for a request slot.
+	// Zero means requests are only bound by the client context.
+	RequestTimeout time.Duration
+
+	// requestSlots bounds the number of requests in flight, nil means unbounded.
+	requestSlots chan struct{}
+	ctx          context.Context
+	// cache is set by CacheResponses.
+	cache *responseCache
 }
 
 // NewClient creates a new Duplo API client
@@ -124,16 +138,63 @@ func NewClient(host, token string) (*Client, error) {
 	if host != "" && token != "" {
 		tokenBearer := fmt.Sprintf("Bearer %s", token)
 		c := Client{
-			HTTPClient: &http.Client{Timeout: 20 * time.Second},
-			HostURL:    host,
-			Token:      tokenBearer,
+			HTTPClient:     &http.Client{},
+			HostURL:        host,
+			Token:          tokenBearer,
+			RequestTimeout: DefaultRequestTimeout,
 		}
 		return &c, nil
 	}
 	return nil, fmt.Errorf("missing provider config for 'duplo_token' 'duplo_host'. Not defined in environment var / main.tf")
 }
 
+// SetMaxConcurrentRequests bounds the number of API requests the client runs at the same time.
+// It must be called before the client is shared between goroutines, n <= 0 removes the bound.
+func (c *Client) SetMaxConcurrentRequests(n int) {
+	if n <= 0 {
+		c.requestSlots = nil
+		return
+	}
+	c.requestSlots = make(chan struct{}, n)
+}
+
+// WithContext returns a copy of the client whose API requests are canceled when ctx is done.
+// The copy shares the HTTP client and the request bound of c.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+// Context returns the context the client requests are bound to.
+func (c *Client) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+// newRequest builds a request bound to the client context.
+func (c *Client) newRequest(verb string, url string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(c.Context(), verb, url, body)
+}
+
+// doRequestWithStatus waits for a request slot, then sends req bound to the RequestTimeout and reads the response.
 func (c *Client) doRequestWithStatus(req *http.Request, expectedStatus int) ([]byte, ClientError) {
+	if c.requestSlots != nil {
+		select {
+		case c.requestSlots <- struct{}{}:
+			defer func() { <-c.requestSlots }()
+		case <-req.Context().Done():
+			return nil, ioHttpError(req, req.Context().Err())
+		}
+	}
+	// The timeout starts once the slot is held, so that waiting for other requests does not count toward it.
+	if c.RequestTimeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), c.RequestTimeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
 	req.Header.Set("Authorization", c.Token)
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 
@@ -181,17 +242,24 @@ func (c *Client) doAPI(verb string, apiName string, apiPath string, rp interface
 	// Build the request
 	url := fmt.Sprintf("%s/%s", c.HostURL, apiPath)
 	log.Printf("[TRACE] %s: prepared request: %s", apiName, url)
-	req, err := http.NewRequest(verb, url, nil)
+	req, err := c.newRequest(verb, url, nil)
 	if err != nil {
 		log.Printf("[TRACE] %s: cannot build request: %s", apiName, err.Error())
 		return nil
 	}
 
-	// Call the API and get the response.
-	body, httpErr := c.doRequest(req)
-	if httpErr != nil {
-		log.Printf("[TRACE] %s: failed: %s", apiName, httpErr.Error())
-		return httpErr
+	// Call the API and get the response, unless it was already received.
+	body, cached := c.cache.get(apiPath)
+	if verb != "GET" || !cached {
+		var httpErr ClientError
+		body, httpErr = c.doRequest(req)
+		if httpErr != nil {
+			log.Printf("[TRACE] %s: failed: %s", apiName, httpErr.Error())
+			return httpErr
+		}
+		if verb == "GET" {
+			c.cache.put(apiPath, body)
+		}
 	}
 	bodyString := string(body)
 	log.Printf("[TRACE] %s: received response: %s", apiName, bodyString)
@@ -230,7 +298,7 @@ func (c *Client) doAPIWithRequestBody(verb string, apiName string, apiPath strin
 		return requestHttpError(url, message)
 	}
 	log.Printf("[TRACE] %s: prepared request: %s <= (%s)", apiName, url, rqBody)
-	req, err := http.NewRequest(verb, url, strings.NewReader(string(rqBody)))
+	req, err := c.newRequest(verb, url, strings.NewReader(string(rqBody)))
 	if err != nil {
 		log.Printf("[TRACE] %s: cannot build request: %s", apiName, err.Error())
 		return nil
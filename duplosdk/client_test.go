@@ -0,0 +1,57 @@
+package duplosdk
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowClient returns a client of a server answering {} after delay, with one request slot and timeout.
+func slowClient(t *testing.T, delay, timeout time.Duration) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+	c, err := NewClient(server.URL, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RequestTimeout = timeout
+	c.SetMaxConcurrentRequests(1)
+	return c
+}
+
+func TestRequestTimeoutExcludesSlotWait(t *testing.T) {
+	c := slowClient(t, 100*time.Millisecond, 300*time.Millisecond)
+	// The last request waits about 400ms for its slot, longer than the timeout of its round trip.
+	errs := make([]ClientError, 5)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rp := map[string]interface{}{}
+			errs[i] = c.getAPI("slow", "slow", &rp)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("request %d failed: %s", i, err)
+		}
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	c := slowClient(t, 300*time.Millisecond, 50*time.Millisecond)
+	rp := map[string]interface{}{}
+	err := c.getAPI("slow", "slow", &rp)
+	if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("got error %v, want the request to time out", err)
+	}
+}
@@ -0,0 +1,82 @@
+// Package duplotest provides a fake DuploCloud API serving canned responses, for tests running without a portal.
+package duplotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"tenant-terraform-generator/duplosdk"
+)
+
+// Fixtures maps a request, written as "<METHOD> <path>" like "GET /admin/GetTenantsForUser" (see duplosdk.RequestKey), to the JSON body of its response.
+type Fixtures map[string]json.RawMessage
+
+// LoadFixtures reads fixtures from a JSON file.
+func LoadFixtures(path string) (Fixtures, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fixtures := Fixtures{}
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("error parsing fixtures %s: %s", path, err)
+	}
+	return fixtures, nil
+}
+
+// Server is a fake DuploCloud API answering every request from its fixtures.
+// Requests without a fixture get a 404 response and are recorded, so tests can report the fixtures they lack.
+type Server struct {
+	*httptest.Server
+	// Token is the token the server expects, the clients of the tests must use it.
+	Token string
+
+	fixtures Fixtures
+	mu       sync.Mutex
+	missing  map[string]bool
+}
+
+// NewServer starts a fake DuploCloud API, it must be closed by the caller.
+func NewServer(fixtures Fixtures) *Server {
+	s := &Server{Token: "duplotest-token", fixtures: fixtures, missing: map[string]bool{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		http.Error(w, `{"Message":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	key := duplosdk.RequestKey(r)
+	body, ok := s.fixtures[key]
+	if !ok {
+		s.mu.Lock()
+		s.missing[key] = true
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"Message":"no fixture for %s"}`, strings.ReplaceAll(key, `"`, `'`))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// Missing returns the requests the server had no fixture for, sorted.
+func (s *Server) Missing() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.missing))
+	for k := range s.missing {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -22,16 +22,19 @@ type DuploEksCredentials struct {
 
 // DuploInfrastructure represents a Duplo infrastructure
 type DuploInfrastructure struct {
-	Name               string                 `json:"Name"`
-	AccountId          string                 `json:"AccountId"`
-	Cloud              int                    `json:"Cloud"`
-	Region             string                 `json:"Region"`
-	AzCount            int                    `json:"AzCount"`
-	EnableK8Cluster    bool                   `json:"EnableK8Cluster"`
-	AddressPrefix      string                 `json:"AddressPrefix"`
-	SubnetCidr         int                    `json:"SubnetCidr"`
-	ProvisioningStatus string                 `json:"ProvisioningStatus"`
-	CustomData         *[]DuploKeyStringValue `json:"CustomData,omitempty"`
+	Name             string `json:"Name"`
+	AccountId        string `json:"AccountId"`
+	Cloud            int    `json:"Cloud"`
+	Region           string `json:"Region"`
+	AzCount          int    `json:"AzCount"`
+	EnableK8Cluster  bool   `json:"EnableK8Cluster"`
+	EnableECSCluster bool   `json:"EnableECSCluster"`
+	// EnableContainerInsights turns on CloudWatch Container Insights for the ECS cluster.
+	EnableContainerInsights bool                   `json:"EnableContainerInsights"`
+	AddressPrefix           string                 `json:"AddressPrefix"`
+	SubnetCidr              int                    `json:"SubnetCidr"`
+	ProvisioningStatus      string                 `json:"ProvisioningStatus"`
+	CustomData              *[]DuploKeyStringValue `json:"CustomData,omitempty"`
 }
 
 // DuploInfrastructureVnet represents a Duplo infrastructure VNET subnet
@@ -89,6 +92,7 @@ type DuploInfrastructureConfig struct {
 	Region             string                   `json:"Region"`
 	AzCount            int                      `json:"AzCount"`
 	EnableK8Cluster    bool                     `json:"EnableK8Cluster"`
+	EnableECSCluster   bool                     `json:"EnableECSCluster"`
 	Vnet               *DuploInfrastructureVnet `json:"Vnet"`
 	ProvisioningStatus string                   `json:"ProvisioningStatus"`
 }
@@ -0,0 +1,292 @@
+package duplosdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FixtureBundleVersion is the version of the fixture bundles written by a Recorder.
+const FixtureBundleVersion = 1
+
+// MaskedValue replaces the secret values of the recorded responses.
+const MaskedValue = "**MASKED**"
+
+// FixtureBundle holds the recorded responses of the DuploCloud API, keyed by request as returned by RequestKey.
+// It contains neither the portal URL nor the token, and the secret values of the responses are masked.
+type FixtureBundle struct {
+	Version   int                         `json:"version"`
+	Responses map[string]*FixtureResponse `json:"responses"`
+}
+
+// FixtureResponse is a recorded response.
+type FixtureResponse struct {
+	Status int `json:"status"`
+	// Body is the response when it is JSON, Text otherwise.
+	Body json.RawMessage `json:"body,omitempty"`
+	Text string          `json:"text,omitempty"`
+}
+
+func (r *FixtureResponse) bytes() []byte {
+	if len(r.Body) > 0 {
+		return r.Body
+	}
+	return []byte(r.Text)
+}
+
+// RequestKey identifies a request in a FixtureBundle, e.g. "GET /admin/GetTenantsForUser".
+func RequestKey(req *http.Request) string {
+	key := req.Method + " " + req.URL.EscapedPath()
+	if len(req.URL.RawQuery) > 0 {
+		key += "?" + req.URL.RawQuery
+	}
+	return key
+}
+
+// LoadFixtureBundle reads a bundle written by a Recorder.
+func LoadFixtureBundle(path string) (*FixtureBundle, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &FixtureBundle{}
+	if err := json.Unmarshal(data, bundle); err != nil {
+		return nil, fmt.Errorf("error parsing fixture bundle %s: %s", path, err)
+	}
+	if bundle.Version != FixtureBundleVersion {
+		return nil, fmt.Errorf("unsupported fixture bundle version %d in %s", bundle.Version, path)
+	}
+	if bundle.Responses == nil {
+		bundle.Responses = map[string]*FixtureResponse{}
+	}
+	return bundle, nil
+}
+
+// Recorder is an http.RoundTripper recording the responses of the DuploCloud API into a FixtureBundle.
+type Recorder struct {
+	Transport http.RoundTripper
+
+	// secrets are the strings removed from every recorded response, e.g. the API token.
+	secrets []string
+	mu      sync.Mutex
+	bundle  FixtureBundle
+}
+
+// Record makes the client record every response of the DuploCloud API, the bundle is written with Recorder.Save.
+// It must be called after the HTTP transport of the client is configured.
+func (c *Client) Record() *Recorder {
+	r := &Recorder{
+		Transport: c.HTTPClient.Transport,
+		secrets:   []string{c.Token, strings.TrimPrefix(c.Token, "Bearer ")},
+		bundle:    FixtureBundle{Version: FixtureBundleVersion, Responses: map[string]*FixtureResponse{}},
+	}
+	if r.Transport == nil {
+		r.Transport = http.DefaultTransport
+	}
+	c.HTTPClient.Transport = r
+	return r
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := r.Transport.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	recorded := &FixtureResponse{Status: res.StatusCode}
+	if sanitized, ok := sanitizeJSON(body); ok {
+		recorded.Body = sanitized
+	} else {
+		recorded.Text = string(body)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bundle.Responses[RequestKey(req)] = r.stripSecrets(recorded)
+	return res, nil
+}
+
+func (r *Recorder) stripSecrets(res *FixtureResponse) *FixtureResponse {
+	for _, s := range r.secrets {
+		if len(s) == 0 {
+			continue
+		}
+		res.Text = strings.ReplaceAll(res.Text, s, MaskedValue)
+		// The token cannot contain characters escaped by JSON, so it is replaced as is in the raw body.
+		res.Body = bytes.ReplaceAll(res.Body, []byte(s), []byte(MaskedValue))
+	}
+	return res
+}
+
+// Save writes the recorded responses to path.
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.bundle, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// Replayer is an http.RoundTripper answering the requests from a FixtureBundle, without any network access.
+// Requests missing from the bundle get a 404 response.
+type Replayer struct {
+	Bundle *FixtureBundle
+
+	mu      sync.Mutex
+	missing map[string]bool
+}
+
+// NewReplayClient creates a client answering every request from a recorded bundle.
+func NewReplayClient(bundle *FixtureBundle) *Client {
+	c, _ := NewClient("http://duplo-replay.invalid", "replay")
+	c.HTTPClient.Transport = &Replayer{Bundle: bundle, missing: map[string]bool{}}
+	return c
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	key := RequestKey(req)
+	recorded, ok := r.Bundle.Responses[key]
+	if !ok {
+		r.mu.Lock()
+		r.missing[key] = true
+		r.mu.Unlock()
+		message, _ := json.Marshal(map[string]string{"Message": "no recorded response for " + key})
+		recorded = &FixtureResponse{Status: http.StatusNotFound, Body: message}
+	}
+	header := http.Header{}
+	if len(recorded.Body) > 0 {
+		header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	body := recorded.bytes()
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Missing returns the requests which were not in the bundle, sorted.
+func (r *Replayer) Missing() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]string, 0, len(r.missing))
+	for k := range r.missing {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// secretFieldPattern matches the names of the fields whose values are masked in the recorded responses.
+var secretFieldPattern = regexp.MustCompile(`(?i)^(.*password|privatekey|secretaccesskey|accesskeyid|sessiontoken|token|secretdata|.*apikey)$`)
+
+// secretNamePattern matches the names of the environment variables and parameters whose values are masked.
+var secretNamePattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key|private_?key|credential)`)
+
+// sanitizeJSON masks the secret values of a JSON document, ok is false when data is not JSON.
+func sanitizeJSON(data []byte) ([]byte, bool) {
+	sanitized, _, ok := sanitizeDocument(data)
+	return sanitized, ok
+}
+
+// sanitizeDocument masks the secret values of a JSON document, masked tells whether any value was masked.
+func sanitizeDocument(data []byte) (sanitized []byte, masked bool, ok bool) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, false, false
+	}
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return nil, false, false
+	}
+	v = sanitizeValue(v, &masked)
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, false, false
+	}
+	return out, masked, true
+}
+
+func sanitizeValue(v interface{}, masked *bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		// Name/Value pairs like container environment variables and SSM parameters.
+		maskValue := false
+		if name, ok := v["Name"].(string); ok && secretNamePattern.MatchString(name) {
+			maskValue = true
+		}
+		if t, ok := v["Type"].(string); ok && t == "SecureString" {
+			maskValue = true
+		}
+		for k, field := range v {
+			switch {
+			case secretFieldPattern.MatchString(k), maskValue && k == "Value":
+				v[k] = maskAll(field, masked)
+			default:
+				v[k] = sanitizeValue(field, masked)
+			}
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = sanitizeValue(v[i], masked)
+		}
+		return v
+	case string:
+		// Some APIs embed JSON documents in strings, e.g. OtherDockerConfig of the services.
+		// They are only rewritten when a value was masked, to keep the exact formatting otherwise.
+		trimmed := strings.TrimSpace(v)
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			if sanitized, embeddedMasked, ok := sanitizeDocument([]byte(trimmed)); ok && embeddedMasked {
+				*masked = true
+				return string(sanitized)
+			}
+		}
+		return v
+	}
+	return v
+}
+
+// maskAll masks every value of a field, keeping the keys of the objects so the structure of the response is preserved.
+func maskAll(v interface{}, masked *bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			v[k] = maskAll(field, masked)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = maskAll(v[i], masked)
+		}
+		return v
+	case nil, bool:
+		return v
+	}
+	*masked = true
+	return MaskedValue
+}
@@ -0,0 +1,77 @@
+package duplosdk
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		masked []string
+		kept   []string
+	}{
+		{
+			name:   "secret fields",
+			body:   `{"Name":"db","MasterPassword":"hunter2","Credentials":[{"Username":"ubuntu","Password":"pw1","Privatekey":"-----BEGIN"}]}`,
+			masked: []string{"hunter2", "pw1", "-----BEGIN"},
+			kept:   []string{"db", "ubuntu"},
+		},
+		{
+			name:   "k8s secret data",
+			body:   `[{"SecretName":"app","SecretType":"Opaque","SecretData":{"API_KEY":"c2VjcmV0"}}]`,
+			masked: []string{"c2VjcmV0"},
+			kept:   []string{`"SecretName":"app"`, `"API_KEY"`, "Opaque"},
+		},
+		{
+			name:   "ssm secure string",
+			body:   `[{"Name":"/app/url","Type":"String","Value":"https://example.com"},{"Name":"/app/db","Type":"SecureString","Value":"s3cret"}]`,
+			masked: []string{"s3cret"},
+			kept:   []string{"https://example.com"},
+		},
+		{
+			name:   "environment variables embedded in a string",
+			body:   `{"OtherDockerConfig":"{\"Env\":[{\"Name\":\"DB_PASSWORD\",\"Value\":\"pw2\"},{\"Name\":\"PORT\",\"Value\":\"8080\"}]}"}`,
+			masked: []string{"pw2"},
+			kept:   []string{"DB_PASSWORD", "8080"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, ok := sanitizeJSON([]byte(tt.body))
+			if !ok {
+				t.Fatalf("sanitizeJSON(%s) failed", tt.body)
+			}
+			for _, s := range tt.masked {
+				if strings.Contains(string(out), s) {
+					t.Errorf("%q is not masked in %s", s, out)
+				}
+			}
+			for _, s := range tt.kept {
+				if !strings.Contains(string(out), s) {
+					t.Errorf("%q is missing from %s", s, out)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizeJSONKeepsUnmaskedEmbeddedDocuments(t *testing.T) {
+	body := `{"OtherDockerConfig":"{ \"Env\": [ {\"Name\": \"PORT\", \"Value\": \"8080\"} ] }"}`
+	out, ok := sanitizeJSON([]byte(body))
+	if !ok {
+		t.Fatal("sanitizeJSON failed")
+	}
+	if string(out) != body {
+		t.Errorf("sanitizeJSON rewrote a document without secrets:\n%s\nwant:\n%s", out, body)
+	}
+}
+
+func TestSanitizeJSONRejectsText(t *testing.T) {
+	for _, body := range []string{"", "not json", `{"a":1} trailing`} {
+		if _, ok := sanitizeJSON([]byte(body)); ok {
+			t.Errorf("sanitizeJSON(%q) succeeded", body)
+		}
+	}
+}
@@ -8,6 +8,7 @@ require (
 	github.com/hashicorp/hcl/v2 v2.11.1
 	github.com/hashicorp/terraform-exec v0.16.0
 	github.com/zclconf/go-cty v1.10.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -2,413 +2,565 @@ package main
 
 //ReadMe : https://dev.to/pdcommunity/write-terraform-files-in-go-with-hclwrite-2e1j
 import (
+	"bytes"
 	"context"
-	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
-	"path/filepath"
-	"strconv"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
 	"tenant-terraform-generator/duplosdk"
 	tfgenerator "tenant-terraform-generator/tf-generator"
-	"tenant-terraform-generator/tf-generator/app"
-	awsservices "tenant-terraform-generator/tf-generator/aws-services"
+	_ "tenant-terraform-generator/tf-generator/app"
+	_ "tenant-terraform-generator/tf-generator/aws-services"
 	"tenant-terraform-generator/tf-generator/common"
-	"tenant-terraform-generator/tf-generator/tenant"
+	_ "tenant-terraform-generator/tf-generator/infra"
+	_ "tenant-terraform-generator/tf-generator/tenant"
+	"time"
 
-	"github.com/hashicorp/go-version"
-	"github.com/hashicorp/hc-install/product"
-	"github.com/hashicorp/hc-install/releases"
-	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/hashicorp/hcl/v2/hclwrite"
 )
 
-func init() {
-	fmt.Println("This will get called on main initialization")
-}
-
 func main() {
+	// The first interrupt cancels the run so it can stop cleanly, a second one kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	code := runCLI(ctx, os.Args[1:], os.Stderr)
+	stop()
+	os.Exit(code)
+}
 
-	// Initialize duplo client and config
-	log.Println("[TRACE] <====== Initialize duplo client and config. =====>")
-	client := validateAndGetDuploClient()
-	config := validateAndGetConfig()
-	log.Println("[TRACE] <====== Initialized duplo client and config. =====>")
-
-	tenantConfig, err := client.GetTenantByNameForUser(config.TenantName)
+// generate exports the terraform projects of the configured tenant, it stops once ctx is done.
+// The objects are read by the generators enabled with config before anything is written, then rendered from the snapshot.
+func generate(ctx context.Context, config *common.Config, client *duplosdk.Client) error {
+	regs, err := enabledRegistrations(config)
 	if err != nil {
-		log.Fatalf("error getting tenant from duplo: %s", err)
+		return err
 	}
-	if tenantConfig == nil {
-		log.Fatalf("Tenant not found: Tenant Name - %s ", config.TenantName)
-	}
-	config.TenantId = tenantConfig.TenantID
-	accountID, err := client.TenantGetAwsAccountID(config.TenantId)
+	snapshot, err := fetchSnapshot(ctx, config, client, regs)
 	if err != nil {
-		log.Fatalf("error getting aws account id from duplo: %s", err)
+		return err
 	}
-	config.AccountID = accountID
-	log.Println("[TRACE] <====== Initialize target directory with customer name and tenant id. =====>")
-	initTargetDir(config)
-	log.Printf("[TRACE] Config ==> %+v\n", config)
-	log.Println("[TRACE] <====== Initialized target directory with customer name and tenant id. =====>")
-	// Chain of responsiblity started.
-	// Provider --> Tenant --> Hosts --> Services --> ...
-	startTFGeneration(config, client)
-	log.Printf("[TRACE] |==========================================================================|")
-	log.Printf("[TRACE] Terraform projects are generated at - %s", filepath.Join("./target", config.CustomerName, config.TenantName))
-	log.Printf("[TRACE] |==========================================================================|")
+	return render(ctx, config, snapshot)
 }
 
-func validateAndGetDuploClient() *duplosdk.Client {
-	host := os.Getenv("duplo_host")
-	if len(host) == 0 {
-		err := fmt.Errorf("Error - Please provide \"%s\" as env variable.", "duplo_host")
-		log.Printf("[TRACE] - %s", err)
-		os.Exit(1)
-	}
-	token := os.Getenv("duplo_token")
-	if len(token) == 0 {
-		err := fmt.Errorf("Error - Please provide \"%s\" as env variable.", "duplo_token")
-		log.Printf("[TRACE] - %s", err)
-		os.Exit(1)
-	}
-	c, err := duplosdk.NewClient(host, token)
+// clone writes the terraform projects of a new tenant named to, made from the objects of the configured tenant
+// rewritten with the mapping. The resources of the new tenant do not exist yet, nothing is imported.
+func clone(ctx context.Context, config *common.Config, client *duplosdk.Client, to string, mapping *common.CloneMapping) error {
+	regs, err := enabledRegistrations(config)
 	if err != nil {
-		err = fmt.Errorf("Error while creating duplo client %s", err)
-		log.Printf("[TRACE] - %s", err)
-		os.Exit(1)
+		return err
 	}
-
-	sslNoVerify := os.Getenv("ssl_no_verify")
-	if len(sslNoVerify) != 0 {
-		c.HTTPClient.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
+	snapshot, err := fetchSnapshot(ctx, config, client, regs)
+	if err != nil {
+		return err
+	}
+	cloned, err := common.CloneSnapshot(snapshot, to, mapping)
+	if err != nil {
+		return err
 	}
-	return c
+	return render(ctx, config, cloned)
 }
 
-func validateAndGetConfig() *common.Config {
-
-	// tenantId := os.Getenv("tenant_id")
-	// if len(tenantId) == 0 {
-	// 	err := fmt.Errorf("Error - Please provide \"%s\" as env variable.", "tenant_id")
-	// 	log.Printf("[TRACE] - %s", err)
-	// 	os.Exit(1)
-	// }
-	tenantName := os.Getenv("tenant_name")
-	if len(tenantName) == 0 {
-		err := fmt.Errorf("Error - Please provide \"%s\" as env variable.", "tenant_name")
-		log.Printf("[TRACE] - %s", err)
-		os.Exit(1)
-	}
-	custName := os.Getenv("customer_name")
-	if len(custName) == 0 {
-		err := fmt.Errorf("Error - Please provide \"%s\" as env variable.", "customer_name")
-		log.Printf("[TRACE] - %s", err)
-		os.Exit(1)
+// enabledRegistrations returns the generators of every project enabled with config.
+func enabledRegistrations(config *common.Config) ([]tfgenerator.Registration, error) {
+	regs := []tfgenerator.Registration{}
+	for _, project := range tfgenerator.Projects {
+		projectRegs, err := tfgenerator.EnabledRegistrations(project, config)
+		if err != nil {
+			return nil, fmt.Errorf("error building generator list for %s project: %s", project, err)
+		}
+		regs = append(regs, projectRegs...)
 	}
+	return regs, nil
+}
 
-	certArn := os.Getenv("cert_arn")
-	if len(certArn) == 0 {
-		err := fmt.Errorf("Error - Please provide \"%s\" as env variable.", "cert_arn")
-		log.Printf("[TRACE] - %s", err)
-		os.Exit(1)
+// fetchSnapshot reads the duplo objects of the configured tenant for the given generators.
+// The generators which fail are recorded in the snapshot, only a tenant which cannot be resolved is an error.
+func fetchSnapshot(ctx context.Context, config *common.Config, client *duplosdk.Client, regs []tfgenerator.Registration) (*common.Snapshot, error) {
+	client = client.WithContext(ctx)
+	tenantConfig, clientErr := client.GetTenantByNameForUser(config.TenantName)
+	if clientErr != nil {
+		return nil, fmt.Errorf("error getting tenant from duplo: %s", clientErr)
 	}
-
-	duploProviderVersion := os.Getenv("duplo_provider_version")
-	if len(duploProviderVersion) == 0 {
-		duploProviderVersion = "0.8.0"
+	if tenantConfig == nil {
+		return nil, fmt.Errorf("tenant not found: Tenant Name - %s", config.TenantName)
 	}
-
-	tenantProject := os.Getenv("tenant_project")
-	if len(tenantProject) == 0 {
-		tenantProject = "admin-tenant"
+	config.TenantId = tenantConfig.TenantID
+	config.PlanID = tenantConfig.PlanID
+	// Resolved once before generation, generators run concurrently and must not modify the config.
+	config.TenantName = tenantConfig.AccountName
+	accountID, clientErr := client.TenantGetAwsAccountID(config.TenantId)
+	if clientErr != nil {
+		return nil, fmt.Errorf("error getting aws account id from duplo: %s", clientErr)
 	}
-
-	awsServicesProject := os.Getenv("aws_services_project")
-	if len(awsServicesProject) == 0 {
-		awsServicesProject = "aws-services"
+	config.AccountID = accountID
+	region, clientErr := client.TenantGetAwsRegion(config.TenantId)
+	if clientErr != nil {
+		return nil, fmt.Errorf("error getting aws region from duplo: %s", clientErr)
+	}
+	config.Region = region
+
+	snapshot := &common.Snapshot{
+		Version:    common.SnapshotVersion,
+		CreatedAt:  time.Now().UTC(),
+		Tenant:     common.SnapshotTenant{Name: config.TenantName, ID: config.TenantId, AccountID: config.AccountID, PlanID: config.PlanID, Region: config.Region},
+		Generators: map[string]*common.Fetched{},
+	}
+	fetched := make([]*common.Fetched, len(regs))
+	err := common.ForEachParallel(ctx, config.Workers(), len(regs), func(i int) error {
+		f := &common.Fetched{}
+		if err := regs[i].New().Fetch(ctx, config, client, f); err != nil {
+			log.Printf("[TRACE] Generator %s failed: %s", regs[i].Name, err)
+			f.Data = nil
+			f.Failures = append(f.Failures, &common.ObjectError{Err: err})
+		}
+		for _, e := range f.Failures {
+			e.Generator = regs[i].Name
+		}
+		fetched[i] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	appProject := os.Getenv("app_project")
-	if len(appProject) == 0 {
-		appProject = "app"
+	for i, reg := range regs {
+		snapshot.Generators[reg.Name] = fetched[i]
 	}
+	return snapshot, nil
+}
 
-	generateTfState := false
-
-	generateTfStateStr := os.Getenv("generate_tf_state")
-	if len(generateTfStateStr) == 0 {
-		generateTfState = false
-	} else {
-		generateTfStateBool, err := strconv.ParseBool(generateTfStateStr)
-		if err != nil {
-			err = fmt.Errorf("Error while reading generate_tf_state from env vars %s", err)
-			log.Printf("[TRACE] - %s", err)
-			os.Exit(1)
+// render writes the terraform projects of the tenant of a snapshot with the generators enabled with config.
+func render(ctx context.Context, config *common.Config, snapshot *common.Snapshot) error {
+	config.TenantId = snapshot.Tenant.ID
+	config.TenantName = snapshot.Tenant.Name
+	config.AccountID = snapshot.Tenant.AccountID
+	config.PlanID = snapshot.Tenant.PlanID
+	config.Region = snapshot.Tenant.Region
+	if len(config.Region) == 0 {
+		config.Region = common.DefaultRegion
+	}
+	var merge *common.MergeOutput
+	if config.Merge {
+		var mergeErr error
+		merge, mergeErr = common.NewMergeOutput(config.Output.(*common.DirOutput), path.Join(config.CustomerName, config.TenantName))
+		if mergeErr != nil {
+			return fmt.Errorf("error reading the manifest of the last run: %s", mergeErr)
 		}
-		generateTfState = generateTfStateBool
+		config.Output = merge
 	}
-
-	s3Backend := true
-	s3BackendStr := os.Getenv("s3_backend")
-	if len(s3BackendStr) == 0 {
-		s3Backend = true
-	} else {
-		s3BackendBool, err := strconv.ParseBool(s3BackendStr)
+	log.Println("[TRACE] <====== Initialize target directory with customer name and tenant id. =====>")
+	if err := initTargetDir(config); err != nil {
+		return fmt.Errorf("error initializing target directory: %s", err)
+	}
+	log.Printf("[TRACE] Config ==> %+v\n", config)
+	log.Println("[TRACE] <====== Initialized target directory with customer name and tenant id. =====>")
+	// Chain of responsiblity started.
+	// Provider --> Tenant --> Hosts --> Services --> ...
+	failures, genErr := startTFGeneration(ctx, config, snapshot)
+	if merge != nil {
+		// Files of the objects which failed to export would look stale.
+		merge.KeepStale = genErr != nil || missingObjects(failures)
+		report, err := merge.Finish()
 		if err != nil {
-			err = fmt.Errorf("Error while reading s3_backend from env vars %s", err)
-			log.Printf("[TRACE] - %s", err)
-			os.Exit(1)
+			return fmt.Errorf("error saving the manifest: %s", err)
 		}
-		s3Backend = s3BackendBool
+		printMergeReport(os.Stderr, report)
 	}
-
-	return &common.Config{
-		TenantName:           tenantName,
-		CustomerName:         custName,
-		DuploProviderVersion: duploProviderVersion,
-		TenantProject:        tenantProject,
-		AwsServicesProject:   awsServicesProject,
-		AppProject:           appProject,
-		GenerateTfState:      generateTfState,
-		S3Backend:            s3Backend,
-		CertArn:              certArn,
+	if genErr != nil {
+		return genErr
 	}
+	if dir, ok := config.Output.(common.LocalOutput); ok {
+		log.Printf("[TRACE] |==========================================================================|")
+		log.Printf("[TRACE] Terraform projects are generated at - %s", dir.Path(path.Join(config.CustomerName, config.TenantName)))
+		log.Printf("[TRACE] |==========================================================================|")
+	}
+	if len(failures) > 0 {
+		return &generationFailedError{failures: failures, partial: true}
+	}
+	return nil
 }
 
-func initTargetDir(config *common.Config) {
-	config.TFCodePath = filepath.Join("target", config.CustomerName, config.TenantName, "terraform")
-	tenantProject := filepath.Join(config.TFCodePath, config.TenantProject)
-	err := os.RemoveAll(tenantProject)
-	if err != nil {
-		log.Fatal(err)
+// initTargetDir sets the project directories in config and writes the helper scripts next to the projects.
+// When the output is a directory, previously generated projects are removed first.
+func initTargetDir(config *common.Config) error {
+	tenantDir := path.Join(config.CustomerName, config.TenantName)
+	config.TFCodePath = path.Join(tenantDir, "terraform")
+	config.AdminInfraDir = path.Join(config.TFCodePath, config.InfraProject)
+	config.AdminTenantDir = path.Join(config.TFCodePath, config.TenantProject)
+	config.AwsServicesDir = path.Join(config.TFCodePath, config.AwsServicesProject)
+	config.AppDir = path.Join(config.TFCodePath, config.AppProject)
+	scriptsPath := path.Join(tenantDir, "scripts")
+
+	if dir, ok := config.Output.(*common.DirOutput); ok {
+		generated := []string{config.AdminTenantDir, config.AwsServicesDir, config.AppDir, scriptsPath,
+			path.Join(config.TFCodePath, tfgenerator.ModulesDir),
+			path.Join(tenantDir, common.ImportManifestFile), path.Join(tenantDir, common.ImportScriptFile)}
+		// The infrastructure project of a previous run is kept when it is not generated again.
+		if config.GenerateInfra {
+			generated = append(generated, config.AdminInfraDir)
+		}
+		for _, p := range generated {
+			if err := dir.RemoveAll(p); err != nil {
+				return err
+			}
+		}
 	}
-	err = os.MkdirAll(tenantProject, os.ModePerm)
+	err := common.CopyDirToOutput(config.Output, "./scripts", scriptsPath)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	config.AdminTenantDir = tenantProject
-
-	awsServicesProject := filepath.Join(config.TFCodePath, config.AwsServicesProject)
-	err = os.RemoveAll(awsServicesProject)
+	gitignore, err := os.ReadFile(".gitignore")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	err = os.MkdirAll(awsServicesProject, os.ModePerm)
+	err = config.Output.WriteFile(path.Join(tenantDir, ".gitignore"), gitignore, 0644)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	config.AwsServicesDir = awsServicesProject
-
-	appProject := filepath.Join(config.TFCodePath, config.AppProject)
-	err = os.RemoveAll(appProject)
+	envrc, err := os.ReadFile(".envrc")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	err = os.MkdirAll(appProject, os.ModePerm)
-	if err != nil {
-		log.Fatal(err)
+	envrc = append(envrc, []byte("\nexport tenant_id=\""+config.TenantId+"\"")...)
+	if args, ok := config.Backend.ScriptBackendConfig(); ok {
+		envrc = append(envrc, []byte("\nexport tf_backend_config=\""+args+"\"")...)
 	}
-	config.AppDir = appProject
+	return config.Output.WriteFile(path.Join(tenantDir, ".envrc"), envrc, 0644)
+}
 
-	scriptsPath := filepath.Join("target", config.CustomerName, config.TenantName, "scripts")
-	err = os.RemoveAll(scriptsPath)
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = os.MkdirAll(scriptsPath, os.ModePerm)
-	if err != nil {
-		log.Fatal(err)
+// startTFGeneration generates every project and returns the objects which could not be exported.
+// Without --keep-going it stops after the first project with a failure and returns a generationFailedError.
+func startTFGeneration(ctx context.Context, config *common.Config, snapshot *common.Snapshot) ([]*common.ObjectError, error) {
+	// terraform validate and fmt need the projects on disk, other outputs are formatted in process.
+	_, onDisk := config.Output.(common.LocalOutput)
+	if !onDisk {
+		log.Printf("[TRACE] Output is not a directory, terraform validate is skipped.")
+	} else if config.Terraform == nil && (config.Validate != common.ValidateStatic || config.GenerateTfState) {
+		// Resolved before anything is generated, a missing binary should not fail the run halfway,
+		// and the version of the binary decides the generated syntax.
+		if err := config.ResolveTerraform(ctx); err != nil {
+			return nil, fmt.Errorf("error finding terraform: %s", err)
+		}
 	}
-	err = duplosdk.CopyDirectory("./scripts", scriptsPath)
-	if err != nil {
-		log.Fatal(err)
+	providerGen := &common.Provider{}
+	if err := providerGen.Generate(config); err != nil {
+		return nil, fmt.Errorf("error generating providers: %s", err)
+	}
+
+	projectDirs := map[string]string{
+		tfgenerator.ProjectInfra:       config.AdminInfraDir,
+		tfgenerator.ProjectTenant:      config.AdminTenantDir,
+		tfgenerator.ProjectAwsServices: config.AwsServicesDir,
+		tfgenerator.ProjectApp:         config.AppDir,
+	}
+	failures := []*common.ObjectError{}
+	// The projects are rendered in memory first, the literals of a project may become references to the resources
+	// of the projects generated before it, which then get the outputs these references need.
+	rendered := common.NewMemoryOutput()
+	renderConfig := *config
+	renderConfig.Output = rendered
+	contexts := map[string]*common.TFContext{}
+	for _, project := range tfgenerator.Projects {
+		log.Printf("[TRACE] <====== Start TF generation for %s project. =====>", project)
+		// Generators register themselves from the init functions of their packages.
+		regs, err := tfgenerator.EnabledRegistrations(project, config)
+		if err != nil {
+			return nil, fmt.Errorf("error building generator list for %s project: %s", project, err)
+		}
+		if len(regs) == 0 {
+			log.Printf("[TRACE] <====== No generator enabled for %s project. =====>", project)
+			continue
+		}
+		tfContext, err := renderProject(ctx, &renderConfig, snapshot, regs, projectDirs[project])
+		if err != nil {
+			return nil, err
+		}
+		failures = append(failures, tfContext.Failures...)
+		if len(tfContext.Failures) > 0 && !config.KeepGoing {
+			return nil, &generationFailedError{failures: failures}
+		}
+		contexts[project] = tfContext
 	}
-	err = duplosdk.Copy(".gitignore", filepath.Join("target", config.CustomerName, config.TenantName, ".gitignore"))
-	if err != nil {
-		log.Fatal(err)
+	if err := rewriteReferences(config, rendered, contexts, projectDirs); err != nil {
+		return nil, fmt.Errorf("error replacing literals with references: %s", err)
 	}
-	err = duplosdk.Copy(".envrc", filepath.Join("target", config.CustomerName, config.TenantName, ".envrc"))
-	if err != nil {
-		log.Fatal(err)
+	// The variables and outputs are scanned for secrets with the resources, they are rendered too.
+	manifest := &common.ImportManifest{}
+	for _, project := range tfgenerator.Projects {
+		if tfContext, ok := contexts[project]; ok {
+			if err := renderProjectFiles(&renderConfig, tfContext, manifest); err != nil {
+				return nil, err
+			}
+		}
 	}
-	envFile, err := os.OpenFile(filepath.Join("target", config.CustomerName, config.TenantName, ".envrc"), os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Fatal(err)
+	if err := scanSecrets(rendered, config); err != nil {
+		return nil, err
 	}
-	defer envFile.Close()
-	if _, err := envFile.WriteString("\nexport tenant_id=\"" + config.TenantId + "\""); err != nil {
-		log.Fatal(err)
+	for _, name := range rendered.Names() {
+		f, _ := rendered.File(name)
+		if err := config.Output.WriteFile(name, f.Data, f.Mode); err != nil {
+			return nil, err
+		}
 	}
 
-}
-
-func startTFGeneration(config *common.Config, client *duplosdk.Client) {
-	// var tf *tfexec.Terraform
-	providerGen := &common.Provider{}
-	providerGen.Generate(config, client)
-
-	// if config.GenerateTfState {
-	// 	tf := tfInit(config, config.AdminTenantDir)
-	// 	tfNewWorkspace(config, tf)
-	// }
-
-	log.Println("[TRACE] <====== Start TF generation for tenant project. =====>")
-	// Register New TF generator for Tenant Project
-	tenantGeneratorList := []tfgenerator.Generator{
-		&tenant.Tenant{},
-		&tenant.TenantSGRule{},
+	for _, project := range tfgenerator.Projects {
+		tfContext, ok := contexts[project]
+		if !ok {
+			continue
+		}
+		projectFailures, err := finishProject(ctx, config, tfContext)
+		if err != nil {
+			return nil, err
+		}
+		failures = append(failures, projectFailures...)
+		if err := validateProject(ctx, config, projectDirs[project]); err != nil {
+			if !config.KeepGoing || ctx.Err() != nil {
+				return nil, err
+			}
+			failures = append(failures, validationFailures(project, err)...)
+		}
+		log.Printf("[TRACE] <====== End TF generation for %s project. =====>", project)
 	}
-	if config.S3Backend {
-		tenantGeneratorList = append(tenantGeneratorList, &tenant.TenantBackend{})
+	if len(manifest.Imports) > 0 {
+		command := "terraform"
+		if config.Terraform != nil {
+			command = config.Terraform.Command()
+		}
+		if err := manifest.Write(config.Output, path.Dir(config.TFCodePath), command); err != nil {
+			return nil, fmt.Errorf("error writing the import manifest: %s", err)
+		}
 	}
-
-	starTFGenerationForProject(config, client, tenantGeneratorList, config.AdminTenantDir)
-	validateAndFormatTfCode(config.AdminTenantDir)
-	log.Println("[TRACE] <====== End TF generation for tenant project. =====>")
-
-	log.Println("[TRACE] <====== Start TF generation for aws services project. =====>")
-	// Register New TF generator for AWS Services project
-	awsServcesGeneratorList := []tfgenerator.Generator{
-		&awsservices.AwsServicesMain{},
-		&awsservices.Hosts{},
-		&awsservices.ASG{},
-		&awsservices.Rds{},
-		&awsservices.Redis{},
-		&awsservices.Kafka{},
-		&awsservices.S3Bucket{},
-		&awsservices.SQS{},
-		&awsservices.SNS{},
-		&awsservices.MWAA{},
-		&awsservices.ES{},
-		&awsservices.SsmParams{},
-		&awsservices.LoadBalancer{},
-		&awsservices.ApiGatewayIntegration{},
-		&awsservices.CFD{},
-		&awsservices.LambdaFunction{},
-		&awsservices.DynamoDB{},
-		&awsservices.BYOH{},
-		&awsservices.EMR{},
-		&awsservices.CloudwatchMetrics{},
-		&awsservices.ECR{},
-	}
-	if config.S3Backend {
-		awsServcesGeneratorList = append(awsServcesGeneratorList, &awsservices.AwsServicesBackend{})
-	}
-	starTFGenerationForProject(config, client, awsServcesGeneratorList, config.AwsServicesDir)
-	validateAndFormatTfCode(config.AwsServicesDir)
-	log.Println("[TRACE] <====== End TF generation for aws services project. =====>")
-
-	log.Println("[TRACE] <====== Start TF generation for app project. =====>")
-	// Register New TF generator for App Services project
-	appGeneratorList := []tfgenerator.Generator{
-		&app.AppMain{},
-		&app.Services{},
-		&app.ECS{},
-		&app.K8sConfig{},
-		&app.K8sSecret{},
-	}
-	if config.S3Backend {
-		appGeneratorList = append(appGeneratorList, &app.AppBackend{})
-	}
-	starTFGenerationForProject(config, client, appGeneratorList, config.AppDir)
-	validateAndFormatTfCode(config.AppDir)
-	log.Println("[TRACE] <====== End TF generation for app project. =====>")
-
-	// if config.GenerateTfState && config.S3Backend {
-	// 	tf = tfInit(config, config.AppDir)
-	// 	tfDeleteWorkspace(config, tf)
-	// }
-
+	return failures, nil
 }
 
-func starTFGenerationForProject(config *common.Config, client *duplosdk.Client, generatorList []tfgenerator.Generator, targetLocation string) {
+// renderProject runs the generators of a project and returns their merged context,
+// with the objects they could not export.
+func renderProject(ctx context.Context, config *common.Config, snapshot *common.Snapshot, regs []tfgenerator.Registration, targetLocation string) (*common.TFContext, error) {
 
-	tfContext := common.TFContext{
-		TargetLocation: targetLocation,
-		InputVars:      []common.VarConfig{},
-		OutputVars:     []common.OutputVarConfig{},
-	}
-
-	// 1. Generate Duplo TF resources.
-	for _, g := range generatorList {
-		c, err := g.Generate(config, client)
-		if err != nil {
-			log.Fatalf("error running admin tenant tf generation: %s", err)
+	// 1. Generate Duplo TF resources, generators run concurrently and their results are merged in registration order.
+	contexts := make([]*common.TFContext, len(regs))
+	err := common.ForEachParallel(ctx, config.Workers(), len(regs), func(i int) error {
+		fetched, ok := snapshot.Generators[regs[i].Name]
+		if !ok {
+			fetched = &common.Fetched{Failures: []*common.ObjectError{{Err: fmt.Errorf("not in the snapshot")}}}
 		}
-		if c != nil {
-			if len(c.InputVars) > 0 {
-				tfContext.InputVars = append(tfContext.InputVars, c.InputVars...)
+		// The objects which could not be read are reported with the failures of the rendering.
+		failures := append([]*common.ObjectError{}, fetched.Failures...)
+		c := &common.TFContext{}
+		if !fetched.Failed() {
+			rendered, err := regs[i].New().Render(config, fetched)
+			if rendered != nil {
+				c = rendered
 			}
-			if len(c.OutputVars) > 0 {
-				tfContext.OutputVars = append(tfContext.OutputVars, c.OutputVars...)
-			}
-			if len(c.ImportConfigs) > 0 {
-				tfContext.ImportConfigs = append(tfContext.ImportConfigs, c.ImportConfigs...)
+			if err != nil {
+				log.Printf("[TRACE] Generator %s failed: %s", regs[i].Name, err)
+				c.Failures = append(c.Failures, &common.ObjectError{Err: err})
 			}
 		}
+		c.Failures = append(failures, c.Failures...)
+		for _, f := range c.Failures {
+			f.Generator = regs[i].Name
+		}
+		contexts[i] = c
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	// 2. Generate input vars.
+	tfContext := common.MergeTFContexts(targetLocation, contexts)
+	return &tfContext, nil
+}
+
+// renderProjectFiles writes the variables, outputs and import blocks of a rendered project.
+func renderProjectFiles(config *common.Config, tfContext *common.TFContext, manifest *common.ImportManifest) error {
+	// 1. Generate input vars.
 	if len(tfContext.InputVars) > 0 {
 		varsGenerator := common.Vars{
 			TargetLocation: tfContext.TargetLocation,
+			Output:         config.Output,
 			Vars:           tfContext.InputVars,
+			NoDefaults:     config.TfVars,
+		}
+		if err := varsGenerator.Generate(); err != nil {
+			return fmt.Errorf("error generating variables: %s", err)
+		}
+		if config.TfVars {
+			tfVarsFile := common.TfVarsFile{
+				Path:   config.TfVarsPath(path.Base(tfContext.TargetLocation)),
+				Output: config.Output,
+				Vars:   tfContext.InputVars,
+			}
+			if err := tfVarsFile.Generate(); err != nil {
+				return fmt.Errorf("error generating variable values: %s", err)
+			}
 		}
-		varsGenerator.Generate()
 	}
-	// 3. Generate output vars.
+	// Write the values of the sensitive variables next to them.
+	if len(tfContext.Secrets) > 0 {
+		secretVars := common.SecretVars{
+			TargetLocation: tfContext.TargetLocation,
+			Output:         config.Output,
+			Secrets:        tfContext.Secrets,
+		}
+		if err := secretVars.Generate(); err != nil {
+			return fmt.Errorf("error generating secret variables: %s", err)
+		}
+	}
+	// 2. Generate output vars.
 	if len(tfContext.OutputVars) > 0 {
 		outVarsGenerator := common.OutputVars{
 			TargetLocation: tfContext.TargetLocation,
+			Output:         config.Output,
 			OutputVars:     tfContext.OutputVars,
 		}
-		outVarsGenerator.Generate()
+		if err := outVarsGenerator.Generate(); err != nil {
+			return fmt.Errorf("error generating outputs: %s", err)
+		}
 	}
-	// 4. Import all resources
+	// 3. Write the import blocks, the resources are imported by terraform plan.
+	// Older terraform versions import them with the script written from the manifest.
+	if config.ImportBlocks && len(tfContext.ImportConfigs) > 0 {
+		if config.UseImportBlocks() {
+			importBlocks := common.ImportBlocks{
+				TargetLocation: tfContext.TargetLocation,
+				Output:         config.Output,
+				ImportConfigs:  tfContext.ImportConfigs,
+			}
+			if err := importBlocks.Generate(); err != nil {
+				return fmt.Errorf("error generating import blocks: %s", err)
+			}
+		}
+		manifest.Add(strings.TrimPrefix(tfContext.TargetLocation, path.Dir(config.TFCodePath)+"/"), tfContext.ImportConfigs)
+	}
+	return nil
+}
+
+// finishProject imports the resources of a written project, and returns the ones which could not be imported
+// with --keep-going.
+func finishProject(ctx context.Context, config *common.Config, tfContext *common.TFContext) ([]*common.ObjectError, error) {
+	failures := []*common.ObjectError{}
+	// Import all resources
 	if config.GenerateTfState && len(tfContext.ImportConfigs) > 0 {
 		tfInitializer := common.TfInitializer{
-			WorkingDir: targetLocation,
+			WorkingDir: config.Output.(common.LocalOutput).Path(tfContext.TargetLocation),
 			Config:     config,
+			Workspace:  config.ProjectState(path.Base(tfContext.TargetLocation)).Workspace,
+		}
+		tf, err := tfInitializer.InitWithWorkspace(ctx)
+		if err != nil {
+			return nil, err
 		}
-		tf := tfInitializer.InitWithWorkspace()
 		importer := &common.Importer{}
 		for _, ic := range tfContext.ImportConfigs {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			//importer.Import(config, &ic)
-			importer.ImportWithoutInit(config, &ic, tf)
+			if err := importer.ImportWithoutInit(ctx, config, &ic, tf); err != nil {
+				if !config.KeepGoing {
+					return nil, err
+				}
+				failures = append(failures, &common.ObjectError{Generator: importStep, Object: ic.ResourceAddress, Err: err})
+			}
 		}
 		//tfInitializer.DeleteWorkspace(config, tf)
 	}
+	return failures, nil
 }
 
-func validateAndFormatTfCode(tfDir string) {
-	log.Printf("[TRACE] Validation and formatting of terraform code generated at %s is started.", tfDir)
-	installer := &releases.ExactVersion{
-		Product: product.Terraform,
-		Version: version.Must(version.NewVersion("0.14.11")),
+// validateProject checks the generated files of a project in process, then with terraform validate and fmt
+// when the output is a directory, unless only the static validation is enabled.
+func validateProject(ctx context.Context, config *common.Config, projectDir string) error {
+	files, err := common.ProjectFiles(config.Output, projectDir)
+	if err != nil {
+		return fmt.Errorf("error reading the terraform code generated at %s: %s", projectDir, err)
+	}
+	log.Printf("[TRACE] Static validation of terraform code generated at %s is started.", projectDir)
+	if err := common.NewValidationError(common.ValidateTerraformFiles(files)); err != nil {
+		return err
+	}
+	dir, onDisk := config.Output.(common.LocalOutput)
+	if !onDisk {
+		return nil
+	}
+	// With --merge the files of the user are left alone, only the generated files are formatted.
+	merge, merging := config.Output.(*common.MergeOutput)
+	if merging {
+		for name := range files {
+			if !merge.Written(name) {
+				delete(files, name)
+			}
+		}
+	}
+	if config.Validate == common.ValidateTerraform {
+		// terraform fmt formats every file of the directory, it only runs when all of them were generated.
+		if err := validateAndFormatTfCode(ctx, config, dir.Path(projectDir), !merging); err != nil {
+			return err
+		}
+		if !merging {
+			return nil
+		}
 	}
+	return formatTfCode(files)
+}
 
-	execPath, err := installer.Install(context.Background())
-	if err != nil {
-		log.Fatalf("error installing Terraform: %s", err)
+// formatTfCode rewrites the files of a project written to disk which are not formatted, like terraform fmt.
+func formatTfCode(files map[string][]byte) error {
+	for name, data := range files {
+		formatted := hclwrite.Format(data)
+		if bytes.Equal(formatted, data) {
+			continue
+		}
+		if err := os.WriteFile(name, formatted, 0644); err != nil {
+			return fmt.Errorf("error formatting %s: %s", name, err)
+		}
 	}
-	tf, err := tfexec.NewTerraform(tfDir, execPath)
+	return nil
+}
+
+// validationFailures returns the failures reported for a project which did not pass validation,
+// one per problem found by the static validation.
+func validationFailures(project string, err error) []*common.ObjectError {
+	var verr *common.ValidationError
+	if !errors.As(err, &verr) {
+		return []*common.ObjectError{{Generator: validateStep, Object: project + " project", Err: err}}
+	}
+	failures := []*common.ObjectError{}
+	for _, p := range verr.Problems {
+		failures = append(failures, &common.ObjectError{Generator: staticValidateStep, Object: p.Location, Err: errors.New(p.Message)})
+	}
+	return failures
+}
+
+// validateAndFormatTfCode runs terraform validate in a project directory, then terraform fmt when format is set.
+func validateAndFormatTfCode(ctx context.Context, config *common.Config, tfDir string, format bool) error {
+	log.Printf("[TRACE] Validation and formatting of terraform code generated at %s is started.", tfDir)
+	tf, err := config.NewTerraform(ctx, tfDir)
 	if err != nil {
-		log.Fatalf("error running NewTerraform: %s", err)
+		return err
 	}
 	log.Printf("[TRACE] Validation of terraform code generated at %s is started.", tfDir)
-	_, err = tf.Validate(context.Background())
+	_, err = tf.Validate(ctx)
 	if err != nil {
-		log.Fatalf("error running terraform validate: %s", err)
+		return fmt.Errorf("error running terraform validate: %s", err)
 	}
 	log.Printf("[TRACE] Validation of terraform code generated at %s is done.", tfDir)
+	if !format {
+		return nil
+	}
 	log.Printf("[TRACE] Formatting of terraform code generated at %s is started.", tfDir)
-	err = tf.FormatWrite(context.Background())
+	err = tf.FormatWrite(ctx)
 	if err != nil {
-		log.Fatalf("error running terraform format: %s", err)
+		return fmt.Errorf("error running terraform format: %s", err)
 	}
 	log.Printf("[TRACE] Formatting of terraform code generated at %s is done.", tfDir)
 	log.Printf("[TRACE] Validation and formatting of terraform code generated at %s is done.", tfDir)
+	return nil
 }
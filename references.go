@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	tfgenerator "tenant-terraform-generator/tf-generator"
+	"tenant-terraform-generator/tf-generator/common"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// remoteStateFile holds the terraform_remote_state data sources a project needs to refer to the resources of
+// the projects generated before it.
+const remoteStateFile = "remote-state.tf"
+
+// rewriteReferences replaces the literals of the rendered projects which identify a generated resource
+// with references to it. A project refers to the resources of the projects generated before it through
+// their outputs, which are added to their context when missing.
+func rewriteReferences(config *common.Config, rendered *common.MemoryOutput, contexts map[string]*common.TFContext, projectDirs map[string]string) error {
+	renderConfig := *config
+	renderConfig.Output = rendered
+	index := common.NewReferenceIndex()
+	for _, project := range tfgenerator.Projects {
+		if c, ok := contexts[project]; ok {
+			index.Add(project, c.References)
+		}
+	}
+	if index.Len() == 0 {
+		return nil
+	}
+	log.Printf("[TRACE] <====== Replacing literals with references to %d values. =====>", index.Len())
+	for i, project := range tfgenerator.Projects {
+		if _, ok := contexts[project]; !ok {
+			continue
+		}
+		upstream := map[string]bool{}
+		for _, p := range tfgenerator.Projects[:i] {
+			upstream[p] = true
+		}
+		remoteStates := map[string]bool{}
+		rewriter := &common.ReferenceRewriter{
+			Index:   index,
+			Project: project,
+			Remote: func(ref common.Reference, source string) hcl.Traversal {
+				sourceContext, ok := contexts[source]
+				if !ok || !upstream[source] {
+					return nil
+				}
+				// main.tf of every project already reads the state of the tenant project.
+				if source != tfgenerator.ProjectTenant {
+					remoteStates[source] = true
+				}
+				return hcl.Traversal{
+					hcl.TraverseRoot{Name: "data"},
+					hcl.TraverseAttr{Name: "terraform_remote_state"},
+					hcl.TraverseAttr{Name: common.GetResourceName(source)},
+					hcl.TraverseAttr{Name: "outputs"},
+					hcl.TraverseIndex{Key: cty.StringVal(exportReference(sourceContext, ref))},
+				}
+			},
+		}
+		files, err := common.ProjectFiles(rendered, projectDirs[project])
+		if err != nil {
+			return err
+		}
+		changed, err := rewriter.Rewrite(files)
+		if err != nil {
+			return err
+		}
+		for name, data := range changed {
+			log.Printf("[TRACE] Literals replaced with references in %s.", name)
+			if err := rendered.WriteFile(name, data, 0644); err != nil {
+				return err
+			}
+		}
+		if len(remoteStates) > 0 {
+			if err := writeRemoteStates(&renderConfig, projectDirs, project, remoteStates); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// exportReference returns the name of the output of a project exporting the attribute of ref,
+// the output is added to the context of the project when it has none.
+func exportReference(c *common.TFContext, ref common.Reference) string {
+	for _, o := range c.OutputVars {
+		if o.RootTraversal && o.ActualVal == ref.Expression() {
+			return o.Name
+		}
+	}
+	name := common.GetResourceName(ref.Address + "_" + ref.Attribute)
+	c.OutputVars = append(c.OutputVars, common.OutputVarConfig{
+		Name:          name,
+		ActualVal:     ref.Expression(),
+		DescVal:       fmt.Sprintf("The %s of %s.", ref.Attribute, ref.Address),
+		RootTraversal: true,
+	})
+	sort.SliceStable(c.OutputVars, func(i, j int) bool {
+		return c.OutputVars[i].Name < c.OutputVars[j].Name
+	})
+	return name
+}
+
+// writeRemoteStates writes the remote state data sources of the projects the resources of project refer to.
+// They read the state of the projects like the tenant remote state of main.tf.
+func writeRemoteStates(config *common.Config, projectDirs map[string]string, project string, sources map[string]bool) error {
+	hclFile := hclwrite.NewEmptyFile()
+	rootBody := hclFile.Body()
+	for _, source := range tfgenerator.Projects {
+		if !sources[source] {
+			continue
+		}
+		tfgenerator.AppendRemoteState(rootBody, config, common.GetResourceName(source), path.Base(projectDirs[source]))
+		rootBody.AppendNewline()
+	}
+	return config.Output.WriteFile(path.Join(projectDirs[project], remoteStateFile), hclFile.Bytes(), 0644)
+}
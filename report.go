@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"tenant-terraform-generator/tf-generator/common"
+	"text/tabwriter"
+	"time"
+)
+
+// Generator names used in the failure report for the terraform steps run after generation.
+const (
+	staticValidateStep = "static validation"
+	validateStep       = "terraform validate"
+	importStep         = "terraform import"
+)
+
+// missingObjects reports whether a generator failed, so the generated projects may lack some objects.
+func missingObjects(failures []*common.ObjectError) bool {
+	for _, f := range failures {
+		if f.Generator != staticValidateStep && f.Generator != validateStep && f.Generator != importStep {
+			return true
+		}
+	}
+	return false
+}
+
+// generationFailedError is returned when some duplo objects could not be exported.
+type generationFailedError struct {
+	failures []*common.ObjectError
+	// partial is set when the projects were written without the failed objects, with --keep-going.
+	partial bool
+	// snapshot is set when the objects were read for a snapshot instead of projects.
+	snapshot bool
+}
+
+func (e *generationFailedError) Error() string {
+	if e.snapshot {
+		if e.partial {
+			return fmt.Sprintf("%d object(s) could not be read, the snapshot is incomplete", len(e.failures))
+		}
+		return fmt.Sprintf("%d object(s) could not be read, rerun with --keep-going to write the snapshot without them", len(e.failures))
+	}
+	if e.partial {
+		return fmt.Sprintf("%d object(s) could not be exported, the generated projects are incomplete", len(e.failures))
+	}
+	return fmt.Sprintf("%d object(s) could not be exported, rerun with --keep-going to write the projects without them", len(e.failures))
+}
+
+// printFailureReport writes one line per failed object with the duplo API response when there is one.
+func printFailureReport(w io.Writer, title string, failures []*common.ObjectError) {
+	fmt.Fprintf(w, "\n%s:\n", title)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "GENERATOR\tOBJECT\tSTATUS\tURL\tERROR")
+	for _, f := range failures {
+		object, status, url, msg := f.Object, "-", "-", f.Err.Error()
+		if len(object) == 0 {
+			object = "-"
+		}
+		if clientErr := f.ClientError(); clientErr != nil {
+			if clientErr.Status() > 0 {
+				status = strconv.Itoa(clientErr.Status())
+			}
+			if len(clientErr.URL()) > 0 {
+				url = clientErr.URL()
+			}
+			if m, ok := clientErr.Response()["Message"].(string); ok && len(m) > 0 {
+				msg = m
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.Generator, object, status, url, strings.ReplaceAll(msg, "\n", " "))
+	}
+	tw.Flush()
+}
+
+// printBatchReport writes the status of every tenant of a batch run, then the objects of each tenant which could not
+// be exported.
+func printBatchReport(w io.Writer, results []*tenantResult) {
+	fmt.Fprintf(w, "\nTenants:\n")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TENANT\tSTATUS\tDURATION\tERROR")
+	for _, r := range results {
+		duration, msg := "-", "-"
+		if r.duration > 0 {
+			duration = r.duration.Round(time.Second).String()
+		}
+		if r.err != nil {
+			msg = strings.ReplaceAll(r.err.Error(), "\n", " ")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.tenant, r.status, duration, msg)
+	}
+	tw.Flush()
+	for _, r := range results {
+		var gerr *generationFailedError
+		if errors.As(r.err, &gerr) {
+			printFailureReport(w, "Failed objects of tenant "+r.tenant, gerr.failures)
+		}
+	}
+}
+
+// printMergeReport lists the files --merge did not overwrite or removed.
+func printMergeReport(w io.Writer, report *common.MergeReport) {
+	if len(report.Conflicts) > 0 {
+		fmt.Fprintf(w, "\nFiles edited since the last run, the generated version was written next to them with the %s suffix:\n", common.ConflictSuffix)
+		for _, name := range report.Conflicts {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+	if len(report.Removed) > 0 {
+		fmt.Fprintf(w, "\nRemoved files which are no longer generated:\n")
+		for _, name := range report.Removed {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+	if len(report.KeptStale) > 0 {
+		fmt.Fprintf(w, "\nKept files which are no longer generated, they were edited or the run failed:\n")
+		for _, name := range report.KeptStale {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+}
+
+// printSecretReport lists the possible secrets found in the generated files, without their values.
+func printSecretReport(w io.Writer, findings []common.SecretFinding, redacted bool) {
+	if redacted {
+		fmt.Fprintf(w, "\nPossible secrets redacted from the generated files:\n")
+	} else {
+		fmt.Fprintf(w, "\nPossible secrets in the generated files, check them before committing the projects:\n")
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tRESOURCE\tATTRIBUTE\tRULE")
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s:%d\t%s\t%s\t%s\n", f.File, f.Line, f.Resource, f.Attribute, f.Rule)
+	}
+	tw.Flush()
+}
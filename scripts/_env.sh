@@ -5,7 +5,9 @@ duplo_default_tenant_id="$tenant_id"
 duplo_host="$duplo_host"
 duplo_token="$duplo_token"
 
-backend="-backend-config=bucket=duplo-tfstate-${AWS_ACCOUNT_ID} -backend-config=dynamodb_table=duplo-tfstate-${AWS_ACCOUNT_ID}-lock"
+# The -backend-config arguments of terraform init, the generated .envrc sets tf_backend_config
+# when the projects do not use the default state bucket of the account.
+backend="${tf_backend_config-"-backend-config=bucket=duplo-tfstate-${AWS_ACCOUNT_ID} -backend-config=dynamodb_table=duplo-tfstate-${AWS_ACCOUNT_ID}-lock"}"
 
 # Test required environment variables
 for key in duplo_token duplo_host
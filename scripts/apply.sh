@@ -28,7 +28,7 @@ source "$(dirname "${BASH_SOURCE[0]}")/_env.sh"
 tf_apply() {
   local project="$1" ; shift
 
-  [ -z "${backend:-}" ] && die "internal error: backend should have been configured by _env.sh"
+  [ -z "${backend+set}" ] && die "internal error: backend should have been configured by _env.sh"
 
   # Skip projects that are not selected.
   if [ -n "$selection" ] && [ "$selection" != "$project" ]; then
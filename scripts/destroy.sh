@@ -28,7 +28,7 @@ source "$(dirname "${BASH_SOURCE[0]}")/_env.sh"
 function tf_destroy() {
   local project="$1" ; shift
 
-  [ -z "${backend:-}" ] && die "internal error: backend should have been configured by _env.sh"
+  [ -z "${backend+set}" ] && die "internal error: backend should have been configured by _env.sh"
 
   # Skip projects that are not selected.
   if [ -n "$selection" ] && [ "$selection" != "$project" ]; then
@@ -40,7 +40,7 @@ function tf_destroy() {
 
   local tf_args=( -auto-approve -input=false "$@" )
 
-  local varfile="configs/$ws/$project.tfvars.json"
+  local varfile="config/$ws/$project.tfvars.json"
   [ -f "$varfile" ] && tf_args=( "${tf_args[@]}" "-var-file=../../$varfile" )
 
   echo "Project: $project"
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"tenant-terraform-generator/tf-generator/common"
+)
+
+// scanSecrets looks for secrets in the rendered projects before they are written, and reports them:
+// the resources, the defaults of the variables, their values with --tfvars and the outputs.
+// With --redact-secrets the secrets are masked, otherwise --strict-secrets fails the run.
+func scanSecrets(rendered *common.MemoryOutput, config *common.Config) error {
+	findings := []common.SecretFinding{}
+	for _, name := range rendered.Names() {
+		// The secrets written with --secrets variables are meant to be there.
+		if path.Base(name) == common.SecretsFile || path.Base(name) == ".gitignore" {
+			continue
+		}
+		f, _ := rendered.File(name)
+		found := common.ScanSecrets(name, f.Data)
+		if len(found) == 0 {
+			continue
+		}
+		log.Printf("[TRACE] %d possible secret(s) found in %s.", len(found), name)
+		if config.RedactSecrets {
+			if err := rendered.WriteFile(name, common.RedactSecrets(f.Data, found), f.Mode); err != nil {
+				return err
+			}
+		}
+		findings = append(findings, found...)
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+	printSecretReport(os.Stderr, findings, config.RedactSecrets)
+	if config.StrictSecrets && !config.RedactSecrets {
+		return fmt.Errorf("%d possible secret(s) found in the generated files, the projects were not written: use --secrets variables, --redact-secrets, or rerun without --strict-secrets", len(findings))
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+resource "duplocloud_infrastructure" "infra" {
+  infra_name                = var.infra_name
+  cloud                     = 0
+  region                    = var.region
+  azcount                   = 2
+  enable_k8_cluster         = true
+  enable_ecs_cluster        = false
+  enable_container_insights = false
+  address_prefix            = "10.220.0.0/16"
+  subnet_cidr               = 22
+}
+
+resource "duplocloud_infrastructure_setting" "infra" {
+  infra_name = duplocloud_infrastructure.infra.infra_name
+  setting {
+    key   = "EnableAwsAlbIngress"
+    value = "true"
+  }
+  setting {
+    key   = "MaximumK8sSessionDuration"
+    value = "3600"
+  }
+}
+
+resource "duplocloud_infrastructure_subnet" "nonprod_a_db" {
+  name       = "nonprod-A-db"
+  infra_name = duplocloud_infrastructure.infra.infra_name
+  cidr_block = "10.220.200.0/24"
+  type       = "private"
+  zone       = "A"
+}
@@ -0,0 +1,16 @@
+output "infra_name" {
+  value       = duplocloud_infrastructure.infra.infra_name
+  description = "The duplo infra name."
+}
+output "region" {
+  value       = var.region
+  description = "The duplo plan region."
+}
+output "subnet_nonprod_a_db_id" {
+  value       = duplocloud_infrastructure_subnet.nonprod_a_db.subnet_id
+  description = "The ID of the nonprod-A-db subnet."
+}
+output "vpc_id" {
+  value       = duplocloud_infrastructure.infra.vpc_id
+  description = "The VPC or VNet ID."
+}
@@ -0,0 +1,16 @@
+terraform {
+  required_version = ">= 0.14.11"
+  required_providers {
+    duplocloud = {
+      source  = "duplocloud/duplocloud"
+      version = "~> 0.8.0"
+    }
+  }
+}
+provider "duplocloud" {
+
+}
+provider "aws" {
+  region = var.region
+
+}
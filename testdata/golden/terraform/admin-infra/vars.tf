@@ -0,0 +1,8 @@
+variable "infra_name" {
+  default = "nonprod"
+  type    = string
+}
+variable "region" {
+  default = "us-west-2"
+  type    = string
+}
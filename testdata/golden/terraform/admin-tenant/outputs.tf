@@ -0,0 +1,24 @@
+output "cert_arn" {
+  value       = var.cert_arn
+  description = "The duplo plan certificate arn."
+}
+output "infra_name" {
+  value       = data.duplocloud_infrastructure.infra.infra_name
+  description = "The duplo infra name."
+}
+output "region" {
+  value       = var.region
+  description = "The duplo plan region."
+}
+output "tenant_id" {
+  value       = duplocloud_tenant.tenant.tenant_id
+  description = "The tenant ID"
+}
+output "tenant_name" {
+  value       = duplocloud_tenant.tenant.account_name
+  description = "The tenant name"
+}
+output "vpc_id" {
+  value       = data.duplocloud_infrastructure.infra.vpc_id
+  description = "The VPC or VNet ID."
+}
@@ -0,0 +1,12 @@
+variable "cert_arn" {
+  default = "arn:aws:acm:us-west-2:100000000000:certificate/00000000-0000-0000-0000-000000000000"
+  type    = string
+}
+variable "infra_name" {
+  default = "nonprod"
+  type    = string
+}
+variable "region" {
+  default = "us-west-2"
+  type    = string
+}
@@ -0,0 +1,8 @@
+resource "duplocloud_k8_config_map" "app_config" {
+  tenant_id = local.tenant_id
+  name      = "app-config"
+  data = jsonencode({
+    "LOG_LEVEL" : "info"
+    }
+  )
+}
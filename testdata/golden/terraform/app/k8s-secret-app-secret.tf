@@ -0,0 +1,9 @@
+resource "duplocloud_k8_secret" "app_secret" {
+  tenant_id   = local.tenant_id
+  secret_name = "app-secret"
+  secret_type = "Opaque"
+  secret_data = jsonencode({
+    "API_KEY" : "c2VjcmV0"
+    }
+  )
+}
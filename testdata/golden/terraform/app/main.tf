@@ -0,0 +1,24 @@
+data "aws_caller_identity" "current" {
+}
+
+data "aws_region" "current" {
+}
+
+locals {
+  tfstate_bucket = "duplo-tfstate-${data.aws_caller_identity.current.account_id}"
+  region         = var.region
+  tenant_id      = data.terraform_remote_state.tenant.outputs["tenant_id"]
+  cert_arn       = data.terraform_remote_state.tenant.outputs["cert_arn"]
+  tenant_name    = data.terraform_remote_state.tenant.outputs["tenant_name"]
+}
+
+data "terraform_remote_state" "tenant" {
+  backend   = "s3"
+  workspace = terraform.workspace
+  config = {
+    bucket               = local.tfstate_bucket
+    workspace_key_prefix = "admin:"
+    key                  = "tenant"
+    region               = "us-west-2"
+  }
+}
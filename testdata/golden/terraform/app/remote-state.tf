@@ -0,0 +1,11 @@
+data "terraform_remote_state" "aws_services" {
+  backend   = "s3"
+  workspace = terraform.workspace
+  config = {
+    bucket               = local.tfstate_bucket
+    workspace_key_prefix = "tenant:"
+    key                  = "aws-services"
+    region               = "us-west-2"
+  }
+}
+
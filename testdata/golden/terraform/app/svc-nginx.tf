@@ -0,0 +1,107 @@
+resource "duplocloud_duplo_service" "nginx" {
+  tenant_id                            = local.tenant_id
+  name                                 = "nginx"
+  replicas                             = 2
+  lb_synced_deployment                 = false
+  cloud_creds_from_k8s_service_account = false
+  is_daemonset                         = false
+  agent_platform                       = 7
+  cloud                                = 0
+  other_docker_config = jsonencode({
+    "Env" : [
+      {
+        "Name" : "API_KEY",
+        "ValueFrom" : {
+          "SecretKeyRef" : {
+            "Key" : "API_KEY",
+            "Name" : duplocloud_k8_secret.app_secret.secret_name
+          }
+        }
+      },
+      {
+        "Name" : "QUEUE_URL",
+        "Value" : data.terraform_remote_state.aws_services.outputs["sqs_jobs_url"]
+      },
+      {
+        "Name" : "DB_HOST",
+        "Value" : data.terraform_remote_state.aws_services.outputs["rds_postgres_host"]
+      }
+    ],
+    "EnvFrom" : [
+      {
+        "ConfigMapRef" : {
+          "Name" : duplocloud_k8_config_map.app_config.name
+        }
+      }
+    ],
+    "ImagePullSecrets" : [
+      {
+        "Name" : duplocloud_k8_secret.app_secret.secret_name
+      }
+    ],
+    "initContainers" : [
+      {
+        "env" : [
+          {
+            "name" : "LOG_LEVEL",
+            "valueFrom" : {
+              "configMapKeyRef" : {
+                "key" : "LOG_LEVEL",
+                "name" : duplocloud_k8_config_map.app_config.name
+              }
+            }
+          }
+        ],
+        "image" : "nginx:1.23",
+        "name" : "migrate"
+      }
+    ]
+    }
+  )
+  docker_image = var.svc_nginx_docker_image
+  volumes = jsonencode([
+    {
+      "Name" : "config",
+      "Path" : "/etc/app",
+      "Spec" : {
+        "Projected" : {
+          "Sources" : [
+            {
+              "Secret" : {
+                "Name" : duplocloud_k8_secret.app_secret.secret_name
+              }
+            },
+            {
+              "ConfigMap" : {
+                "Name" : duplocloud_k8_config_map.app_config.name
+              }
+            }
+          ]
+        }
+      }
+    }
+    ]
+  )
+}
+
+resource "duplocloud_duplo_service_lbconfigs" "nginx_config" {
+  tenant_id                   = duplocloud_duplo_service.nginx.tenant_id
+  replication_controller_name = duplocloud_duplo_service.nginx.name
+  lbconfigs {
+    lb_type          = 1
+    is_native        = false
+    is_internal      = false
+    port             = 80
+    external_port    = 443
+    protocol         = "http"
+    health_check_url = "/health"
+    certificate_arn  = local.cert_arn
+  }
+}
+resource "duplocloud_duplo_service_params" "nginx_params" {
+  tenant_id                   = duplocloud_duplo_service_lbconfigs.nginx_config.tenant_id
+  replication_controller_name = duplocloud_duplo_service_lbconfigs.nginx_config.replication_controller_name
+  enable_access_logs          = true
+  drop_invalid_headers        = true
+  http_to_https_redirect      = true
+}
@@ -0,0 +1,8 @@
+variable "region" {
+  default = "us-west-2"
+  type    = string
+}
+variable "svc_nginx_docker_image" {
+  default = "nginx:1.23"
+  type    = string
+}
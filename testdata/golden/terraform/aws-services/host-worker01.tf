@@ -0,0 +1,13 @@
+resource "duplocloud_aws_host" "worker01" {
+  tenant_id           = local.tenant_id
+  friendly_name       = "worker01"
+  image_id            = var.host_worker01_image_id
+  capacity            = var.host_worker01_capacity
+  agent_platform      = 7
+  zone                = 0
+  is_minion           = true
+  is_ebs_optimized    = false
+  encrypt_disk        = false
+  allocated_public_ip = false
+  cloud               = 0
+}
@@ -0,0 +1,18 @@
+resource "duplocloud_aws_lambda_function" "worker" {
+  tenant_id    = local.tenant_id
+  name         = "worker"
+  description  = "Processes the jobs queue."
+  package_type = "Zip"
+  s3_bucket    = duplocloud_s3_bucket.assets.fullname
+  s3_key       = "lambda/worker.zip"
+  memory_size  = 256
+  timeout      = 60
+  handler      = "main.handler"
+  runtime      = "python3.9"
+  environment {
+    variables = {
+      JOBS_QUEUE_ARN = duplocloud_aws_sqs_queue.jobs.arn
+      RESULTS_PREFIX = "s3://${duplocloud_s3_bucket.assets.fullname}/results/"
+    }
+  }
+}
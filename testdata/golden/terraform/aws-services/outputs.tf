@@ -0,0 +1,72 @@
+output "host_worker01_instance_id" {
+  value       = duplocloud_aws_host.worker01.instance_id
+  description = "The AWS EC2 instance ID of the host."
+}
+output "host_worker01_private_ip_address" {
+  value       = duplocloud_aws_host.worker01.private_ip_address
+  description = "The primary private IP address assigned to the host."
+}
+output "lf_worker_arn" {
+  value       = duplocloud_aws_lambda_function.worker.arn
+  description = "The ARN of the lambda function."
+}
+output "lf_worker_fullname" {
+  value       = duplocloud_aws_lambda_function.worker.fullname
+  description = "The full name of the lambda function."
+}
+output "lf_worker_version" {
+  value       = duplocloud_aws_lambda_function.worker.version
+  description = "The version of the lambda function."
+}
+output "rds_postgres_arn" {
+  value       = duplocloud_rds_instance.postgres.arn
+  description = "The ARN of the RDS instance."
+}
+output "rds_postgres_endpoint" {
+  value       = duplocloud_rds_instance.postgres.endpoint
+  description = "The endpoint of the RDS instance."
+}
+output "rds_postgres_fullname" {
+  value       = duplocloud_rds_instance.postgres.identifier
+  description = "The full name of the RDS instance."
+}
+output "rds_postgres_host" {
+  value       = duplocloud_rds_instance.postgres.host
+  description = "The DNS hostname of the RDS instance."
+}
+output "rds_postgres_port" {
+  value       = duplocloud_rds_instance.postgres.port
+  description = "The listening port of the RDS instance."
+}
+output "redis_cache_arn" {
+  value       = duplocloud_ecache_instance.cache.arn
+  description = "The ARN of the elasticache instance."
+}
+output "redis_cache_endpoint" {
+  value       = duplocloud_ecache_instance.cache.endpoint
+  description = "The endpoint of the elasticache instance."
+}
+output "redis_cache_fullname" {
+  value       = duplocloud_ecache_instance.cache.identifier
+  description = "The full name of the elasticache instance."
+}
+output "redis_cache_host" {
+  value       = duplocloud_ecache_instance.cache.host
+  description = "The DNS hostname of the elasticache instance."
+}
+output "redis_cache_port" {
+  value       = duplocloud_ecache_instance.cache.port
+  description = "The listening port of the elasticache instance."
+}
+output "s3_assets_arn" {
+  value       = duplocloud_s3_bucket.assets.arn
+  description = "The ARN of the S3 bucket."
+}
+output "s3_assets_fullname" {
+  value       = duplocloud_s3_bucket.assets.fullname
+  description = "The full name of the S3 bucket."
+}
+output "sqs_jobs_url" {
+  value       = duplocloud_aws_sqs_queue.jobs.url
+  description = "The URL for the created Amazon SQS queue."
+}
@@ -0,0 +1,23 @@
+terraform {
+  required_version = ">= 0.14.11"
+  required_providers {
+    duplocloud = {
+      source  = "duplocloud/duplocloud"
+      version = "~> 0.8.0"
+    }
+    random = {
+      source  = "hashicorp/random"
+      version = "~> 3.3.2"
+    }
+  }
+}
+provider "duplocloud" {
+
+}
+provider "aws" {
+  region = var.region
+
+}
+provider "random" {
+
+}
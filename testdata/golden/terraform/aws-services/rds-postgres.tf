@@ -0,0 +1,16 @@
+resource "random_password" "postgres_password" {
+  length  = 16
+  special = false
+}
+resource "duplocloud_rds_instance" "postgres" {
+  tenant_id       = local.tenant_id
+  name            = "postgres-${local.tenant_name}"
+  engine          = 1
+  engine_version  = var.rds_postgres_engine_version
+  size            = var.rds_postgres_size
+  master_username = var.rds_postgres_master_username
+  master_password = random_password.postgres_password.result
+  encrypt_storage = var.rds_postgres_encrypt_storage
+  enable_logging  = false
+  multi_az        = false
+}
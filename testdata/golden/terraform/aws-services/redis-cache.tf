@@ -0,0 +1,9 @@
+resource "duplocloud_ecache_instance" "cache" {
+  tenant_id             = local.tenant_id
+  name                  = "cache-${local.tenant_name}"
+  cache_type            = 0
+  replicas              = var.redis_cache_replicas
+  size                  = var.redis_cache_size
+  encryption_at_rest    = true
+  encryption_in_transit = false
+}
@@ -0,0 +1,10 @@
+resource "duplocloud_s3_bucket" "assets" {
+  tenant_id           = local.tenant_id
+  name                = "assets"
+  allow_public_access = var.s3_assets_allow_public_access
+  enable_access_logs  = var.s3_assets_enable_access_logs
+  enable_versioning   = var.s3_assets_enable_versioning
+  default_encryption {
+    method = "Sse"
+  }
+}
@@ -0,0 +1,4 @@
+resource "duplocloud_aws_sqs_queue" "jobs" {
+  tenant_id = local.tenant_id
+  name      = "jobs"
+}
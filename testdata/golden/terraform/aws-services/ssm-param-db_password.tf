@@ -0,0 +1,7 @@
+resource "duplocloud_aws_ssm_parameter" "db_password" {
+  tenant_id   = local.tenant_id
+  name        = "db-password-${local.tenant_name}"
+  type        = "SecureString"
+  value       = "s3cr3t-db-pass"
+  description = "Password of the app database"
+}
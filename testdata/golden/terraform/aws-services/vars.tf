@@ -0,0 +1,48 @@
+variable "host_worker01_capacity" {
+  default = "t3.medium"
+  type    = string
+}
+variable "host_worker01_image_id" {
+  default = "ami-00000000000000001"
+  type    = string
+}
+variable "rds_postgres_encrypt_storage" {
+  default = true
+  type    = bool
+}
+variable "rds_postgres_engine_version" {
+  default = "13.7"
+  type    = string
+}
+variable "rds_postgres_master_username" {
+  default = "dbadmin"
+  type    = string
+}
+variable "rds_postgres_size" {
+  default = "db.t3.medium"
+  type    = string
+}
+variable "redis_cache_replicas" {
+  default = 1
+  type    = number
+}
+variable "redis_cache_size" {
+  default = "cache.t3.small"
+  type    = string
+}
+variable "region" {
+  default = "us-west-2"
+  type    = string
+}
+variable "s3_assets_allow_public_access" {
+  default = false
+  type    = bool
+}
+variable "s3_assets_enable_access_logs" {
+  default = false
+  type    = bool
+}
+variable "s3_assets_enable_versioning" {
+  default = true
+  type    = bool
+}
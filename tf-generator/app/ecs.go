@@ -1,13 +1,13 @@
 package app
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -19,36 +19,75 @@ import (
 type ECS struct {
 }
 
-func (ecs *ECS) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AppProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "ecs",
+		Project:       tfgenerator.ProjectApp,
+		ResourceTypes: []string{"duplocloud_ecs_task_definition", "duplocloud_ecs_service"},
+		DuploAPIs:     []string{"EcsServiceList", "EcsTaskDefinitionGet"},
+		DependsOn:     []string{"app-main"},
+		New:           func() tfgenerator.Generator { return &ECS{} },
+	})
+}
+
+// ecsService is an ECS service with its task definition.
+type ecsService struct {
+	Service        duplosdk.DuploEcsService
+	TaskDefinition *duplosdk.DuploEcsTaskDef
+}
 
+func (ecs *ECS) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.EcsServiceList(config.TenantId)
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	if list == nil {
+		return fetched.Set(nil)
+	}
+	taskDefs := make([]*duplosdk.DuploEcsTaskDef, len(*list))
+	fetchErrs := make([]error, len(*list))
+	err := common.ForEachParallel(ctx, config.Workers(), len(*list), func(i int) error {
+		taskDefObj, clientErr := client.EcsTaskDefinitionGet(config.TenantId, (*list)[i].TaskDefinition)
+		if clientErr != nil {
+			fetchErrs[i] = clientErr
+			return nil
+		}
+		taskDefs[i] = taskDefObj
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	services := []ecsService{}
+	for i, ecs := range *list {
+		if fetchErrs[i] != nil {
+			fetched.ObjectFailed("ecs service "+ecs.Name, fetchErrs[i])
+			continue
+		}
+		services = append(services, ecsService{Service: ecs, TaskDefinition: taskDefs[i]})
+	}
+	return fetched.Set(services)
+}
+
+func (ecs *ECS) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AppProject)
+
+	var list *[]ecsService
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
 	}
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== Duplo ECS TF generation started. =====>")
-		for _, ecs := range *list {
-
-			taskDefObj, clientErr := client.EcsTaskDefinitionGet(config.TenantId, ecs.TaskDefinition)
-			if clientErr != nil {
-				fmt.Println(clientErr)
-				return nil, clientErr
-			}
+		for _, svc := range *list {
+			ecs, taskDefObj := svc.Service, svc.TaskDefinition
 			// create new empty hcl file object
 			hclFile := hclwrite.NewEmptyFile()
 
 			// create new file on system
 			path := filepath.Join(workingDir, "ecs-"+ecs.Name+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			resourceName := common.GetResourceName(ecs.Name)
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
@@ -68,10 +107,7 @@ func (ecs *ECS) Generate(config *common.Config, client *duplosdk.Client) (*commo
 			})
 			// tdBody.SetAttributeValue("tenant_id",
 			// 	cty.StringVal(config.TenantId))
-			taskDefnName, err := extractTaskDefnName(client, config.TenantId, taskDefObj.Family)
-			if err != nil {
-				return nil, err
-			}
+			taskDefnName := extractTaskDefnName(config.DuploServicesPrefix(), taskDefObj.Family)
 			name := "duploservices-${local.tenant_name}-" + taskDefnName
 			tdNameTokens := hclwrite.Tokens{
 				{Type: hclsyntax.TokenOQuote, Bytes: []byte(`"`)},
@@ -98,26 +134,16 @@ func (ecs *ECS) Generate(config *common.Config, client *duplosdk.Client) (*commo
 					cty.ListVal(vals))
 			}
 			if taskDefObj.Volumes != nil && len(taskDefObj.Volumes) > 0 {
-				volString, err := duplosdk.JSONMarshal(taskDefObj.Volumes)
-				if err != nil {
-					panic(err)
+				if err := common.SetJSONEncodeAttribute(tdBody, "volumes", taskDefObj.Volumes); err != nil {
+					tfContext.ObjectFailed("ecs service "+ecs.Name, err)
+					continue
 				}
-				tdBody.SetAttributeTraversal("volumes", hcl.Traversal{
-					hcl.TraverseRoot{
-						Name: "jsonencode(" + volString + ")",
-					},
-				})
 			}
 			if taskDefObj.ContainerDefinitions != nil && len(taskDefObj.ContainerDefinitions) > 0 {
-				containerString, err := duplosdk.JSONMarshal(taskDefObj.ContainerDefinitions)
-				if err != nil {
-					panic(err)
+				if err := common.SetJSONEncodeAttribute(tdBody, "container_definitions", taskDefObj.ContainerDefinitions); err != nil {
+					tfContext.ObjectFailed("ecs service "+ecs.Name, err)
+					continue
 				}
-				tdBody.SetAttributeTraversal("container_definitions", hcl.Traversal{
-					hcl.TraverseRoot{
-						Name: "jsonencode(" + containerString + ")",
-					},
-				})
 			}
 			rootBody.AppendNewline()
 			log.Printf("[TRACE] Terraform config generated for duplo task definition : %s", taskDefObj.Family)
@@ -181,7 +207,6 @@ func (ecs *ECS) Generate(config *common.Config, client *duplosdk.Client) (*commo
 					cty.BoolVal(serviceConfig.IsInternal))
 				port, err := strconv.Atoi(serviceConfig.Port)
 				if err != nil {
-					fmt.Println(err)
 					return nil, err
 				}
 				lbConfigBlockBody.SetAttributeValue("port",
@@ -237,13 +262,11 @@ func (ecs *ECS) Generate(config *common.Config, client *duplosdk.Client) (*commo
 			}
 			//}
 
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_ecs_task_definition." + resourceName,
 					ResourceId:      "subscriptions/" + config.TenantId + "/EcsTaskDefinition/" + ecs.TaskDefinition,
@@ -263,11 +286,7 @@ func (ecs *ECS) Generate(config *common.Config, client *duplosdk.Client) (*commo
 	return &tfContext, nil
 }
 
-func extractTaskDefnName(client *duplosdk.Client, tenantID string, family string) (string, error) {
-	prefix, err := client.GetDuploServicesPrefix(tenantID)
-	if err != nil {
-		return "", err
-	}
+func extractTaskDefnName(prefix string, family string) string {
 	name, _ := duplosdk.UnprefixName(prefix, family)
-	return name, nil
+	return name
 }
@@ -1,12 +1,12 @@
 package app
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -19,14 +19,33 @@ const EXCLUDE_K8S_CONFIG_STR = "kube-root-ca.crt"
 type K8sConfig struct {
 }
 
-func (k8sConfig *K8sConfig) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AppProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "k8s-config-map",
+		Project:       tfgenerator.ProjectApp,
+		ResourceTypes: []string{"duplocloud_k8_config_map"},
+		DuploAPIs:     []string{"K8ConfigMapGetList"},
+		DependsOn:     []string{"app-main"},
+		New:           func() tfgenerator.Generator { return &K8sConfig{} },
+	})
+}
+
+func (k8sConfig *K8sConfig) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.K8ConfigMapGetList(config.TenantId)
-	exclude_k8s_config_list := strings.Split(EXCLUDE_K8S_CONFIG_STR, ",")
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, nil
+		return clientErr
 	}
+	return fetched.Set(list)
+}
+
+func (k8sConfig *K8sConfig) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AppProject)
+	var list *[]duplosdk.DuploK8sConfigMap
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
+	}
+	setConfigMapNames(list)
+	exclude_k8s_config_list := strings.Split(EXCLUDE_K8S_CONFIG_STR, ",")
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
@@ -49,11 +68,6 @@ func (k8sConfig *K8sConfig) Generate(config *common.Config, client *duplosdk.Cli
 
 			// create new file on system
 			path := filepath.Join(workingDir, "k8s-cm-"+k8sConfig.Name+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			resourceName := common.GetResourceName(k8sConfig.Name)
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
@@ -74,24 +88,17 @@ func (k8sConfig *K8sConfig) Generate(config *common.Config, client *duplosdk.Cli
 				cty.StringVal(k8sConfig.Name))
 
 			if len(k8sConfig.Data) > 0 {
-				configDataStr, err := duplosdk.JSONMarshal(k8sConfig.Data)
-				if err != nil {
-					panic(err)
+				if err := common.SetJSONEncodeAttribute(k8sConfigBody, "data", k8sConfig.Data); err != nil {
+					tfContext.ObjectFailed("k8s config map "+k8sConfig.Name, err)
+					continue
 				}
-				k8sConfigBody.SetAttributeTraversal("data", hcl.Traversal{
-					hcl.TraverseRoot{
-						Name: "jsonencode(" + configDataStr + ")",
-					},
-				})
 			}
 
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_k8_config_map." + resourceName,
@@ -107,3 +114,15 @@ func (k8sConfig *K8sConfig) Generate(config *common.Config, client *duplosdk.Cli
 
 	return &tfContext, nil
 }
+
+// setConfigMapNames sets the names of config maps decoded from a snapshot, the SDK synthesizes them from the metadata and does not encode them.
+func setConfigMapNames(list *[]duplosdk.DuploK8sConfigMap) {
+	if list == nil {
+		return
+	}
+	for i := range *list {
+		if name, ok := (*list)[i].Metadata["name"].(string); ok {
+			(*list)[i].Name = name
+		}
+	}
+}
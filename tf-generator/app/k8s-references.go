@@ -0,0 +1,63 @@
+package app
+
+import (
+	"strings"
+	"tenant-terraform-generator/duplosdk"
+	"tenant-terraform-generator/tf-generator/common"
+)
+
+// Kinds of the Kubernetes objects the specs of a duplo service refer to.
+const (
+	k8sSecretKind    = "secret"
+	k8sConfigMapKind = "configmap"
+)
+
+// k8sContainerRules are the paths of OtherDockerConfig naming a secret or a config map,
+// for the container of the service, its init containers and its additional containers.
+var k8sContainerRules = append(containerReferenceRules("", "InitContainers.*.", "AdditionalContainers.*."),
+	common.JSONReferenceRule{Path: "ImagePullSecrets.*.Name", Kind: k8sSecretKind},
+)
+
+// k8sVolumeRules are the paths of the Volumes of a duplo service naming a secret or a config map.
+var k8sVolumeRules = []common.JSONReferenceRule{
+	{Path: "*.Spec.Secret.SecretName", Kind: k8sSecretKind},
+	{Path: "*.Spec.ConfigMap.Name", Kind: k8sConfigMapKind},
+	{Path: "*.Spec.Projected.Sources.*.Secret.Name", Kind: k8sSecretKind},
+	{Path: "*.Spec.Projected.Sources.*.ConfigMap.Name", Kind: k8sConfigMapKind},
+}
+
+func containerReferenceRules(prefixes ...string) []common.JSONReferenceRule {
+	rules := []common.JSONReferenceRule{}
+	for _, prefix := range prefixes {
+		rules = append(rules,
+			common.JSONReferenceRule{Path: prefix + "Env.*.ValueFrom.SecretKeyRef.Name", Kind: k8sSecretKind},
+			common.JSONReferenceRule{Path: prefix + "Env.*.ValueFrom.ConfigMapKeyRef.Name", Kind: k8sConfigMapKind},
+			common.JSONReferenceRule{Path: prefix + "EnvFrom.*.SecretRef.Name", Kind: k8sSecretKind},
+			common.JSONReferenceRule{Path: prefix + "EnvFrom.*.ConfigMapRef.Name", Kind: k8sConfigMapKind},
+		)
+	}
+	return rules
+}
+
+// k8sReferences returns the references to the secrets and config maps generated in the app project,
+// the excluded ones are not generated and keep their name.
+func k8sReferences(secrets *[]duplosdk.DuploK8sSecret, configMaps *[]duplosdk.DuploK8sConfigMap) common.JSONReferences {
+	refs := common.JSONReferences{}
+	if secrets != nil {
+		excluded := strings.Split(EXCLUDE_K8S_SECRET_STR, ",")
+		for _, s := range *secrets {
+			if !isExcludedService(s.SecretName, excluded) {
+				refs.Add(k8sSecretKind, s.SecretName, "duplocloud_k8_secret."+common.GetResourceName(s.SecretName)+".secret_name")
+			}
+		}
+	}
+	if configMaps != nil {
+		excluded := strings.Split(EXCLUDE_K8S_CONFIG_STR, ",")
+		for _, c := range *configMaps {
+			if !isExcludedService(c.Name, excluded) {
+				refs.Add(k8sConfigMapKind, c.Name, "duplocloud_k8_config_map."+common.GetResourceName(c.Name)+".name")
+			}
+		}
+	}
+	return refs
+}
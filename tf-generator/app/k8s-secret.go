@@ -1,12 +1,13 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -19,14 +20,32 @@ const EXCLUDE_K8S_SECRET_STR = "default-token,duploservices-,filebeat-token-"
 type K8sSecret struct {
 }
 
-func (k8sSecret *K8sSecret) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AppProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "k8s-secret",
+		Project:       tfgenerator.ProjectApp,
+		ResourceTypes: []string{"duplocloud_k8_secret"},
+		DuploAPIs:     []string{"K8SecretGetList"},
+		DependsOn:     []string{"app-main"},
+		New:           func() tfgenerator.Generator { return &K8sSecret{} },
+	})
+}
+
+func (k8sSecret *K8sSecret) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.K8SecretGetList(config.TenantId)
-	exclude_k8s_secret_list := strings.Split(EXCLUDE_K8S_SECRET_STR, ",")
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, nil
+		return clientErr
+	}
+	return fetched.Set(list)
+}
+
+func (k8sSecret *K8sSecret) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AppProject)
+	var list *[]duplosdk.DuploK8sSecret
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
 	}
+	exclude_k8s_secret_list := strings.Split(EXCLUDE_K8S_SECRET_STR, ",")
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
@@ -49,11 +68,6 @@ func (k8sSecret *K8sSecret) Generate(config *common.Config, client *duplosdk.Cli
 
 			// create new file on system
 			path := filepath.Join(workingDir, "k8s-secret-"+k8sSecret.SecretName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			resourceName := common.GetResourceName(k8sSecret.SecretName)
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
@@ -83,25 +97,29 @@ func (k8sSecret *K8sSecret) Generate(config *common.Config, client *duplosdk.Cli
 				k8sSecretBody.SetAttributeValue("secret_annotations", cty.ObjectVal(newMap))
 			}
 
-			if len(k8sSecret.SecretData) > 0 {
-				secretDataStr, err := duplosdk.JSONMarshal(k8sSecret.SecretData)
-				if err != nil {
-					panic(err)
+			if len(k8sSecret.SecretData) > 0 && config.ExternalSecrets() {
+				data := make(map[string]cty.Value)
+				for key, element := range k8sSecret.SecretData {
+					data[key] = cty.StringVal(fmt.Sprint(element))
 				}
+				dataVar := tfContext.SensitiveVar("k8s_secret_"+resourceName+"_data", "Data of the k8s secret "+k8sSecret.SecretName+".", cty.MapVal(data))
 				k8sSecretBody.SetAttributeTraversal("secret_data", hcl.Traversal{
 					hcl.TraverseRoot{
-						Name: "jsonencode(" + secretDataStr + ")",
+						Name: "jsonencode(" + string(hclwrite.TokensForTraversal(dataVar).Bytes()) + ")",
 					},
 				})
+			} else if len(k8sSecret.SecretData) > 0 {
+				if err := common.SetJSONEncodeAttribute(k8sSecretBody, "secret_data", k8sSecret.SecretData); err != nil {
+					tfContext.ObjectFailed("k8s secret "+k8sSecret.SecretName, err)
+					continue
+				}
 			}
 
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_k8_secret." + resourceName,
 					ResourceId:      "v2/subscriptions/" + config.TenantId + "/K8SecretApiV2/" + k8sSecret.SecretName,
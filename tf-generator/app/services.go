@@ -1,14 +1,14 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"log"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -20,42 +20,149 @@ import (
 const SVC_VAR_PREFIX = "svc_"
 const EXCLUDE_SVC_STR = "duploinfrasvc,dockerservices-shell,system-svc-"
 
+// SVC_MODULE is the local module of a service, instantiated for each entry of the SVC_MODULE_VAR map with --modules.
+const SVC_MODULE = "duplo-service"
+const SVC_MODULE_VAR = "duplo_services"
+
+// svcLbConfigType, svcParamsType and svcSettingsType are the types of the settings variable of the module.
+// svcDocuments are the JSON documents of a service, given to the module by the module block instead, with the
+// references to the secrets, config maps and other resources they name.
+var svcLbConfigType = cty.Object(map[string]cty.Type{
+	"lb_type":          cty.Number,
+	"is_native":        cty.Bool,
+	"is_internal":      cty.Bool,
+	"port":             cty.Number,
+	"external_port":    cty.Number,
+	"protocol":         cty.String,
+	"health_check_url": cty.String,
+	"certificate":      cty.Bool,
+})
+
+var svcParamsType = cty.Object(map[string]cty.Type{
+	"dns_prfx":               cty.String,
+	"webaclid":               cty.String,
+	"enable_access_logs":     cty.Bool,
+	"drop_invalid_headers":   cty.Bool,
+	"http_to_https_redirect": cty.Bool,
+})
+
+var svcDocuments = []string{"other_docker_config", "extra_config", "other_docker_host_config", "hpa_specs", "volumes"}
+
+var svcSettingsType = cty.Object(map[string]cty.Type{
+	"replicas":                             cty.Number,
+	"lb_synced_deployment":                 cty.Bool,
+	"cloud_creds_from_k8s_service_account": cty.Bool,
+	"is_daemonset":                         cty.Bool,
+	"replicas_matching_asg_name":           cty.String,
+	"agent_platform":                       cty.Number,
+	"cloud":                                cty.Number,
+	"allocation_tags":                      cty.String,
+	"docker_image":                         cty.String,
+	"commands":                             cty.List(cty.String),
+	"lbconfigs":                            cty.List(svcLbConfigType),
+	"params":                               svcParamsType,
+})
+
 type Services struct {
 }
 
-func (s *Services) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AppProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "services",
+		Project:       tfgenerator.ProjectApp,
+		ResourceTypes: []string{"duplocloud_duplo_service", "duplocloud_duplo_service_lbconfigs", "duplocloud_duplo_service_params"},
+		DuploAPIs:     []string{"ReplicationControllerList", "ReplicationControllerLbConfigurationList", "ReplicationControllerLbWafGet", "TenantGetLbDetailsInService", "TenantGetApplicationLbSettings", "K8SecretGetList", "K8ConfigMapGetList"},
+		DependsOn:     []string{"app-main", "k8s-secret", "k8s-config-map"},
+		New:           func() tfgenerator.Generator { return &Services{} },
+	})
+}
+
+// servicesSnapshot holds the duplo objects rendered by the services generator.
+type servicesSnapshot struct {
+	// Services lists the replication controllers, without those which failed to fetch.
+	Services *[]duplosdk.DuploReplicationController
+	// Details maps the name of every service which is not excluded to its details.
+	Details map[string]serviceDetails
+	// K8sSecrets and ConfigMaps are used to reference the secrets and config maps of the app project, nil when they could not be read.
+	K8sSecrets *[]duplosdk.DuploK8sSecret
+	ConfigMaps *[]duplosdk.DuploK8sConfigMap
+}
+
+func (s *Services) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.ReplicationControllerList(config.TenantId)
 	exclude_svc_list := strings.Split(EXCLUDE_SVC_STR, ",")
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	data := servicesSnapshot{Details: map[string]serviceDetails{}}
+	if list == nil {
+		return fetched.Set(data)
+	}
+	// The specs of the services are written with the names of the secrets and config maps which could not be read
+	// instead of references.
+	k8sSecretList, clientErr := client.K8SecretGetList(config.TenantId)
+	if clientErr != nil {
+		fetched.ObjectFailed("k8s secrets of the service specs", clientErr)
+	} else {
+		data.K8sSecrets = k8sSecretList
+	}
+	configMapList, clientErr := client.K8ConfigMapGetList(config.TenantId)
+	if clientErr != nil {
+		fetched.ObjectFailed("k8s config maps of the service specs", clientErr)
+	} else {
+		data.ConfigMaps = configMapList
+	}
+	detailsList := make([]serviceDetails, len(*list))
+	fetchErrs := make([]error, len(*list))
+	err := common.ForEachParallel(ctx, config.Workers(), len(*list), func(i int) error {
+		if isExcludedService((*list)[i].Name, exclude_svc_list) {
+			return nil
+		}
+		detailsList[i], fetchErrs[i] = fetchServiceDetails(config.TenantId, &(*list)[i], client)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	services := []duplosdk.DuploReplicationController{}
+	for i, service := range *list {
+		if fetchErrs[i] != nil {
+			fetched.ObjectFailed("duplo service "+service.Name, fetchErrs[i])
+			continue
+		}
+		services = append(services, service)
+		if !isExcludedService(service.Name, exclude_svc_list) {
+			data.Details[service.Name] = detailsList[i]
+		}
+	}
+	data.Services = &services
+	return fetched.Set(data)
+}
+
+func (s *Services) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AppProject)
+	data := servicesSnapshot{}
+	if err := fetched.Decode(&data); err != nil {
+		return nil, err
+	}
+	list := data.Services
+	setConfigMapNames(data.ConfigMaps)
+	k8sRefs := k8sReferences(data.K8sSecrets, data.ConfigMaps)
+	exclude_svc_list := strings.Split(EXCLUDE_SVC_STR, ",")
+	if list != nil && config.Modules {
+		return renderServicesModule(config, data, k8sRefs)
 	}
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== Duplo Services TF generation started. =====>")
-		k8sSecretList, clientErr := client.K8SecretGetList(config.TenantId)
-		if clientErr != nil {
-			k8sSecretList = nil
-		}
-		configMapList, clientErr := client.K8ConfigMapGetList(config.TenantId)
-		if clientErr != nil {
-			configMapList = nil
-		}
 		for _, service := range *list {
 			log.Printf("[TRACE] Generating terraform config for duplo service : %s", service.Name)
-			skip := false
-			for _, element := range exclude_svc_list {
-				if strings.Contains(service.Name, element) {
-					log.Printf("[TRACE] Generating terraform config for duplo service : %s skipped.", service.Name)
-					skip = true
-					break
-				}
-			}
-			if skip {
+			if isExcludedService(service.Name, exclude_svc_list) {
+				log.Printf("[TRACE] Generating terraform config for duplo service : %s skipped.", service.Name)
 				continue
 			}
+			details := data.Details[service.Name]
 			resourceName := common.GetResourceName(service.Name)
 			varFullPrefix := SVC_VAR_PREFIX + resourceName + "_"
 			inputVars := generateSvcVars(service, varFullPrefix)
@@ -66,11 +173,6 @@ func (s *Services) Generate(config *common.Config, client *duplosdk.Client) (*co
 
 			// create new file on system
 			path := filepath.Join(workingDir, "svc-"+service.Name+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 			// Add duplocloud_aws_host resource
@@ -114,160 +216,44 @@ func (s *Services) Generate(config *common.Config, client *duplosdk.Client) (*co
 						cty.StringVal(service.Template.AllocationTags))
 				}
 				if len(service.Template.OtherDockerConfig) > 0 {
-					otherDockerConfigMap := make(map[string]interface{})
-					err := json.Unmarshal([]byte(service.Template.OtherDockerConfig), &otherDockerConfigMap)
+					var otherDockerConfig interface{}
+					err := json.Unmarshal([]byte(service.Template.OtherDockerConfig), &otherDockerConfig)
 					if err != nil {
-						panic(err)
+						tfContext.ObjectFailed("duplo service "+service.Name, err)
+						continue
 					}
-					if service.Template.AgentPlatform == 7 && k8sSecretList != nil {
-						for _, k8sSecret := range *k8sSecretList {
-							envFrom := otherDockerConfigMap["EnvFrom"]
-							if envFrom != nil {
-								envFromList := envFrom.([]interface{})
-								for _, result := range envFromList {
-									resultMap := result.(map[string]interface{})
-									if resultMap["SecretRef"] != nil {
-										secretRef := resultMap["SecretRef"].(map[string]interface{})
-										if secretRef["Name"] == k8sSecret.SecretName {
-											secretRef["Name"] = "${duplocloud_k8_secret." + common.GetResourceName(k8sSecret.SecretName) + ".secret_name}"
-											//break
-										} else if secretRef["name"] == k8sSecret.SecretName {
-											secretRef["name"] = "${duplocloud_k8_secret." + common.GetResourceName(k8sSecret.SecretName) + ".secret_name}"
-											//break
-										}
-									} else if resultMap["secretRef"] != nil {
-										secretRef := resultMap["secretRef"].(map[string]interface{})
-										if secretRef["Name"] == k8sSecret.SecretName {
-											secretRef["Name"] = "${duplocloud_k8_secret." + common.GetResourceName(k8sSecret.SecretName) + ".secret_name}"
-											//break
-										} else if secretRef["name"] == k8sSecret.SecretName {
-											secretRef["name"] = "${duplocloud_k8_secret." + common.GetResourceName(k8sSecret.SecretName) + ".secret_name}"
-											//break
-										}
-									}
-								}
-							}
-							env := otherDockerConfigMap["Env"]
-							if env != nil {
-								envList := env.([]interface{})
-								for _, result := range envList {
-									resultMap := result.(map[string]interface{})
-									if resultMap["ValueFrom"] != nil {
-										valueFrom := resultMap["ValueFrom"].(map[string]interface{})
-										if valueFrom["SecretKeyRef"] != nil {
-											secretKeyRef := valueFrom["SecretKeyRef"].(map[string]interface{})
-											if secretKeyRef["Name"] == k8sSecret.SecretName {
-												secretKeyRef["Name"] = "${duplocloud_k8_secret." + common.GetResourceName(k8sSecret.SecretName) + ".secret_name}"
-												//break
-											} else if secretKeyRef["name"] == k8sSecret.SecretName {
-												secretKeyRef["name"] = "${duplocloud_k8_secret." + common.GetResourceName(k8sSecret.SecretName) + ".secret_name}"
-												//break
-											}
-										} else if valueFrom["secretKeyRef"] != nil {
-											secretKeyRef := valueFrom["secretKeyRef"].(map[string]interface{})
-											if secretKeyRef["Name"] == k8sSecret.SecretName {
-												secretKeyRef["Name"] = "${duplocloud_k8_secret." + common.GetResourceName(k8sSecret.SecretName) + ".secret_name}"
-												//break
-											} else if secretKeyRef["name"] == k8sSecret.SecretName {
-												secretKeyRef["name"] = "${duplocloud_k8_secret." + common.GetResourceName(k8sSecret.SecretName) + ".secret_name}"
-												//break
-											}
-										}
-									}
-								}
-							}
-						}
-					}
-					if service.Template.AgentPlatform == 7 && configMapList != nil {
-						for _, k8sConfigMap := range *configMapList {
-							envFrom := otherDockerConfigMap["EnvFrom"]
-							if envFrom != nil {
-								envFromList := envFrom.([]interface{})
-								for _, result := range envFromList {
-									resultMap := result.(map[string]interface{})
-									if resultMap["ConfigMapRef"] != nil {
-										configMapRef := resultMap["ConfigMapRef"].(map[string]interface{})
-										if configMapRef["Name"] == k8sConfigMap.Name {
-											configMapRef["Name"] = "${duplocloud_k8_config_map." + common.GetResourceName(k8sConfigMap.Name) + ".name}"
-											//break
-										} else if configMapRef["name"] == k8sConfigMap.Name {
-											configMapRef["name"] = "${duplocloud_k8_config_map." + common.GetResourceName(k8sConfigMap.Name) + ".name}"
-											//break
-										}
-									} else if resultMap["configMapRef"] != nil {
-										configMapRef := resultMap["configMapRef"].(map[string]interface{})
-										if configMapRef["Name"] == k8sConfigMap.Name {
-											configMapRef["Name"] = "${duplocloud_k8_config_map." + common.GetResourceName(k8sConfigMap.Name) + ".name}"
-											//break
-										} else if configMapRef["name"] == k8sConfigMap.Name {
-											configMapRef["name"] = "${duplocloud_k8_config_map." + common.GetResourceName(k8sConfigMap.Name) + ".name}"
-											//break
-										}
-									}
-								}
-							}
-							env := otherDockerConfigMap["Env"]
-							if env != nil {
-								envList := env.([]interface{})
-								for _, result := range envList {
-									resultMap := result.(map[string]interface{})
-									if resultMap["ValueFrom"] != nil {
-										valueFrom := resultMap["ValueFrom"].(map[string]interface{})
-										if valueFrom["ConfigMapKeyRef"] != nil {
-											configMapKeyRef := valueFrom["ConfigMapKeyRef"].(map[string]interface{})
-											if configMapKeyRef["Name"] == k8sConfigMap.Name {
-												configMapKeyRef["Name"] = "${duplocloud_k8_config_map." + common.GetResourceName(k8sConfigMap.Name) + ".name}"
-												//break
-											}
-										}
-									}
-								}
-							}
-						}
+					if service.Template.AgentPlatform == 7 {
+						common.RewriteJSONReferences(otherDockerConfig, k8sContainerRules, k8sRefs)
 					}
-
-					otherDockerConfigStr, err := duplosdk.JSONMarshal(otherDockerConfigMap)
-					if err != nil {
-						panic(err)
+					if err := common.SetJSONEncodeAttribute(svcBody, "other_docker_config", otherDockerConfig); err != nil {
+						tfContext.ObjectFailed("duplo service "+service.Name, err)
+						continue
 					}
-					svcBody.SetAttributeTraversal("other_docker_config", hcl.Traversal{
-						hcl.TraverseRoot{
-							Name: "jsonencode(" + otherDockerConfigStr + ")",
-						},
-					})
 				}
 				if len(service.Template.ExtraConfig) > 0 {
 					var extraConfigMap interface{}
 					log.Printf("[TRACE] ExtraConfig *** : %s", service.Template.ExtraConfig)
 					err := json.Unmarshal([]byte(service.Template.ExtraConfig), &extraConfigMap)
 					if err != nil {
-						panic(err)
+						tfContext.ObjectFailed("duplo service "+service.Name, err)
+						continue
 					}
-					extraConfigStr, err := duplosdk.JSONMarshal(extraConfigMap)
-					if err != nil {
-						panic(err)
+					if err := common.SetJSONEncodeAttribute(svcBody, "extra_config", extraConfigMap); err != nil {
+						tfContext.ObjectFailed("duplo service "+service.Name, err)
+						continue
 					}
-					svcBody.SetAttributeTraversal("extra_config", hcl.Traversal{
-						hcl.TraverseRoot{
-							Name: "jsonencode(" + extraConfigStr + ")",
-						},
-					})
 				}
 				if len(service.Template.OtherDockerHostConfig) > 0 {
 					OtherDockerHostConfigMap := make(map[string]interface{})
 					err := json.Unmarshal([]byte(service.Template.OtherDockerHostConfig), &OtherDockerHostConfigMap)
 					if err != nil {
-						panic(err)
+						tfContext.ObjectFailed("duplo service "+service.Name, err)
+						continue
 					}
-					OtherDockerHostConfigStr, err := duplosdk.JSONMarshal(OtherDockerHostConfigMap)
-					if err != nil {
-						panic(err)
+					if err := common.SetJSONEncodeAttribute(svcBody, "other_docker_host_config", OtherDockerHostConfigMap); err != nil {
+						tfContext.ObjectFailed("duplo service "+service.Name, err)
+						continue
 					}
-					svcBody.SetAttributeTraversal("other_docker_host_config", hcl.Traversal{
-						hcl.TraverseRoot{
-							Name: "jsonencode(" + OtherDockerHostConfigStr + ")",
-						},
-					})
 				}
 
 				if service.Template.Commands != nil && len(service.Template.Commands) > 0 {
@@ -293,80 +279,32 @@ func (s *Services) Generate(config *common.Config, client *duplosdk.Client) (*co
 				}
 
 				if len(service.HPASpecs) > 0 {
-					hpaSpecsStr, err := duplosdk.JSONMarshal(service.HPASpecs)
-					if err != nil {
-						panic(err)
+					if err := common.SetJSONEncodeAttribute(svcBody, "hpa_specs", service.HPASpecs); err != nil {
+						tfContext.ObjectFailed("duplo service "+service.Name, err)
+						continue
 					}
-					svcBody.SetAttributeTraversal("hpa_specs", hcl.Traversal{
-						hcl.TraverseRoot{
-							Name: "jsonencode(" + hpaSpecsStr + ")",
-						},
-					})
 				}
 
 				if len(service.Template.Volumes) > 0 {
-					//log.Printf("[TRACE] Volume : %s", service.Template.Volumes)
-					//volConfigMap := make(map[string]interface{})
 					var volConfigMapList []interface{}
-					// log.Printf("[TRACE] Vol *** : %s", service.Template.Volumes)
 					err := json.Unmarshal([]byte(service.Template.Volumes), &volConfigMapList)
 					if err != nil {
-						panic(err)
+						tfContext.ObjectFailed("duplo service "+service.Name, err)
+						continue
 					}
-					if service.Template.AgentPlatform == 7 && k8sSecretList != nil {
-						for _, k8sSecret := range *k8sSecretList {
-							for _, result := range volConfigMapList {
-								volMap := result.(map[string]interface{})
-								if volMap["Spec"] != nil {
-									spec := volMap["Spec"].(map[string]interface{})
-									if spec["Secret"] != nil {
-										secretMap := spec["Secret"].(map[string]interface{})
-										if secretMap["SecretName"] == k8sSecret.SecretName {
-											secretMap["SecretName"] = "${duplocloud_k8_secret." + common.GetResourceName(k8sSecret.SecretName) + ".secret_name}"
-											// break
-										}
-									}
-								}
-							}
-						}
+					if service.Template.AgentPlatform == 7 {
+						common.RewriteJSONReferences(volConfigMapList, k8sVolumeRules, k8sRefs)
 					}
-					if service.Template.AgentPlatform == 7 && configMapList != nil {
-						for _, k8sConfigMap := range *configMapList {
-							for _, result := range volConfigMapList {
-								volMap := result.(map[string]interface{})
-								if volMap["Spec"] != nil {
-									spec := volMap["Spec"].(map[string]interface{})
-									if spec["ConfigMap"] != nil {
-										configMap := spec["ConfigMap"].(map[string]interface{})
-										if configMap["Name"] == k8sConfigMap.Name {
-											configMap["Name"] = "${duplocloud_k8_config_map." + common.GetResourceName(k8sConfigMap.Name) + ".name}"
-											// break
-										}
-									}
-								}
-							}
-						}
-					}
-					volConfigMapStr, err := duplosdk.JSONMarshal(volConfigMapList)
-					if err != nil {
-						panic(err)
+					if err := common.SetJSONEncodeAttribute(svcBody, "volumes", volConfigMapList); err != nil {
+						tfContext.ObjectFailed("duplo service "+service.Name, err)
+						continue
 					}
-
-					svcBody.SetAttributeTraversal("volumes", hcl.Traversal{
-						hcl.TraverseRoot{
-							Name: "jsonencode(" + volConfigMapStr + ")",
-						},
-					})
 				}
 
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo service : %s", service.Name)
 			rootBody.AppendNewline()
-			configList, clientErr := client.ReplicationControllerLbConfigurationList(config.TenantId, service.Name)
-			if clientErr != nil {
-				fmt.Println(clientErr)
-				return nil, clientErr
-			}
+			configList := details.ConfigList
 			configPresent := false
 			if configList != nil && len(*configList) > 0 {
 				configPresent = true
@@ -402,7 +340,6 @@ func (s *Services) Generate(config *common.Config, client *duplosdk.Client) (*co
 						cty.BoolVal(serviceConfig.IsInternal))
 					port, err := strconv.Atoi(serviceConfig.Port)
 					if err != nil {
-						fmt.Println(err)
 						return nil, err
 					}
 					lbConfigBlockBody.SetAttributeValue("port",
@@ -461,32 +398,15 @@ func (s *Services) Generate(config *common.Config, client *duplosdk.Client) (*co
 					}
 
 					if doesReplicationControllerHaveAlb(&service) {
-						webAclId, clientError := client.ReplicationControllerLbWafGet(config.TenantId, service.Name)
-						if clientError != nil {
-							if clientError.Status() == 500 && service.Template.Cloud != 0 {
-								log.Printf("[TRACE] Ignoring error %s for non AWS cloud.", clientError)
-							}
-							webAclId = ""
-						}
+						webAclId := details.WebAclId
 						if len(webAclId) > 0 {
 							svcParamBody.SetAttributeValue("webaclid",
 								cty.StringVal(webAclId))
 						}
 					}
-					isError := false
 					if doesReplicationControllerHaveAlbOrNlb(&service) {
-						details, err := getDuploServiceAwsLbSettings(config.TenantId, &service, client)
-						if details == nil || err != nil {
-							isError = true
-						}
-						var settings *duplosdk.DuploAwsLbSettings
-						if !isError {
-							settings, err = client.TenantGetApplicationLbSettings(config.TenantId, details.LoadBalancerArn)
-						}
-
-						if err != nil {
-							isError = true
-						}
+						settings := details.LbSettings
+						isError := details.LbSettingsErr
 						if settings != nil && settings.LoadBalancerArn != "" {
 							svcParamBody.SetAttributeValue("enable_access_logs",
 								cty.BoolVal(settings.EnableAccessLogs))
@@ -506,13 +426,11 @@ func (s *Services) Generate(config *common.Config, client *duplosdk.Client) (*co
 				}
 			}
 
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_duplo_service." + resourceName,
@@ -542,6 +460,265 @@ func (s *Services) Generate(config *common.Config, client *duplosdk.Client) (*co
 	return &tfContext, nil
 }
 
+// renderServicesModule writes the services as the entries of the duplo_services variable, for each of which the
+// project instantiates the duplo-service module. The JSON documents of the services are given by the module block,
+// where they refer to the secrets and config maps they use and get the references to the other resources.
+func renderServicesModule(config *common.Config, data servicesSnapshot, k8sRefs common.JSONReferences) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AppProject)
+	exclude_svc_list := strings.Split(EXCLUDE_SVC_STR, ",")
+	tfContext := common.TFContext{}
+	entries := map[string]cty.Value{}
+	documents := []tfgenerator.ObjectAttrTokens{}
+	log.Println("[TRACE] <====== Duplo Services module TF generation started. =====>")
+	for _, service := range *data.Services {
+		if isExcludedService(service.Name, exclude_svc_list) {
+			log.Printf("[TRACE] Generating terraform module settings for duplo service : %s skipped.", service.Name)
+			continue
+		}
+		log.Printf("[TRACE] Generating terraform module settings for duplo service : %s", service.Name)
+		details := data.Details[service.Name]
+		settings, err := serviceModuleSettings(config, service, details)
+		if err != nil {
+			tfContext.ObjectFailed("duplo service "+service.Name, err)
+			continue
+		}
+		serviceDocuments, err := serviceModuleDocuments(service, k8sRefs)
+		if err != nil {
+			tfContext.ObjectFailed("duplo service "+service.Name, err)
+			continue
+		}
+		entries[service.Name] = settings
+		if serviceDocuments != nil {
+			documents = append(documents, tfgenerator.ObjectAttrTokens{
+				Name:  hclwrite.TokensForValue(cty.StringVal(service.Name)),
+				Value: serviceDocuments,
+			})
+		}
+
+		if config.ImportsEnabled() {
+			address := tfgenerator.ModuleAddress(SVC_MODULE_VAR, service.Name)
+			tfContext.ImportConfigs = append(tfContext.ImportConfigs, common.ImportConfig{
+				ResourceAddress: address + ".duplocloud_duplo_service.this",
+				ResourceId:      "v2/subscriptions/" + config.TenantId + "/ReplicationControllerApiV2/" + service.Name,
+				WorkingDir:      workingDir,
+			})
+			if settings.GetAttr("lbconfigs").LengthInt() > 0 {
+				tfContext.ImportConfigs = append(tfContext.ImportConfigs, common.ImportConfig{
+					ResourceAddress: address + ".duplocloud_duplo_service_lbconfigs.this[0]",
+					ResourceId:      "v2/subscriptions/" + config.TenantId + "/ServiceLBConfigsV2/" + service.Name,
+					WorkingDir:      workingDir,
+				})
+			}
+			if !settings.GetAttr("params").IsNull() {
+				tfContext.ImportConfigs = append(tfContext.ImportConfigs, common.ImportConfig{
+					ResourceAddress: address + ".duplocloud_duplo_service_params.this[0]",
+					ResourceId:      "v2/subscriptions/" + config.TenantId + "/ReplicationControllerParamsV2/" + service.Name,
+					WorkingDir:      workingDir,
+				})
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return &tfContext, nil
+	}
+	tfContext.InputVars = append(tfContext.InputVars, tfgenerator.MapVar(SVC_MODULE_VAR, svcSettingsType, entries, "The settings of the duplo services, by name."))
+
+	if err := tfgenerator.WriteModule(config, SVC_MODULE); err != nil {
+		return nil, err
+	}
+	hclFile := hclwrite.NewEmptyFile()
+	moduleBody := tfgenerator.AppendModuleBlock(hclFile.Body(), SVC_MODULE_VAR, SVC_MODULE, []tfgenerator.ModuleAttr{
+		{Name: "tenant_id", Value: tfgenerator.LocalTraversal("tenant_id")},
+		{Name: "tenant_name", Value: tfgenerator.LocalTraversal("tenant_name")},
+		{Name: "cert_arn", Value: tfgenerator.LocalTraversal("cert_arn")},
+	})
+	if len(documents) > 0 {
+		// The services added to the variable later have no documents.
+		moduleBody.SetAttributeRaw("documents", tokensForEntry(tfgenerator.TokensForObject(documents)))
+	}
+	if err := config.Output.WriteFile(filepath.Join(workingDir, "duplo-services.tf"), hclFile.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	log.Println("[TRACE] <====== Duplo Services module TF generation done. =====>")
+	return &tfContext, nil
+}
+
+// serviceModuleSettings returns the entry of a service in the duplo_services variable, the attributes the resources
+// do not set are null.
+func serviceModuleSettings(config *common.Config, service duplosdk.DuploReplicationController, details serviceDetails) (cty.Value, error) {
+	attrs := map[string]cty.Value{
+		"replicas":                             cty.NumberIntVal(int64(service.Replicas)),
+		"lb_synced_deployment":                 cty.BoolVal(service.IsLBSyncedDeployment),
+		"cloud_creds_from_k8s_service_account": cty.BoolVal(service.IsCloudCredsFromK8sServiceAccount),
+		"is_daemonset":                         cty.BoolVal(service.IsDaemonset),
+		"replicas_matching_asg_name":           stringOrNull(service.ReplicasMatchingAsgName),
+	}
+	for name, t := range svcSettingsType.AttributeTypes() {
+		if _, ok := attrs[name]; !ok {
+			attrs[name] = cty.NullVal(t)
+		}
+	}
+	attrs["lbconfigs"] = cty.ListValEmpty(svcLbConfigType)
+	if template := service.Template; template != nil {
+		attrs["agent_platform"] = cty.NumberIntVal(int64(template.AgentPlatform))
+		attrs["cloud"] = cty.NumberIntVal(int64(template.Cloud))
+		attrs["allocation_tags"] = stringOrNull(template.AllocationTags)
+		if template.Containers != nil && len(*template.Containers) > 0 {
+			attrs["docker_image"] = cty.StringVal((*template.Containers)[0].Image)
+		}
+		if len(template.Commands) > 0 {
+			var vals []cty.Value
+			for _, cmd := range template.Commands {
+				vals = append(vals, cty.StringVal(cmd))
+			}
+			attrs["commands"] = cty.ListVal(vals)
+		}
+	}
+
+	if details.ConfigList != nil && len(*details.ConfigList) > 0 {
+		lbConfigs := []cty.Value{}
+		for _, serviceConfig := range *details.ConfigList {
+			port, err := strconv.Atoi(serviceConfig.Port)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			lbConfigs = append(lbConfigs, cty.ObjectVal(map[string]cty.Value{
+				"lb_type":          cty.NumberIntVal(int64(serviceConfig.LbType)),
+				"is_native":        cty.BoolVal(serviceConfig.IsNative),
+				"is_internal":      cty.BoolVal(serviceConfig.IsInternal),
+				"port":             cty.NumberIntVal(int64(port)),
+				"external_port":    cty.NumberIntVal(int64(serviceConfig.ExternalPort)),
+				"protocol":         cty.StringVal(serviceConfig.Protocol),
+				"health_check_url": stringOrNull(serviceConfig.HealthCheckURL),
+				"certificate":      cty.BoolVal(len(serviceConfig.CertificateArn) > 0),
+			}))
+		}
+		attrs["lbconfigs"] = cty.ListVal(lbConfigs)
+		if doesReplicationControllerHaveAlbOrNlb(&service) {
+			params := map[string]cty.Value{
+				"dns_prfx":               cty.NullVal(cty.String),
+				"webaclid":               cty.NullVal(cty.String),
+				"enable_access_logs":     cty.NullVal(cty.Bool),
+				"drop_invalid_headers":   cty.NullVal(cty.Bool),
+				"http_to_https_redirect": cty.NullVal(cty.Bool),
+			}
+			// The module appends the name of the tenant, like the prefix written without module.
+			if len(service.DnsPrfx) > 0 {
+				params["dns_prfx"] = cty.StringVal(strings.Replace(service.DnsPrfx, "-"+config.TenantName, "", -1))
+			}
+			if doesReplicationControllerHaveAlb(&service) {
+				params["webaclid"] = stringOrNull(details.WebAclId)
+			}
+			if settings := details.LbSettings; settings != nil && settings.LoadBalancerArn != "" {
+				params["enable_access_logs"] = cty.BoolVal(settings.EnableAccessLogs)
+				params["drop_invalid_headers"] = cty.BoolVal(settings.DropInvalidHeaders)
+				params["http_to_https_redirect"] = cty.BoolVal(settings.HttpToHttpsRedirect)
+			} else if details.LbSettingsErr {
+				params["enable_access_logs"] = cty.False
+				params["drop_invalid_headers"] = cty.False
+				params["http_to_https_redirect"] = cty.False
+			}
+			attrs["params"] = cty.ObjectVal(params)
+		}
+	}
+	return cty.ObjectVal(attrs), nil
+}
+
+// serviceModuleDocuments returns the object of the JSON documents of a service given to its module, like the
+// resource of the service sets them, or nil when it has none.
+func serviceModuleDocuments(service duplosdk.DuploReplicationController, k8sRefs common.JSONReferences) (hclwrite.Tokens, error) {
+	template := service.Template
+	if template == nil {
+		return nil, nil
+	}
+	docs := map[string]interface{}{}
+	if len(template.OtherDockerConfig) > 0 {
+		var otherDockerConfig interface{}
+		if err := json.Unmarshal([]byte(template.OtherDockerConfig), &otherDockerConfig); err != nil {
+			return nil, err
+		}
+		if template.AgentPlatform == 7 {
+			common.RewriteJSONReferences(otherDockerConfig, k8sContainerRules, k8sRefs)
+		}
+		docs["other_docker_config"] = otherDockerConfig
+	}
+	if len(template.ExtraConfig) > 0 {
+		var extraConfigMap interface{}
+		if err := json.Unmarshal([]byte(template.ExtraConfig), &extraConfigMap); err != nil {
+			return nil, err
+		}
+		docs["extra_config"] = extraConfigMap
+	}
+	if len(template.OtherDockerHostConfig) > 0 {
+		otherDockerHostConfigMap := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(template.OtherDockerHostConfig), &otherDockerHostConfigMap); err != nil {
+			return nil, err
+		}
+		docs["other_docker_host_config"] = otherDockerHostConfigMap
+	}
+	if len(service.HPASpecs) > 0 {
+		docs["hpa_specs"] = service.HPASpecs
+	}
+	if len(template.Volumes) > 0 {
+		var volConfigMapList []interface{}
+		if err := json.Unmarshal([]byte(template.Volumes), &volConfigMapList); err != nil {
+			return nil, err
+		}
+		if template.AgentPlatform == 7 {
+			common.RewriteJSONReferences(volConfigMapList, k8sVolumeRules, k8sRefs)
+		}
+		docs["volumes"] = volConfigMapList
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	// Every attribute is set, the module variable is an object.
+	attrs := []tfgenerator.ObjectAttrTokens{}
+	for _, name := range svcDocuments {
+		value := hclwrite.TokensForValue(cty.NullVal(cty.String))
+		if doc, ok := docs[name]; ok {
+			tokens, err := common.JSONEncodeTokens(doc)
+			if err != nil {
+				return nil, err
+			}
+			value = tokens
+		}
+		attrs = append(attrs, tfgenerator.ObjectAttrTokens{
+			Name:  hclwrite.TokensForTraversal(hcl.Traversal{hcl.TraverseRoot{Name: name}}),
+			Value: value,
+		})
+	}
+	return tfgenerator.TokensForObject(attrs), nil
+}
+
+// tokensForEntry returns try(<object>[each.key], null), the attribute of object for the instance of a module.
+func tokensForEntry(object hclwrite.Tokens) hclwrite.Tokens {
+	tokens := hclwrite.Tokens{
+		{Type: hclsyntax.TokenIdent, Bytes: []byte("try")},
+		{Type: hclsyntax.TokenOParen, Bytes: []byte("(")},
+	}
+	tokens = append(tokens, object...)
+	tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenOBrack, Bytes: []byte("[")})
+	tokens = append(tokens, hclwrite.TokensForTraversal(hcl.Traversal{
+		hcl.TraverseRoot{Name: "each"},
+		hcl.TraverseAttr{Name: "key"},
+	})...)
+	tokens = append(tokens,
+		&hclwrite.Token{Type: hclsyntax.TokenCBrack, Bytes: []byte("]")},
+		&hclwrite.Token{Type: hclsyntax.TokenComma, Bytes: []byte(",")},
+		&hclwrite.Token{Type: hclsyntax.TokenIdent, Bytes: []byte("null"), SpacesBefore: 1},
+		&hclwrite.Token{Type: hclsyntax.TokenCParen, Bytes: []byte(")")},
+	)
+	return tokens
+}
+
+func stringOrNull(s string) cty.Value {
+	if len(s) == 0 {
+		return cty.NullVal(cty.String)
+	}
+	return cty.StringVal(s)
+}
+
 func generateSvcVars(duplo duplosdk.DuploReplicationController, prefix string) []common.VarConfig {
 	varConfigs := make(map[string]common.VarConfig)
 
@@ -570,6 +747,60 @@ func doesReplicationControllerHaveAlb(duplo *duplosdk.DuploReplicationController
 	return false
 }
 
+// serviceDetails holds what is fetched for a duplo service besides the replication controller itself.
+type serviceDetails struct {
+	ConfigList *[]duplosdk.DuploLbConfiguration
+	WebAclId   string
+	LbSettings *duplosdk.DuploAwsLbSettings
+	// LbSettingsErr is set when the load balancer settings could not be read, they are not rendered then.
+	LbSettingsErr bool
+}
+
+func isExcludedService(name string, excludeList []string) bool {
+	for _, element := range excludeList {
+		if strings.Contains(name, element) {
+			return true
+		}
+	}
+	return false
+}
+
+func fetchServiceDetails(tenantID string, service *duplosdk.DuploReplicationController, client *duplosdk.Client) (serviceDetails, error) {
+	details := serviceDetails{}
+	configList, clientErr := client.ReplicationControllerLbConfigurationList(tenantID, service.Name)
+	if clientErr != nil {
+		return details, clientErr
+	}
+	details.ConfigList = configList
+	if configList == nil || len(*configList) == 0 {
+		return details, nil
+	}
+	if doesReplicationControllerHaveAlb(service) {
+		webAclId, clientError := client.ReplicationControllerLbWafGet(tenantID, service.Name)
+		if clientError != nil {
+			if clientError.Status() == 500 && service.Template.Cloud != 0 {
+				log.Printf("[TRACE] Ignoring error %s for non AWS cloud.", clientError)
+			}
+			webAclId = ""
+		}
+		details.WebAclId = webAclId
+	}
+	if doesReplicationControllerHaveAlbOrNlb(service) {
+		lbDetails, err := getDuploServiceAwsLbSettings(tenantID, service, client)
+		if lbDetails == nil || err != nil {
+			details.LbSettingsErr = true
+			return details, nil
+		}
+		settings, clientErr := client.TenantGetApplicationLbSettings(tenantID, lbDetails.LoadBalancerArn)
+		if clientErr != nil {
+			details.LbSettingsErr = true
+			return details, nil
+		}
+		details.LbSettings = settings
+	}
+	return details, nil
+}
+
 func getDuploServiceAwsLbSettings(tenantID string, rpc *duplosdk.DuploReplicationController, c *duplosdk.Client) (*duplosdk.DuploAwsLbDetailsInService, error) {
 
 	if rpc.Template != nil && rpc.Template.Cloud == 0 {
@@ -1,11 +1,11 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -16,21 +16,39 @@ import (
 type ApiGatewayIntegration struct {
 }
 
-func (agi *ApiGatewayIntegration) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
-	list, clientErr := client.TenantGetApplicationApiGatewayList(config.TenantId)
-	//Get tenant from duplo
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "api-gateway-integration",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_api_gateway_integration"},
+		DuploAPIs:     []string{"TenantGetApplicationApiGatewayList"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &ApiGatewayIntegration{} },
+	})
+}
 
+func (agi *ApiGatewayIntegration) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
+	list, clientErr := client.TenantGetApplicationApiGatewayList(config.TenantId)
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	return fetched.Set(list)
+}
+
+func (agi *ApiGatewayIntegration) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]duplosdk.DuploApiGatewayResource
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
 	}
+	//Get tenant from duplo
+
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== Api Gateway Integration TF generation started. =====>")
 		for _, agi := range *list {
-			shortName, _ := extractAGIName(client, config.TenantId, agi.Name)
+			shortName := extractAGIName(config.DuploServicesPrefix(), agi.Name)
 			resourceName := common.GetResourceName(shortName)
 			log.Printf("[TRACE] Generating terraform config for duplo Api Gateway Integration : %s", shortName)
 
@@ -39,11 +57,6 @@ func (agi *ApiGatewayIntegration) Generate(config *common.Config, client *duplos
 
 			// create new file on system
 			path := filepath.Join(workingDir, "agi-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
@@ -68,15 +81,13 @@ func (agi *ApiGatewayIntegration) Generate(config *common.Config, client *duplos
 			// 	cty.StringVal(ssmParam.Type))
 
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo Api Gateway Integration : %s", shortName)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_api_gateway_integration." + resourceName,
 					ResourceId:      config.TenantId + "/" + shortName,
@@ -91,11 +102,7 @@ func (agi *ApiGatewayIntegration) Generate(config *common.Config, client *duplos
 	return &tfContext, nil
 }
 
-func extractAGIName(client *duplosdk.Client, tenantID string, fullName string) (string, error) {
-	prefix, err := client.GetDuploServicesPrefix(tenantID)
-	if err != nil {
-		return "", err
-	}
+func extractAGIName(prefix string, fullName string) string {
 	name, _ := duplosdk.UnprefixName(prefix, fullName)
-	return name, nil
+	return name
 }
@@ -1,12 +1,12 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"strconv"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -19,15 +19,33 @@ const ASG_VAR_PREFIX = "asg_"
 type ASG struct {
 }
 
-func (asg *ASG) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
-	list, clientErr := client.AsgProfileGetList(config.TenantId)
-	//Get tenant from duplo
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "asg",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_asg_profile"},
+		DuploAPIs:     []string{"AsgProfileGetList"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &ASG{} },
+	})
+}
 
+func (asg *ASG) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
+	list, clientErr := client.AsgProfileGetList(config.TenantId)
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
 	}
+	return fetched.Set(list)
+}
+
+func (asg *ASG) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]duplosdk.DuploAsgProfile
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
+	}
+	//Get tenant from duplo
+
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
@@ -44,11 +62,6 @@ func (asg *ASG) Generate(config *common.Config, client *duplosdk.Client) (*commo
 			// create new file on system
 
 			path := filepath.Join(workingDir, "asg-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -172,17 +185,18 @@ func (asg *ASG) Generate(config *common.Config, client *duplosdk.Client) (*commo
 			}
 			// TODO - Handle tags, network_interface
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo ASG : %s", asgProfile.FriendlyName)
 
 			outVars := generateAsgOutputVars(asgProfile, varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_asg_profile."+resourceName, map[string]string{
+				"fullname": asgProfile.FriendlyName,
+			})...)
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_asg_profile." + resourceName,
 					ResourceId:      config.TenantId + "/" + asgProfile.FriendlyName,
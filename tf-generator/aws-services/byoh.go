@@ -1,11 +1,11 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -18,20 +18,64 @@ const BYOH_VAR_PREFIX = "byoh_"
 type BYOH struct {
 }
 
-func (byoh *BYOH) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "byoh",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_byoh"},
+		DuploAPIs:     []string{"TenantByohList", "TenantHostCredentialsGet"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &BYOH{} },
+	})
+}
+
+// byohHost is a BYOH host with its credentials, nil when they could not be read.
+type byohHost struct {
+	Host       duplosdk.DuploMinion
+	Credential *duplosdk.DuploHostCredential
+}
+
+func (byoh *BYOH) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.TenantByohList(config.TenantId)
 	//Get tenant from duplo
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	if list == nil {
+		return fetched.Set(nil)
+	}
+	hosts := make([]byohHost, len(*list))
+	err := common.ForEachParallel(ctx, config.Workers(), len(*list), func(i int) error {
+		cred, err := client.TenantHostCredentialsGet(config.TenantId, duplosdk.DuploHostOOBData{
+			IPAddress: (*list)[i].DirectAddress,
+			Cloud:     4,
+		})
+		if err != nil {
+			// TODO - Fix backend API for missing data.
+			log.Printf("[TRACE] Error : %s", err)
+		}
+		hosts[i] = byohHost{Host: (*list)[i], Credential: cred}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return fetched.Set(hosts)
+}
+
+func (byoh *BYOH) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]byohHost
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
 	}
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== BYOH TF generation started. =====>")
-		for _, byoh := range *list {
+		for _, host := range *list {
+			byoh := host.Host
 			shortName := byoh.Name
 			resourceName := common.GetResourceName(shortName)
 			log.Printf("[TRACE] Generating terraform config for duplo byoh Instance : %s", shortName)
@@ -42,11 +86,6 @@ func (byoh *BYOH) Generate(config *common.Config, client *duplosdk.Client) (*com
 
 			// create new file on system
 			path := filepath.Join(workingDir, "byoh-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -78,33 +117,28 @@ func (byoh *BYOH) Generate(config *common.Config, client *duplosdk.Client) (*com
 					}
 				}
 			}
-			cred, err := client.TenantHostCredentialsGet(config.TenantId, duplosdk.DuploHostOOBData{
-				IPAddress: byoh.DirectAddress,
-				Cloud:     4,
-			})
-			if err != nil {
-				// TODO - Fix backend API for missing data.
-				log.Printf("[TRACE] Error : %s", err)
-			}
-
-			if cred != nil {
+			if cred := host.Credential; cred != nil {
 				if len(cred.Username) > 0 {
 					byohBody.SetAttributeValue("username",
 						cty.StringVal(cred.Username))
 				}
-				if len(cred.Password) > 0 {
+				if len(cred.Password) > 0 && config.ExternalSecrets() {
+					byohBody.SetAttributeTraversal("password",
+						tfContext.SensitiveVar("byoh_"+resourceName+"_password", "Password of the BYOH host "+shortName+".", cty.StringVal(cred.Password)))
+				} else if len(cred.Password) > 0 {
 					byohBody.SetAttributeValue("password",
 						cty.StringVal(cred.Password))
 				}
-				if len(cred.Privatekey) > 0 {
+				if len(cred.Privatekey) > 0 && config.ExternalSecrets() {
+					byohBody.SetAttributeTraversal("private_key",
+						tfContext.SensitiveVar("byoh_"+resourceName+"_private_key", "Private key of the BYOH host "+shortName+".", cty.StringVal(cred.Privatekey)))
+				} else if len(cred.Privatekey) > 0 {
 					byohBody.SetAttributeValue("private_key",
 						cty.StringVal(cred.Privatekey))
 				}
 			}
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 
@@ -114,7 +148,7 @@ func (byoh *BYOH) Generate(config *common.Config, client *duplosdk.Client) (*com
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_byoh." + resourceName,
 					ResourceId:      config.TenantId + "/" + shortName,
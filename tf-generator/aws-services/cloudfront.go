@@ -1,13 +1,13 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2/hclsyntax"
@@ -22,24 +22,56 @@ const CFD_VAR_PREFIX = "cfd_"
 type CFD struct {
 }
 
-func (cfd *CFD) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "cloudfront",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_cloudfront_distribution"},
+		DuploAPIs:     []string{"AwsCloudfrontDistributionList", "TenantListS3Buckets"},
+		DependsOn:     []string{"aws-services-main", "s3"},
+		New:           func() tfgenerator.Generator { return &CFD{} },
+	})
+}
+
+// cfdSnapshot holds the duplo objects rendered by the cloudfront generator.
+type cfdSnapshot struct {
+	List *[]duplosdk.DuploAwsCloudfrontDistributionConfig
+	// S3Buckets are used to reference the buckets of the origins, nil when they could not be read.
+	S3Buckets *[]duplosdk.DuploS3Bucket
+}
+
+func (cfd *CFD) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.AwsCloudfrontDistributionList(config.TenantId)
 	//Get tenant from duplo
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, nil
+		return clientErr
 	}
-	prefix, clientErr := client.GetDuploServicesPrefix(config.TenantId)
-	if clientErr != nil {
-		return nil, clientErr
+	data := cfdSnapshot{List: list}
+	if list != nil {
+		s3Buckets, clientErr := client.TenantListS3Buckets(config.TenantId)
+		if clientErr != nil {
+			// The origins are written with the domain names of the buckets instead of references.
+			fetched.ObjectFailed("s3 buckets of the distribution origins", clientErr)
+		} else {
+			data.S3Buckets = s3Buckets
+		}
 	}
+	return fetched.Set(data)
+}
+
+func (cfd *CFD) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	data := cfdSnapshot{}
+	if err := fetched.Decode(&data); err != nil {
+		return nil, err
+	}
+	list, s3List := data.List, data.S3Buckets
+	prefix := config.DuploServicesPrefix()
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== AWS Cloudfront Distribution TF generation started. =====>")
-		s3List, _ := client.TenantListS3Buckets(config.TenantId)
 		for _, cfd := range *list {
 			shortName, _ := duplosdk.UnprefixName(prefix, cfd.Comment)
 			resourceName := common.GetResourceName(shortName)
@@ -52,11 +84,6 @@ func (cfd *CFD) Generate(config *common.Config, client *duplosdk.Client) (*commo
 
 			// create new file on system
 			path := filepath.Join(workingDir, "cfd-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -412,9 +439,7 @@ func (cfd *CFD) Generate(config *common.Config, client *duplosdk.Client) (*commo
 				}
 			}
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo AWS Cloudfront Distribution : %s", shortName)
@@ -422,7 +447,7 @@ func (cfd *CFD) Generate(config *common.Config, client *duplosdk.Client) (*commo
 			outVars := generateCFDOutputVars(varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_cloudfront_distribution." + resourceName,
 					ResourceId:      config.TenantId + "/" + cfd.Id,
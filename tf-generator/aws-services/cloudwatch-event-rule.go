@@ -1,11 +1,11 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -16,19 +16,69 @@ import (
 type CloudwatchEventRule struct {
 }
 
-func (cwer *CloudwatchEventRule) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "cloudwatch-event-rule",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_cloudwatch_event_rule", "duplocloud_aws_cloudwatch_event_target"},
+		DuploAPIs:     []string{"DuploCloudWatchEventRuleList", "DuploCloudWatchEventTargetsList"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &CloudwatchEventRule{} },
+	})
+}
+
+// cwEventRule is a cloudwatch event rule with its targets.
+type cwEventRule struct {
+	Rule    duplosdk.DuploCloudWatchEventRuleGetReq
+	Targets *[]duplosdk.DuploCloudWatchEventTarget
+}
+
+func (cwer *CloudwatchEventRule) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.DuploCloudWatchEventRuleList(config.TenantId)
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	if list == nil {
+		return fetched.Set(nil)
+	}
+	rules := make([]cwEventRule, len(*list))
+	fetchErrs := make([]error, len(*list))
+	err := common.ForEachParallel(ctx, config.Workers(), len(*list), func(i int) error {
+		targets, clientErr := client.DuploCloudWatchEventTargetsList(config.TenantId, (*list)[i].Name)
+		if clientErr != nil {
+			fetchErrs[i] = clientErr
+			return nil
+		}
+		rules[i] = cwEventRule{Rule: (*list)[i], Targets: targets}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fetchedRules := []cwEventRule{}
+	for i, rule := range *list {
+		if fetchErrs[i] != nil {
+			fetched.ObjectFailed("cloudwatch event rule "+rule.Name, fetchErrs[i])
+			continue
+		}
+		fetchedRules = append(fetchedRules, rules[i])
+	}
+	return fetched.Set(fetchedRules)
+}
+
+func (cwer *CloudwatchEventRule) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]cwEventRule
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
 	}
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== Cloudwatch event rules TF generation started. =====>")
-		for _, cwer := range *list {
+		for _, rule := range *list {
+			cwer := rule.Rule
 			shortName := cwer.Name[len("duploservices-"+config.TenantName+"-"):len(cwer.Name)]
 			resourceName := common.GetResourceName(shortName)
 			log.Printf("[TRACE] Generating terraform config for duplo Cloudwatch event rules : %s", shortName)
@@ -38,11 +88,6 @@ func (cwer *CloudwatchEventRule) Generate(config *common.Config, client *duplosd
 
 			// create new file on system
 			path := filepath.Join(workingDir, "cw-event-rule-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
@@ -83,7 +128,7 @@ func (cwer *CloudwatchEventRule) Generate(config *common.Config, client *duplosd
 				cwerBody.SetAttributeValue("state",
 					cty.StringVal(cwer.State.Value))
 			}
-			targetList, _ := client.DuploCloudWatchEventTargetsList(config.TenantId, cwer.Name)
+			targetList := rule.Targets
 			if targetList != nil && len(*targetList) > 0 {
 				rootBody.AppendNewline()
 				for _, target := range *targetList {
@@ -120,7 +165,7 @@ func (cwer *CloudwatchEventRule) Generate(config *common.Config, client *duplosd
 						cwetBody.SetAttributeValue("event_bus_name",
 							cty.StringVal(cwer.EventBusName))
 					}
-					if config.GenerateTfState {
+					if config.ImportsEnabled() {
 						importConfigs = append(importConfigs, common.ImportConfig{
 							ResourceAddress: "duplocloud_aws_cloudwatch_event_target." + targetResourceName,
 							ResourceId:      config.TenantId + "/" + cwer.Name + "/" + target.Id,
@@ -131,15 +176,13 @@ func (cwer *CloudwatchEventRule) Generate(config *common.Config, client *duplosd
 				}
 
 			}
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo Cloudwatch metrics : %s", shortName)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_cloudwatch_event_rule." + resourceName,
 					ResourceId:      config.TenantId + "/" + cwer.Name,
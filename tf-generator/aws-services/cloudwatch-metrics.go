@@ -1,13 +1,13 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -20,20 +20,58 @@ const CWM_VAR_PREFIX = "cwm_"
 type CloudwatchMetrics struct {
 }
 
-func (cwm *CloudwatchMetrics) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "cloudwatch-metrics",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_cloudwatch_metric_alarm"},
+		DuploAPIs:     []string{"DuploCloudWatchMetricAlarmList", "NativeHostGetList", "RdsInstanceList", "TenantDynamoDBList"},
+		DependsOn:     []string{"aws-services-main", "hosts", "rds", "dynamodb"},
+		New:           func() tfgenerator.Generator { return &CloudwatchMetrics{} },
+	})
+}
+
+// cwmSnapshot holds the alarms with the hosts, RDS instances and DynamoDB tables their dimensions can refer to.
+type cwmSnapshot struct {
+	List         *[]duplosdk.DuploCloudWatchMetricAlarm
+	Hosts        *[]duplosdk.DuploNativeHost
+	RdsInstances *[]duplosdk.DuploRdsInstance
+	DynamoDBs    *[]duplosdk.DuploAwsResource
+}
+
+func (cwm *CloudwatchMetrics) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.DuploCloudWatchMetricAlarmList(config.TenantId)
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	data := cwmSnapshot{List: list}
+	if list != nil {
+		// The dimensions are written with the names of the objects which could not be read instead of references.
+		var clientErr duplosdk.ClientError
+		if data.Hosts, clientErr = client.NativeHostGetList(config.TenantId); clientErr != nil {
+			fetched.ObjectFailed("hosts of the alarm dimensions", clientErr)
+		}
+		if data.RdsInstances, clientErr = client.RdsInstanceList(config.TenantId); clientErr != nil {
+			fetched.ObjectFailed("rds instances of the alarm dimensions", clientErr)
+		}
+		if data.DynamoDBs, clientErr = client.TenantDynamoDBList(config.TenantId); clientErr != nil {
+			fetched.ObjectFailed("dynamodb tables of the alarm dimensions", clientErr)
+		}
+	}
+	return fetched.Set(data)
+}
+
+func (cwm *CloudwatchMetrics) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	data := cwmSnapshot{}
+	if err := fetched.Decode(&data); err != nil {
+		return nil, err
 	}
+	list, hostList, rdsList, dynamoDBList := data.List, data.Hosts, data.RdsInstances, data.DynamoDBs
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
-		hostList, _ := client.NativeHostGetList(config.TenantId)
-		rdsList, _ := client.RdsInstanceList(config.TenantId)
-		dynamoDBList, _ := client.TenantDynamoDBList(config.TenantId)
 		log.Println("[TRACE] <====== Cloudwatch metrics TF generation started. =====>")
 		for i, cwm := range *list {
 			friendlyNames := []string{}
@@ -50,11 +88,6 @@ func (cwm *CloudwatchMetrics) Generate(config *common.Config, client *duplosdk.C
 
 			// create new file on system
 			path := filepath.Join(workingDir, "cwm-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
@@ -143,7 +176,7 @@ func (cwm *CloudwatchMetrics) Generate(config *common.Config, client *duplosdk.C
 						for _, dynamodb := range *dynamoDBList {
 							if dim.Value == dynamodb.Name {
 								valAssigned = true
-								dynamoDBShortName, _ := extractDynamoDBName(client, config.TenantId, dynamodb.Name)
+								dynamoDBShortName := extractDynamoDBName(config.DuploServicesPrefix(), dynamodb.Name)
 								dimBody.SetAttributeTraversal("value", hcl.Traversal{
 									hcl.TraverseRoot{
 										Name: "duplocloud_aws_dynamodb_table_v2." + dynamoDBShortName,
@@ -162,15 +195,13 @@ func (cwm *CloudwatchMetrics) Generate(config *common.Config, client *duplosdk.C
 				}
 			}
 			friendlyNames = append(friendlyNames, cwm.MetricName)
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo Cloudwatch metrics : %s", shortName)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_cloudwatch_metric_alarm." + resourceName,
 					ResourceId:      config.TenantId + "/" + strings.Join(friendlyNames, "-"),
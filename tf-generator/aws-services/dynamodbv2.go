@@ -1,11 +1,11 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -18,28 +18,74 @@ const DYN_DB_VAR_PREFIX = "dynamodb_"
 type DynamoDB struct {
 }
 
-func (dynamodb *DynamoDB) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "dynamodb",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_dynamodb_table_v2"},
+		DuploAPIs:     []string{"TenantDynamoDBList", "DynamoDBTableGetV2"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &DynamoDB{} },
+	})
+}
+
+// dynamoDBTable is a DynamoDB table with its short name.
+type dynamoDBTable struct {
+	ShortName string
+	Table     *duplosdk.DuploDynamoDBTableV2
+}
+
+func (dynamodb *DynamoDB) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.TenantDynamoDBList(config.TenantId)
 	//Get tenant from duplo
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	if list == nil {
+		return fetched.Set(nil)
+	}
+	prefix := config.DuploServicesPrefix()
+	infos := make([]*duplosdk.DuploDynamoDBTableV2, len(*list))
+	fetchErrs := make([]error, len(*list))
+	err := common.ForEachParallel(ctx, config.Workers(), len(*list), func(i int) error {
+		dynamodbInfo, clientErr := client.DynamoDBTableGetV2(config.TenantId, extractDynamoDBName(prefix, (*list)[i].Name))
+		if clientErr != nil {
+			fetchErrs[i] = clientErr
+			return nil
+		}
+		infos[i] = dynamodbInfo
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	tables := []dynamoDBTable{}
+	for i, dynamodb := range *list {
+		if fetchErrs[i] != nil {
+			fetched.ObjectFailed("dynamodb table "+dynamodb.Name, fetchErrs[i])
+			continue
+		}
+		tables = append(tables, dynamoDBTable{ShortName: extractDynamoDBName(prefix, dynamodb.Name), Table: infos[i]})
+	}
+	return fetched.Set(tables)
+}
+
+func (dynamodb *DynamoDB) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]dynamoDBTable
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
 	}
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
-		for _, dynamodb := range *list {
-			shortName, _ := extractDynamoDBName(client, config.TenantId, dynamodb.Name)
+		for _, table := range *list {
+			shortName := table.ShortName
 			resourceName := common.GetResourceName(shortName)
 			log.Printf("[TRACE] Generating terraform config for DynamoDB : %s", shortName)
 
-			dynamodbInfo, clientErr := client.DynamoDBTableGetV2(config.TenantId, shortName)
-			if clientErr != nil {
-				fmt.Println(clientErr)
-				return nil, nil
-			}
+			dynamodbInfo := table.Table
 			varFullPrefix := DYN_DB_VAR_PREFIX + resourceName + "_"
 			// inputVars := generateKafkaVars(clusterInfo, varFullPrefix)
 			// tfContext.InputVars = append(tfContext.InputVars, inputVars...)
@@ -48,11 +94,6 @@ func (dynamodb *DynamoDB) Generate(config *common.Config, client *duplosdk.Clien
 
 			// create new file on system
 			path := filepath.Join(workingDir, "dynamodb-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -183,9 +224,7 @@ func (dynamodb *DynamoDB) Generate(config *common.Config, client *duplosdk.Clien
 					cty.StringVal(dynamodbInfo.SSEDescription.KMSMasterKeyArn))
 			}
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 
@@ -193,9 +232,13 @@ func (dynamodb *DynamoDB) Generate(config *common.Config, client *duplosdk.Clien
 
 			outVars := generateDynamoDBOutputVars(varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_aws_dynamodb_table_v2."+resourceName, map[string]string{
+				"arn":        dynamodbInfo.TableArn,
+				"stream_arn": dynamodbInfo.LatestStreamArn,
+			})...)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_dynamodb_table_v2." + resourceName,
 					ResourceId:      config.TenantId + "/" + shortName,
@@ -235,11 +278,7 @@ func generateDynamoDBOutputVars(prefix, resourceName string) []common.OutputVarC
 	return outVars
 }
 
-func extractDynamoDBName(client *duplosdk.Client, tenantID string, fullname string) (string, error) {
-	prefix, err := client.GetDuploServicesPrefix(tenantID)
-	if err != nil {
-		return "", err
-	}
+func extractDynamoDBName(prefix string, fullname string) string {
 	name, _ := duplosdk.UnprefixName(prefix, fullname)
-	return name, nil
+	return name
 }
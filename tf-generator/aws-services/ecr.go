@@ -1,11 +1,11 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -19,15 +19,33 @@ const ECR_VAR_PREFIX = "ecr_"
 type ECR struct {
 }
 
-func (ecr *ECR) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
-	list, clientErr := client.AwsEcrRepositoryList(config.TenantId)
-	//Get tenant from duplo
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "ecr",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_ecr_repository"},
+		DuploAPIs:     []string{"AwsEcrRepositoryList"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &ECR{} },
+	})
+}
 
+func (ecr *ECR) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
+	list, clientErr := client.AwsEcrRepositoryList(config.TenantId)
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
 	}
+	return fetched.Set(list)
+}
+
+func (ecr *ECR) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]duplosdk.DuploAwsEcrRepository
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
+	}
+	//Get tenant from duplo
+
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
@@ -44,11 +62,6 @@ func (ecr *ECR) Generate(config *common.Config, client *duplosdk.Client) (*commo
 
 			// create new file on system
 			path := filepath.Join(workingDir, "ecr-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
@@ -84,18 +97,20 @@ func (ecr *ECR) Generate(config *common.Config, client *duplosdk.Client) (*commo
 				ecrBody.SetAttributeValue("kms_encryption_key",
 					cty.StringVal(ecr.KmsEncryption))
 			}
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo AWS ECR : %s", shortName)
 
 			outVars := generateECROutputVars(varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_aws_ecr_repository."+resourceName, map[string]string{
+				"arn":            ecr.Arn,
+				"repository_url": ecr.RepositoryUri,
+			})...)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_ecr_repository." + resourceName,
 					ResourceId:      config.TenantId + "/" + shortName,
@@ -1,12 +1,12 @@
 package awsservices
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"log"
-	"os"
 	"path/filepath"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -19,38 +19,81 @@ const EMR_VAR_PREFIX = "emr_"
 type EMR struct {
 }
 
-func (emr *EMR) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "emr",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_emr_cluster"},
+		DuploAPIs:     []string{"DuploEmrClusterGetList", "DuploEmrClusterGet"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &EMR{} },
+	})
+}
+
+// emrCluster is an EMR cluster with its settings.
+type emrCluster struct {
+	Summary duplosdk.DuploEmrClusterSummary
+	Cluster *duplosdk.DuploEmrClusterRequest
+}
+
+func (emr *EMR) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.DuploEmrClusterGetList(config.TenantId)
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	if list == nil {
+		return fetched.Set(nil)
+	}
+	prefix := config.DuploServicesPrefix()
+	infos := make([]*duplosdk.DuploEmrClusterRequest, len(*list))
+	fetchErrs := make([]error, len(*list))
+	err := common.ForEachParallel(ctx, config.Workers(), len(*list), func(i int) error {
+		emrInfo, clientErr := client.DuploEmrClusterGet(config.TenantId, extractEMRShortName(prefix, (*list)[i].Name))
+		if clientErr != nil {
+			fetchErrs[i] = clientErr
+			return nil
+		}
+		infos[i] = emrInfo
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	clusters := []emrCluster{}
+	for i, emr := range *list {
+		if fetchErrs[i] != nil {
+			fetched.ObjectFailed("emr cluster "+emr.Name, fetchErrs[i])
+			continue
+		}
+		clusters = append(clusters, emrCluster{Summary: emr, Cluster: infos[i]})
+	}
+	return fetched.Set(clusters)
+}
+
+func (emr *EMR) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]emrCluster
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
 	}
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== EMR TF generation started. =====>")
-		for _, emr := range *list {
-			shortName, _ := extractEMRShortName(client, config.TenantId, emr.Name)
+		prefix := config.DuploServicesPrefix()
+		for _, cluster := range *list {
+			emr := cluster.Summary
+			shortName := extractEMRShortName(prefix, emr.Name)
 			resourceName := common.GetResourceName(shortName)
 			log.Printf("[TRACE] Generating terraform config for duplo EMR Instance : %s", shortName)
 
-			emrInfo, clientErr := client.DuploEmrClusterGet(config.TenantId, shortName)
-			if clientErr != nil {
-				fmt.Println(clientErr)
-				return nil, clientErr
-			}
+			emrInfo := cluster.Cluster
 			varFullPrefix := EMR_VAR_PREFIX + resourceName + "_"
 			// create new empty hcl file object
 			hclFile := hclwrite.NewEmptyFile()
 
 			// create new file on system
 			path := filepath.Join(workingDir, "emr-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -103,135 +146,101 @@ func (emr *EMR) Generate(config *common.Config, client *duplosdk.Client) (*commo
 				var appsMap interface{}
 				err := json.Unmarshal([]byte(emrInfo.Applications), &appsMap)
 				if err != nil {
-					panic(err)
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				appsStr, err := duplosdk.JSONMarshal(appsMap)
-				if err != nil {
-					panic(err)
+				if err := common.SetJSONEncodeAttribute(emrBody, "applications", appsMap); err != nil {
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				emrBody.SetAttributeTraversal("applications", hcl.Traversal{
-					hcl.TraverseRoot{
-						Name: "jsonencode(" + appsStr + ")",
-					},
-				})
 			}
 			if len(emrInfo.BootstrapActions) > 0 {
 				var bootstrapActionsMap interface{}
 				err := json.Unmarshal([]byte(emrInfo.BootstrapActions), &bootstrapActionsMap)
 				if err != nil {
-					panic(err)
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				bootstrapActionsMapStr, err := duplosdk.JSONMarshal(bootstrapActionsMap)
-				if err != nil {
-					panic(err)
+				if err := common.SetJSONEncodeAttribute(emrBody, "bootstrap_actions", bootstrapActionsMap); err != nil {
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				emrBody.SetAttributeTraversal("bootstrap_actions", hcl.Traversal{
-					hcl.TraverseRoot{
-						Name: "jsonencode(" + bootstrapActionsMapStr + ")",
-					},
-				})
 			}
 			if len(emrInfo.Configurations) > 0 {
 				var configurationsMap interface{}
 				err := json.Unmarshal([]byte(emrInfo.Configurations), &configurationsMap)
 				if err != nil {
-					panic(err)
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				configurationsMapStr, err := duplosdk.JSONMarshal(configurationsMap)
-				if err != nil {
-					panic(err)
+				if err := common.SetJSONEncodeAttribute(emrBody, "configurations", configurationsMap); err != nil {
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				emrBody.SetAttributeTraversal("configurations", hcl.Traversal{
-					hcl.TraverseRoot{
-						Name: "jsonencode(" + configurationsMapStr + ")",
-					},
-				})
 			}
 			if len(emrInfo.Steps) > 0 {
 				var stepsMap interface{}
 				err := json.Unmarshal([]byte(emrInfo.Steps), &stepsMap)
 				if err != nil {
-					panic(err)
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				stepsMapStr, err := duplosdk.JSONMarshal(stepsMap)
-				if err != nil {
-					panic(err)
+				if err := common.SetJSONEncodeAttribute(emrBody, "steps", stepsMap); err != nil {
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				emrBody.SetAttributeTraversal("steps", hcl.Traversal{
-					hcl.TraverseRoot{
-						Name: "jsonencode(" + stepsMapStr + ")",
-					},
-				})
 			}
 			if len(emrInfo.AdditionalInfo) > 0 {
 				var additionalInfoMap interface{}
 				err := json.Unmarshal([]byte(emrInfo.AdditionalInfo), &additionalInfoMap)
 				if err != nil {
-					panic(err)
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				additionalInfoMapStr, err := duplosdk.JSONMarshal(additionalInfoMap)
-				if err != nil {
-					panic(err)
+				if err := common.SetJSONEncodeAttribute(emrBody, "additional_info", additionalInfoMap); err != nil {
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				emrBody.SetAttributeTraversal("additional_info", hcl.Traversal{
-					hcl.TraverseRoot{
-						Name: "jsonencode(" + additionalInfoMapStr + ")",
-					},
-				})
 			}
 			if len(emrInfo.ManagedScalingPolicy) > 0 {
 				var managedScalingPolicyMap interface{}
 				err := json.Unmarshal([]byte(emrInfo.ManagedScalingPolicy), &managedScalingPolicyMap)
 				if err != nil {
-					panic(err)
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				managedScalingPolicyMapStr, err := duplosdk.JSONMarshal(managedScalingPolicyMap)
-				if err != nil {
-					panic(err)
+				if err := common.SetJSONEncodeAttribute(emrBody, "managed_scaling_policy", managedScalingPolicyMap); err != nil {
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				emrBody.SetAttributeTraversal("managed_scaling_policy", hcl.Traversal{
-					hcl.TraverseRoot{
-						Name: "jsonencode(" + managedScalingPolicyMapStr + ")",
-					},
-				})
 			}
 			if len(emrInfo.InstanceFleets) > 0 {
 				var instanceFleetsMap interface{}
 				err := json.Unmarshal([]byte(emrInfo.InstanceFleets), &instanceFleetsMap)
 				if err != nil {
-					panic(err)
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				instanceFleetsMapStr, err := duplosdk.JSONMarshal(instanceFleetsMap)
-				if err != nil {
-					panic(err)
+				if err := common.SetJSONEncodeAttribute(emrBody, "instance_fleets", instanceFleetsMap); err != nil {
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				emrBody.SetAttributeTraversal("instance_fleets", hcl.Traversal{
-					hcl.TraverseRoot{
-						Name: "jsonencode(" + instanceFleetsMapStr + ")",
-					},
-				})
 			}
 			if len(emrInfo.InstanceGroups) > 0 {
 				var instanceGroupsMap interface{}
 				err := json.Unmarshal([]byte(emrInfo.InstanceGroups), &instanceGroupsMap)
 				if err != nil {
-					panic(err)
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				instanceGroupsMapStr, err := duplosdk.JSONMarshal(instanceGroupsMap)
-				if err != nil {
-					panic(err)
+				if err := common.SetJSONEncodeAttribute(emrBody, "instance_groups", instanceGroupsMap); err != nil {
+					tfContext.ObjectFailed("emr cluster "+shortName, err)
+					continue
 				}
-				emrBody.SetAttributeTraversal("instance_groups", hcl.Traversal{
-					hcl.TraverseRoot{
-						Name: "jsonencode(" + instanceGroupsMapStr + ")",
-					},
-				})
 			}
 
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 
@@ -239,9 +248,14 @@ func (emr *EMR) Generate(config *common.Config, client *duplosdk.Client) (*commo
 
 			outVars := generateEMROutputVars(varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_emr_cluster."+resourceName, map[string]string{
+				"fullname":    emr.Name,
+				"arn":         emr.Arn,
+				"job_flow_id": emr.JobFlowId,
+			})...)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_emr_cluster." + resourceName,
 					ResourceId:      config.TenantId + "/" + emr.JobFlowId,
@@ -290,11 +304,7 @@ func generateEMROutputVars(prefix, resourceName string) []common.OutputVarConfig
 	return outVars
 }
 
-func extractEMRShortName(client *duplosdk.Client, tenantID string, fullName string) (string, error) {
-	prefix, err := client.GetDuploServicesPrefix(tenantID)
-	if err != nil {
-		return "", err
-	}
+func extractEMRShortName(prefix string, fullName string) string {
 	name, _ := duplosdk.UnprefixName(prefix, fullName)
-	return name, nil
+	return name
 }
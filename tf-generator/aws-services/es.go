@@ -1,11 +1,11 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -26,20 +26,52 @@ const (
 type ES struct {
 }
 
-func (es *ES) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "elasticsearch",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_elasticsearch"},
+		DuploAPIs:     []string{"TenantListElasticSearchDomains", "TenantGetTenantKmsKey"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &ES{} },
+	})
+}
+
+// esSnapshot holds the duplo objects rendered by the elastic search generator.
+type esSnapshot struct {
+	List *[]duplosdk.DuploElasticSearchDomain
+	// TenantKmsKey is nil when it could not be read, the domains then reference their key by ARN.
+	TenantKmsKey *duplosdk.DuploAwsKmsKey
+}
+
+func (es *ES) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.TenantListElasticSearchDomains(config.TenantId)
 	//Get tenant from duplo
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	data := esSnapshot{List: list}
+	if list != nil {
+		kms, kmsClientErr := client.TenantGetTenantKmsKey(config.TenantId)
+		if kmsClientErr == nil {
+			data.TenantKmsKey = kms
+		}
+	}
+	return fetched.Set(data)
+}
+
+func (es *ES) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	data := esSnapshot{}
+	if err := fetched.Decode(&data); err != nil {
+		return nil, err
 	}
+	list, kms := data.List, data.TenantKmsKey
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== Elastic Search TF generation started. =====>")
-		kms, kmsClientErr := client.TenantGetTenantKmsKey(config.TenantId)
 		for _, es := range *list {
 			shortName := es.Name
 			resourceName := common.GetResourceName(shortName)
@@ -54,11 +86,6 @@ func (es *ES) Generate(config *common.Config, client *duplosdk.Client) (*common.
 
 			// create new file on system
 			path := filepath.Join(workingDir, "es-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -130,7 +157,7 @@ func (es *ES) Generate(config *common.Config, client *duplosdk.Client) (*common.
 					nil)
 				encryptBody := encryptBlock.Body()
 				if es.EncryptionAtRestOptions.KmsKeyID != "" {
-					if kms != nil && kmsClientErr == nil && (es.EncryptionAtRestOptions.KmsKeyID == kms.KeyArn || es.EncryptionAtRestOptions.KmsKeyID == kms.KeyID) {
+					if kms != nil && (es.EncryptionAtRestOptions.KmsKeyID == kms.KeyArn || es.EncryptionAtRestOptions.KmsKeyID == kms.KeyID) {
 						encryptBody.SetAttributeTraversal("kms_key_id", hcl.Traversal{
 							hcl.TraverseRoot{
 								Name: "data.duplocloud_tenant_aws_kms_key.tenant_kms",
@@ -150,18 +177,21 @@ func (es *ES) Generate(config *common.Config, client *duplosdk.Client) (*common.
 			// 	cty.NumberIntVal(es.NodeToNodeEncryptionOptions.Enabled))
 
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo elastic search instance : %s", shortName)
 
 			outVars := generateESOutputVars(varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_aws_elasticsearch."+resourceName, map[string]string{
+				"arn":              es.Arn,
+				"domain_name":      es.DomainName,
+				`endpoints["vpc"]`: es.Endpoints["vpc"],
+			})...)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_elasticsearch." + resourceName,
 					ResourceId:      config.TenantId + "/" + shortName,
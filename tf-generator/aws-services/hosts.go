@@ -1,11 +1,11 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -18,15 +18,33 @@ const HOST_VAR_PREFIX = "host_"
 type Hosts struct {
 }
 
-func (h *Hosts) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
-	list, clientErr := client.NativeHostGetList(config.TenantId)
-	//Get tenant from duplo
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "hosts",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_host"},
+		DuploAPIs:     []string{"NativeHostGetList"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &Hosts{} },
+	})
+}
 
+func (h *Hosts) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
+	list, clientErr := client.NativeHostGetList(config.TenantId)
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, nil
+		return clientErr
 	}
+	return fetched.Set(list)
+}
+
+func (h *Hosts) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]duplosdk.DuploNativeHost
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
+	}
+	//Get tenant from duplo
+
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
@@ -46,11 +64,6 @@ func (h *Hosts) Generate(config *common.Config, client *duplosdk.Client) (*commo
 
 			// create new file on system
 			path := filepath.Join(workingDir, "host-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -156,17 +169,19 @@ func (h *Hosts) Generate(config *common.Config, client *duplosdk.Client) (*commo
 			}
 			// TODO - Handle tags, network_interface
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo host : %s", host.FriendlyName)
 
 			outVars := generateHostOutputVars(host, varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_aws_host."+resourceName, map[string]string{
+				"instance_id":        host.InstanceID,
+				"private_ip_address": host.PrivateIPAddress,
+			})...)
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_host." + resourceName,
 					ResourceId:      "v2/subscriptions/" + config.TenantId + "/NativeHostV2/" + host.InstanceID,
@@ -1,12 +1,12 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"strconv"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -19,29 +19,76 @@ const KAFKA_VAR_PREFIX = "kafka_"
 type Kafka struct {
 }
 
-func (k *Kafka) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "kafka",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_kafka_cluster"},
+		DuploAPIs:     []string{"TenantListKafkaCluster", "TenantGetKafkaClusterInfo"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &Kafka{} },
+	})
+}
+
+// kafkaCluster is a kafka cluster with its details.
+type kafkaCluster struct {
+	Cluster duplosdk.DuploKafkaCluster
+	Info    *duplosdk.DuploKafkaClusterInfo
+}
+
+func (k *Kafka) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.TenantListKafkaCluster(config.TenantId)
 	//Get tenant from duplo
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	if list == nil {
+		return fetched.Set(nil)
+	}
+	clusterInfos := make([]*duplosdk.DuploKafkaClusterInfo, len(*list))
+	fetchErrs := make([]error, len(*list))
+	err := common.ForEachParallel(ctx, config.Workers(), len(*list), func(i int) error {
+		clusterInfo, clientErr := client.TenantGetKafkaClusterInfo(config.TenantId, (*list)[i].Arn)
+		if clientErr != nil {
+			fetchErrs[i] = clientErr
+			return nil
+		}
+		clusterInfos[i] = clusterInfo
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	clusters := []kafkaCluster{}
+	for i, kafka := range *list {
+		if fetchErrs[i] != nil {
+			shortName := kafka.Name[len(config.DuploServicesPrefix()+"-"):len(kafka.Name)]
+			fetched.ObjectFailed("kafka cluster "+shortName, fetchErrs[i])
+			continue
+		}
+		clusters = append(clusters, kafkaCluster{Cluster: kafka, Info: clusterInfos[i]})
+	}
+	return fetched.Set(clusters)
+}
+
+func (k *Kafka) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]kafkaCluster
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
 	}
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== kafka TF generation started. =====>")
-		for _, kafka := range *list {
+		for _, cluster := range *list {
+			kafka := cluster.Cluster
 			shortName := kafka.Name[len("duploservices-"+config.TenantName+"-"):len(kafka.Name)]
 			resourceName := common.GetResourceName(shortName)
 			log.Printf("[TRACE] Generating terraform config for duplo kafka Instance : %s", shortName)
 
-			clusterInfo, clientErr := client.TenantGetKafkaClusterInfo(config.TenantId, kafka.Arn)
-			if clientErr != nil {
-				fmt.Println(clientErr)
-				return nil, clientErr
-			}
+			clusterInfo := cluster.Info
 			varFullPrefix := KAFKA_VAR_PREFIX + resourceName + "_"
 			inputVars := generateKafkaVars(clusterInfo, varFullPrefix)
 			tfContext.InputVars = append(tfContext.InputVars, inputVars...)
@@ -50,11 +97,6 @@ func (k *Kafka) Generate(config *common.Config, client *duplosdk.Client) (*commo
 
 			// create new file on system
 			path := filepath.Join(workingDir, "kafka-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -109,9 +151,7 @@ func (k *Kafka) Generate(config *common.Config, client *duplosdk.Client) (*commo
 			}
 
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 
@@ -119,9 +159,13 @@ func (k *Kafka) Generate(config *common.Config, client *duplosdk.Client) (*commo
 
 			outVars := generateKafkaOutputVars(varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_aws_kafka_cluster."+resourceName, map[string]string{
+				"fullname": kafka.Name,
+				"arn":      kafka.Arn,
+			})...)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_kafka_cluster." + resourceName,
 					ResourceId:      "v2/subscriptions/" + config.TenantId + "/ECacheDBInstance/" + shortName,
@@ -1,13 +1,13 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -20,40 +20,87 @@ const LF_VAR_PREFIX = "lf_"
 type LambdaFunction struct {
 }
 
-func (lf *LambdaFunction) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "lambda",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_lambda_function", "duplocloud_aws_lambda_permission"},
+		DuploAPIs:     []string{"LambdaFunctionGetList", "LambdaFunctionGet", "LambdaPermissionGet"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &LambdaFunction{} },
+	})
+}
+
+// lambdaFunction is a lambda function with its details and permissions.
+type lambdaFunction struct {
+	Function    duplosdk.DuploLambdaConfiguration
+	Details     *duplosdk.DuploLambdaFunction
+	Permissions *[]duplosdk.DuploLambdaPermissionStatement
+}
+
+func (lf *LambdaFunction) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.LambdaFunctionGetList(config.TenantId)
 	//Get tenant from duplo
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	if list == nil {
+		return fetched.Set(nil)
+	}
+	functions := make([]lambdaFunction, len(*list))
+	fetchErrs := make([]error, len(*list))
+	err := common.ForEachParallel(ctx, config.Workers(), len(*list), func(i int) error {
+		lfDetails, clientErr := client.LambdaFunctionGet(config.TenantId, (*list)[i].FunctionName)
+		if clientErr != nil {
+			fetchErrs[i] = clientErr
+			return nil
+		}
+		permissions, clientErr := client.LambdaPermissionGet(config.TenantId, (*list)[i].FunctionName)
+		if clientErr != nil {
+			fetchErrs[i] = clientErr
+			return nil
+		}
+		functions[i] = lambdaFunction{Function: (*list)[i], Details: lfDetails, Permissions: permissions}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fetchedFunctions := []lambdaFunction{}
+	for i, lf := range *list {
+		if fetchErrs[i] != nil {
+			fetched.ObjectFailed("lambda function "+lf.FunctionName, fetchErrs[i])
+			continue
+		}
+		fetchedFunctions = append(fetchedFunctions, functions[i])
+	}
+	return fetched.Set(fetchedFunctions)
+}
+
+func (lf *LambdaFunction) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]lambdaFunction
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
 	}
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== Lambda Function TF generation started. =====>")
-		for _, lf := range *list {
+		for _, function := range *list {
+			lf := function.Function
 			shortName := lf.Name
 			resourceName := common.GetResourceName(shortName)
 			log.Printf("[TRACE] Generating terraform config for lammbda funtion : %s", shortName)
 
-			lfDetails, clientErr := client.LambdaFunctionGet(config.TenantId, lf.FunctionName)
-			if clientErr != nil {
-				fmt.Println(clientErr)
-				continue
-			}
+			lfDetails := function.Details
 			varFullPrefix := LF_VAR_PREFIX + resourceName + "_"
 			// create new empty hcl file object
 			hclFile := hclwrite.NewEmptyFile()
 
 			// create new file on system
 			path := filepath.Join(workingDir, "lf-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -127,7 +174,7 @@ func (lf *LambdaFunction) Generate(config *common.Config, client *duplosdk.Clien
 			}
 
 			// Lambda Permission Resource
-			lfPermission, _ := client.LambdaPermissionGet(config.TenantId, lf.FunctionName)
+			lfPermission := function.Permissions
 			if lfPermission != nil && len(*lfPermission) > 0 {
 				for i, lfPerm := range *lfPermission {
 					index := strconv.Itoa(i)
@@ -159,9 +206,7 @@ func (lf *LambdaFunction) Generate(config *common.Config, client *duplosdk.Clien
 						cty.StringVal(lfPerm.Sid))
 				}
 			}
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 
@@ -169,9 +214,13 @@ func (lf *LambdaFunction) Generate(config *common.Config, client *duplosdk.Clien
 
 			outVars := generateLFOutputVars(varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_aws_lambda_function."+resourceName, map[string]string{
+				"fullname": lf.FunctionName,
+				"arn":      lf.FunctionArn,
+			})...)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_lambda_function." + resourceName,
 					ResourceId:      config.TenantId + "/" + shortName,
@@ -1,12 +1,12 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"strconv"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -19,31 +19,62 @@ const LB_VAR_PREFIX = "lb_"
 type LoadBalancer struct {
 }
 
-func (lb *LoadBalancer) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "load-balancer",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_load_balancer", "duplocloud_aws_load_balancer_listener", "duplocloud_aws_target_group_attributes"},
+		DuploAPIs:     []string{"TenantGetApplicationLBList", "TenantGetApplicationLbSettings", "TenantListApplicationLbListeners", "DuploAwsTargetGroupAttributesGet", "GetResourcePrefix"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &LoadBalancer{} },
+	})
+}
+
+func (lb *LoadBalancer) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.TenantGetApplicationLBList(config.TenantId)
 	//Get tenant from duplo
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	if list == nil {
+		return fetched.Set(nil)
+	}
+	detailsList := make([]lbDetails, len(*list))
+	fetchErrs := make([]error, len(*list))
+	err := common.ForEachParallel(ctx, config.Workers(), len(*list), func(i int) error {
+		detailsList[i], fetchErrs[i] = fetchLbDetails(client, config.TenantId, (*list)[i])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	lbs := []lbDetails{}
+	for i, lb := range *list {
+		if fetchErrs[i] != nil {
+			fetched.ObjectFailed("load balancer "+lb.Name, fetchErrs[i])
+			continue
+		}
+		lbs = append(lbs, detailsList[i])
+	}
+	return fetched.Set(lbs)
+}
+
+func (lb *LoadBalancer) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]lbDetails
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
 	}
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== Load balancer TF generation started. =====>")
-		for _, lb := range *list {
-			shortName, err := extractLbShortName(client, config.TenantId, lb.Name)
+		for _, details := range *list {
+			lb := details.LB
+			shortName := details.ShortName
 			resourceName := common.GetResourceName(shortName)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-			settings, err := client.TenantGetApplicationLbSettings(config.TenantId, lb.Arn)
-			if err != nil {
-				fmt.Println(err)
-				settings = nil
-			}
+			settings := details.Settings
 			log.Printf("[TRACE] Generating terraform config for duplo aws load balancer : %s", shortName)
 
 			varFullPrefix := LB_VAR_PREFIX + resourceName + "_"
@@ -53,11 +84,6 @@ func (lb *LoadBalancer) Generate(config *common.Config, client *duplosdk.Client)
 
 			// create new file on system
 			path := filepath.Join(workingDir, "lb-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 
 			rootBody := hclFile.Body()
 
@@ -96,16 +122,11 @@ func (lb *LoadBalancer) Generate(config *common.Config, client *duplosdk.Client)
 				}
 			}
 
-			// Fetch all listeners
-			listeners, clientErr := client.TenantListApplicationLbListeners(config.TenantId, shortName)
-			if clientErr != nil {
-				fmt.Println(err)
-				listeners = nil
-			}
+			listeners := details.Listeners
 			rootBody.AppendNewline()
 			log.Printf("[TRACE] Terraform config is generation started for duplo aws load balancer listener : %s", shortName)
 			if listeners != nil {
-				for _, listener := range *listeners {
+				for j, listener := range *listeners {
 					listenerBlock := rootBody.AppendNewBlock("resource",
 						[]string{"duplocloud_aws_load_balancer_listener",
 							resourceName + "_listener_" + strconv.Itoa(listener.Port)})
@@ -145,10 +166,7 @@ func (lb *LoadBalancer) Generate(config *common.Config, client *duplosdk.Client)
 						WorkingDir:      workingDir,
 					})
 
-					getReq := duplosdk.DuploTargetGroupAttributesGetReq{
-						TargetGroupArn: listener.DefaultActions[0].TargetGroupArn,
-					}
-					targetGrpAttrs, _ := client.DuploAwsTargetGroupAttributesGet(config.TenantId, getReq)
+					targetGrpAttrs := details.TargetGrpAttrs[j]
 					if targetGrpAttrs != nil && len(*targetGrpAttrs) > 0 {
 						tgAttrBlock := rootBody.AppendNewBlock("resource",
 							[]string{"duplocloud_aws_target_group_attributes",
@@ -190,18 +208,21 @@ func (lb *LoadBalancer) Generate(config *common.Config, client *duplosdk.Client)
 
 			log.Printf("[TRACE] Terraform config is generated for duplo aws load balancer listener.: %s", shortName)
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo aws load balancer : %s", shortName)
 
 			outVars := generateLBOutputVars(varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_aws_load_balancer."+resourceName, map[string]string{
+				"fullname": lb.Name,
+				"arn":      lb.Arn,
+				"dns_name": lb.DNSName,
+			})...)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_load_balancer." + resourceName,
 					ResourceId:      config.TenantId + "/" + shortName,
@@ -258,3 +279,49 @@ func generateLBOutputVars(prefix, resourceName string) []common.OutputVarConfig
 	}
 	return outVars
 }
+
+// lbDetails holds a load balancer with what is fetched besides the list entry.
+type lbDetails struct {
+	LB             duplosdk.DuploApplicationLB
+	ShortName      string
+	Settings       *duplosdk.DuploAwsLbSettings
+	Listeners      *[]duplosdk.DuploAwsLbListener
+	TargetGrpAttrs []*[]duplosdk.DuploKeyStringValue
+}
+
+func fetchLbDetails(client *duplosdk.Client, tenantID string, lb duplosdk.DuploApplicationLB) (lbDetails, error) {
+	details := lbDetails{LB: lb}
+	shortName, err := extractLbShortName(client, tenantID, lb.Name)
+	if err != nil {
+		return details, err
+	}
+	details.ShortName = shortName
+	settings, clientErr := client.TenantGetApplicationLbSettings(tenantID, lb.Arn)
+	if clientErr != nil {
+		return details, clientErr
+	}
+	details.Settings = settings
+	// Fetch all listeners
+	listeners, clientErr := client.TenantListApplicationLbListeners(tenantID, shortName)
+	if clientErr != nil {
+		return details, clientErr
+	}
+	details.Listeners = listeners
+	if listeners != nil {
+		details.TargetGrpAttrs = make([]*[]duplosdk.DuploKeyStringValue, len(*listeners))
+		for j, listener := range *listeners {
+			if len(listener.DefaultActions) == 0 {
+				continue
+			}
+			getReq := duplosdk.DuploTargetGroupAttributesGetReq{
+				TargetGroupArn: listener.DefaultActions[0].TargetGroupArn,
+			}
+			attrs, clientErr := client.DuploAwsTargetGroupAttributesGet(tenantID, getReq)
+			if clientErr != nil {
+				return details, clientErr
+			}
+			details.TargetGrpAttrs[j] = attrs
+		}
+	}
+	return details, nil
+}
@@ -1,9 +1,8 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"tenant-terraform-generator/duplosdk"
 	tfgenerator "tenant-terraform-generator/tf-generator"
@@ -13,13 +12,26 @@ import (
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclwrite"
-	"github.com/zclconf/go-cty/cty"
 )
 
 type AwsServicesMain struct {
 }
 
-func (asm *AwsServicesMain) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "aws-services-main",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"aws_caller_identity", "aws_region", "duplocloud_tenant_aws_kms_key", "terraform_remote_state"},
+		New:           func() tfgenerator.Generator { return &AwsServicesMain{} },
+	})
+}
+
+// Fetch does nothing, main.tf only depends on the config.
+func (asm *AwsServicesMain) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
+	return nil
+}
+
+func (asm *AwsServicesMain) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
 	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
 
 	log.Println("[TRACE] <====== AWS services main TF generation started. =====>")
@@ -30,11 +42,6 @@ func (asm *AwsServicesMain) Generate(config *common.Config, client *duplosdk.Cli
 
 	// create new file on system
 	path := filepath.Join(workingDir, "main.tf")
-	tfFile, err := os.Create(path)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
-	}
 
 	// initialize the body of the new file object
 	rootBody := hclFile.Body()
@@ -56,12 +63,15 @@ func (asm *AwsServicesMain) Generate(config *common.Config, client *duplosdk.Cli
 	localsBlock := rootBody.AppendNewBlock("locals",
 		nil)
 	localsBlockBody := localsBlock.Body()
-	tfstateBucketTokens := hclwrite.Tokens{
-		{Type: hclsyntax.TokenOQuote, Bytes: []byte(`"`)},
-		{Type: hclsyntax.TokenIdent, Bytes: []byte(`duplo-tfstate-${data.aws_caller_identity.current.account_id}`)},
-		{Type: hclsyntax.TokenCQuote, Bytes: []byte(`"`)},
+	// The remote states read the default S3 bucket of the account, like the scripts.
+	if config.Backend.Type == common.BackendS3 && len(config.Backend.Bucket) == 0 {
+		tfstateBucketTokens := hclwrite.Tokens{
+			{Type: hclsyntax.TokenOQuote, Bytes: []byte(`"`)},
+			{Type: hclsyntax.TokenIdent, Bytes: []byte(`duplo-tfstate-${data.aws_caller_identity.current.account_id}`)},
+			{Type: hclsyntax.TokenCQuote, Bytes: []byte(`"`)},
+		}
+		localsBlockBody.SetAttributeRaw("tfstate_bucket", tfstateBucketTokens)
 	}
-	localsBlockBody.SetAttributeRaw("tfstate_bucket", tfstateBucketTokens)
 
 	localsBlockBody.SetAttributeTraversal("region", hcl.Traversal{
 		hcl.TraverseRoot{
@@ -111,106 +121,23 @@ func (asm *AwsServicesMain) Generate(config *common.Config, client *duplosdk.Cli
 	})
 	rootBody.AppendNewline()
 
-	remoteStateBlock := rootBody.AppendNewBlock("data",
-		[]string{"terraform_remote_state",
-			"tenant"})
-	remoteStateBody := remoteStateBlock.Body()
-	remoteStateBody.SetAttributeValue("backend",
-		cty.StringVal("s3"))
-	remoteStateBody.SetAttributeTraversal("workspace", hcl.Traversal{
-		hcl.TraverseRoot{
-			Name: "terraform",
-		},
-		hcl.TraverseAttr{
-			Name: "workspace",
-		},
-	})
+	tfgenerator.AppendRemoteState(rootBody, config, "tenant", config.TenantProject)
 
-	//configMap := map[string]cty.Value{}
-	// tokens := hclwrite.Tokens{
-	// 	//{Type: hclsyntax.TokenOQuote, Bytes: []byte(`"`)},
-	// 	//{Type: hclsyntax.TokenTemplateInterp, Bytes: []byte(`${`)},
-	// 	{Type: hclsyntax.TokenIdent, Bytes: []byte(`local.tfstate_bucket`)},
-	// 	//{Type: hclsyntax.TokenTemplateSeqEnd, Bytes: []byte(`}`)},
-	// 	//{Type: hclsyntax.TokenCQuote, Bytes: []byte(`"`)},
-	// }
-	//remoteStateBody.SetAttributeRaw("bucket", tokens)
-
-	// configTokens := map[string]hclwrite.Tokens{
-	// 	"bucket": hclwrite.TokensForTraversal(hcl.Traversal{
-	// 		hcl.TraverseRoot{Name: "local"},
-	// 		hcl.TraverseAttr{Name: "tfstate_bucket"},
-	// 	}),
-	// 	"workspace_key_prefix": hclwrite.TokensForValue(cty.StringVal("admin:")),
-	// 	"key":                  hclwrite.TokensForValue(cty.StringVal("tenant")),
-	// 	"region": hclwrite.TokensForTraversal(hcl.Traversal{
-	// 		hcl.TraverseRoot{Name: "local"},
-	// 		hcl.TraverseAttr{Name: "region"},
-	// 	}),
-	// }
-	configTokens := []tfgenerator.ObjectAttrTokens{
-		{
-			Name: hclwrite.TokensForTraversal(hcl.Traversal{
-				hcl.TraverseRoot{Name: "bucket"},
-			}),
-			Value: hclwrite.TokensForTraversal(hcl.Traversal{
-				hcl.TraverseRoot{Name: "local"},
-				hcl.TraverseAttr{Name: "tfstate_bucket"},
-			}),
-		},
-		{
-			Name: hclwrite.TokensForTraversal(hcl.Traversal{
-				hcl.TraverseRoot{Name: "workspace_key_prefix"},
-			}),
-			Value: hclwrite.TokensForValue(cty.StringVal("admin:")),
-		},
-		{
-			Name: hclwrite.TokensForTraversal(hcl.Traversal{
-				hcl.TraverseRoot{Name: "key"},
-			}),
-			Value: hclwrite.TokensForValue(cty.StringVal("tenant")),
-		},
-		{
-			Name: hclwrite.TokensForTraversal(hcl.Traversal{
-				hcl.TraverseRoot{Name: "region"},
-			}),
-			Value: hclwrite.TokensForTraversal(hcl.Traversal{
-				hcl.TraverseRoot{Name: "local"},
-				hcl.TraverseAttr{Name: "region"},
-			}),
-		},
-	}
-	tokens := tfgenerator.TokensForObject(configTokens)
-	remoteStateBody.SetAttributeRaw("config", tokens)
-	// 	cty.ObjectVal(configMap))
-
-	// configMap["bucket"] = cty.StringVal("${local.tfstate_bucket}")
-	// configMap["workspace_key_prefix"] = cty.StringVal("admin:")
-	// configMap["key"] = cty.StringVal("tenant")
-	// configMap["region"] = cty.StringVal("${local.region}")
-
-	// //configMap["region"] = cty.CapsuleVal("${local.region}")
-	// remoteStateBody.SetAttributeValue("config",
-	// 	cty.ObjectVal(configMap))
-
-	fmt.Printf("%s", hclFile.Bytes())
-	_, err = tfFile.Write(hclFile.Bytes())
-	if err != nil {
-		fmt.Println(err)
+	if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 		return nil, err
 	}
 	log.Println("[TRACE] <====== Aws services main TF generation done. =====>")
 	return &common.TFContext{
-		InputVars: generateVars(),
+		InputVars: generateVars(config),
 	}, nil
 }
 
-func generateVars() []common.VarConfig {
+func generateVars(config *common.Config) []common.VarConfig {
 	varConfigs := make(map[string]common.VarConfig)
 
 	regionVar := common.VarConfig{
 		Name:       "region",
-		DefaultVal: "us-west-2",
+		DefaultVal: config.Region,
 		TypeVal:    "string",
 	}
 	varConfigs["region"] = regionVar
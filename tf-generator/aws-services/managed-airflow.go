@@ -1,11 +1,11 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -18,46 +18,94 @@ const MWAA_VAR_PREFIX = "mwaa_"
 type MWAA struct {
 }
 
-func (mwaa *MWAA) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "mwaa",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_mwaa_environment"},
+		DuploAPIs:     []string{"MwaaAirflowList", "MwaaAirflowDetailsGet", "TenantGetTenantKmsKey"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &MWAA{} },
+	})
+}
+
+// mwaaEnvironment is an airflow environment with its details.
+type mwaaEnvironment struct {
+	Environment duplosdk.DuploMwaaAirflowSummary
+	Details     *duplosdk.DuploMwaaAirflowDetail
+}
+
+// mwaaSnapshot holds the duplo objects rendered by the airflow generator.
+type mwaaSnapshot struct {
+	List         *[]mwaaEnvironment
+	TenantKmsKey *duplosdk.DuploAwsKmsKey
+}
+
+func (mwaa *MWAA) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.MwaaAirflowList(config.TenantId)
 	//Get tenant from duplo
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, nil
+		return clientErr
 	}
-	prefix, clientErr := client.GetDuploServicesPrefix(config.TenantId)
-	if clientErr != nil {
-		return nil, clientErr
+	if list == nil {
+		return fetched.Set(mwaaSnapshot{})
+	}
+	data := mwaaSnapshot{List: &[]mwaaEnvironment{}}
+	kms, kmsClientErr := client.TenantGetTenantKmsKey(config.TenantId)
+	if kmsClientErr == nil {
+		data.TenantKmsKey = kms
 	}
+	environments := make([]mwaaEnvironment, len(*list))
+	fetchErrs := make([]error, len(*list))
+	err := common.ForEachParallel(ctx, config.Workers(), len(*list), func(i int) error {
+		mwaaDetails, clientErr := client.MwaaAirflowDetailsGet(config.TenantId, (*list)[i].Name)
+		if clientErr != nil {
+			fetchErrs[i] = clientErr
+			return nil
+		}
+		environments[i] = mwaaEnvironment{Environment: (*list)[i], Details: mwaaDetails}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, mwaa := range *list {
+		if fetchErrs[i] != nil {
+			fetched.ObjectFailed("airflow environment "+mwaa.Name, fetchErrs[i])
+			continue
+		}
+		*data.List = append(*data.List, environments[i])
+	}
+	return fetched.Set(data)
+}
+
+func (mwaa *MWAA) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	data := mwaaSnapshot{}
+	if err := fetched.Decode(&data); err != nil {
+		return nil, err
+	}
+	list, kms := data.List, data.TenantKmsKey
+	prefix := config.DuploServicesPrefix()
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== AWS Apache Airflow TF generation started. =====>")
-		kms, kmsClientErr := client.TenantGetTenantKmsKey(config.TenantId)
-		for _, mwaa := range *list {
+		for _, environment := range *list {
+			mwaa := environment.Environment
 			shortName, _ := duplosdk.UnprefixName(prefix, mwaa.Name)
 			resourceName := common.GetResourceName(shortName)
 			log.Printf("[TRACE] Generating terraform config for duplo AWS Apache Airflow : %s", mwaa.Name)
 
 			varFullPrefix := MWAA_VAR_PREFIX + resourceName + "_"
-			mwaaDetails, clientErr := client.MwaaAirflowDetailsGet(config.TenantId, mwaa.Name)
-			if clientErr != nil {
-				fmt.Println(clientErr)
-				return nil, clientErr
-			}
+			mwaaDetails := environment.Details
 
 			// create new empty hcl file object
 			hclFile := hclwrite.NewEmptyFile()
 
 			// create new file on system
 			path := filepath.Join(workingDir, "mwaa-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -76,34 +124,9 @@ func (mwaa *MWAA) Generate(config *common.Config, client *duplosdk.Client) (*com
 			})
 			mwaaBody.SetAttributeValue("name", cty.StringVal(shortName))
 
+			// The ARN becomes a reference to the bucket when the bucket is generated too.
 			if len(mwaaDetails.SourceBucketArn) > 0 {
-				resourceName := duplosdk.UnwrapResoureNameFromAwsArn(mwaaDetails.SourceBucketArn)
-				s3, clientErr := client.TenantGetS3BucketSettings(config.TenantId, resourceName)
-				if s3 != nil && clientErr == nil && s3.Arn == mwaaDetails.SourceBucketArn {
-					// s3ShortName := s3.Name
-
-					// if strings.HasPrefix(s3.Name, "duploservices-") {
-					// 	s3ShortName = s3.Name[len("duploservices-"+config.TenantName+"-"):len(s3.Name)]
-					// 	parts := strings.Split(s3ShortName, "-")
-					// 	if len(parts) > 0 {
-					// 		parts = parts[:len(parts)-1]
-					// 	}
-					// 	s3ShortName = strings.Join(parts, "-")
-					// }
-
-					// mwaaBody.SetAttributeTraversal("source_bucket_arn", hcl.Traversal{
-					// 	hcl.TraverseRoot{
-					// 		Name: "duplocloud_s3_bucket." + s3ShortName,
-					// 	},
-					// 	hcl.TraverseAttr{
-					// 		Name: "arn",
-					// 	},
-					// })
-					common.Interpolate(mwaaBody, *config, s3.Name, "source_bucket_arn", common.S3, "arn")
-				} else {
-					mwaaBody.SetAttributeValue("source_bucket_arn", cty.StringVal(mwaaDetails.SourceBucketArn))
-				}
-
+				mwaaBody.SetAttributeValue("source_bucket_arn", cty.StringVal(mwaaDetails.SourceBucketArn))
 			}
 
 			if len(mwaaDetails.DagS3Path) > 0 {
@@ -111,7 +134,7 @@ func (mwaa *MWAA) Generate(config *common.Config, client *duplosdk.Client) (*com
 			}
 
 			if len(mwaaDetails.KmsKey) > 0 {
-				if kms != nil && kmsClientErr == nil && (mwaaDetails.KmsKey == kms.KeyArn || mwaaDetails.KmsKey == kms.KeyID) {
+				if kms != nil && (mwaaDetails.KmsKey == kms.KeyArn || mwaaDetails.KmsKey == kms.KeyID) {
 					mwaaBody.SetAttributeTraversal("kms_key", hcl.Traversal{
 						hcl.TraverseRoot{
 							Name: "data.duplocloud_tenant_aws_kms_key.tenant_kms",
@@ -234,17 +257,19 @@ func (mwaa *MWAA) Generate(config *common.Config, client *duplosdk.Client) (*com
 			}
 
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo AWS Apache Airflow : %s", mwaa.Name)
 
 			outVars := generateMWAAOutputVars(varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_aws_mwaa_environment."+resourceName, map[string]string{
+				"arn":           mwaaDetails.Arn,
+				"webserver_url": mwaaDetails.WebserverUrl,
+			})...)
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_mwaa_environment." + resourceName,
 					ResourceId:      config.TenantId + "/" + mwaa.Name,
@@ -1,12 +1,13 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -20,15 +21,33 @@ const RDS_VAR_PREFIX = "rds_"
 type Rds struct {
 }
 
-func (r *Rds) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
-	list, clientErr := client.RdsInstanceList(config.TenantId)
-	//Get tenant from duplo
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "rds",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_rds_instance"},
+		DuploAPIs:     []string{"RdsInstanceList"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &Rds{} },
+	})
+}
 
+func (r *Rds) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
+	list, clientErr := client.RdsInstanceList(config.TenantId)
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	return fetched.Set(list)
+}
+
+func (r *Rds) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]duplosdk.DuploRdsInstance
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
 	}
+	//Get tenant from duplo
+
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
@@ -47,15 +66,11 @@ func (r *Rds) Generate(config *common.Config, client *duplosdk.Client) (*common.
 
 			// create new file on system
 			path := filepath.Join(workingDir, "rds-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
-			if len(rds.MasterPassword) > 0 {
+			// master_password refers to the generated password unless the instance is restored from a snapshot.
+			if len(rds.SnapshotID) == 0 {
 				randomBlock := rootBody.AppendNewBlock("resource",
 					[]string{"random_password",
 						resourceName + "_password"})
@@ -149,17 +164,21 @@ func (r *Rds) Generate(config *common.Config, client *duplosdk.Client) (*common.
 			rdsBody.SetAttributeValue("multi_az",
 				cty.BoolVal(rds.MultiAZ))
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo RDS instance : %s", rds.Identifier)
 
 			outVars := generateRdsOutputVars(varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_rds_instance."+resourceName, map[string]string{
+				"identifier": rds.Identifier,
+				"arn":        rds.Arn,
+				"endpoint":   rds.Endpoint,
+				"host":       endpointHost(rds.Endpoint),
+			})...)
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_rds_instance." + resourceName,
 					ResourceId:      "v2/subscriptions/" + config.TenantId + "/RDSDBInstance/" + shortName,
@@ -261,3 +280,11 @@ func generateRdsOutputVars(prefix, resourceName string) []common.OutputVarConfig
 	}
 	return outVars
 }
+
+// endpointHost returns the host of an endpoint made of a host and a port.
+func endpointHost(endpoint string) string {
+	if i := strings.LastIndex(endpoint, ":"); i > 0 {
+		return endpoint[:i]
+	}
+	return endpoint
+}
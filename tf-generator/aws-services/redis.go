@@ -1,12 +1,12 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"strconv"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -20,20 +20,52 @@ const REDIS_VAR_PREFIX = "redis_"
 type Redis struct {
 }
 
-func (r *Redis) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "redis",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_ecache_instance"},
+		DuploAPIs:     []string{"EcacheInstanceList", "TenantGetTenantKmsKey"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &Redis{} },
+	})
+}
+
+// redisSnapshot holds the duplo objects rendered by the redis generator.
+type redisSnapshot struct {
+	List *[]duplosdk.DuploEcacheInstance
+	// TenantKmsKey is nil when it could not be read, the instances then reference their key by ARN.
+	TenantKmsKey *duplosdk.DuploAwsKmsKey
+}
+
+func (r *Redis) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.EcacheInstanceList(config.TenantId)
 	//Get tenant from duplo
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	data := redisSnapshot{List: list}
+	if list != nil {
+		kms, kmsClientErr := client.TenantGetTenantKmsKey(config.TenantId)
+		if kmsClientErr == nil {
+			data.TenantKmsKey = kms
+		}
+	}
+	return fetched.Set(data)
+}
+
+func (r *Redis) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	data := redisSnapshot{}
+	if err := fetched.Decode(&data); err != nil {
+		return nil, err
 	}
+	list, kms := data.List, data.TenantKmsKey
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== Redis TF generation started. =====>")
-		kms, kmsClientErr := client.TenantGetTenantKmsKey(config.TenantId)
 		for _, redis := range *list {
 			shortName := redis.Identifier[len("duplo-"):len(redis.Identifier)]
 			resourceName := common.GetResourceName(shortName)
@@ -48,11 +80,6 @@ func (r *Redis) Generate(config *common.Config, client *duplosdk.Client) (*commo
 
 			// create new file on system
 			path := filepath.Join(workingDir, "redis-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -108,7 +135,7 @@ func (r *Redis) Generate(config *common.Config, client *duplosdk.Client) (*commo
 					cty.StringVal(redis.AuthToken))
 			}
 			if len(redis.KMSKeyID) > 0 {
-				if kms != nil && kmsClientErr == nil && (redis.KMSKeyID == kms.KeyArn || redis.KMSKeyID == kms.KeyID) {
+				if kms != nil && (redis.KMSKeyID == kms.KeyArn || redis.KMSKeyID == kms.KeyID) {
 					redisBody.SetAttributeTraversal("kms_key_id", hcl.Traversal{
 						hcl.TraverseRoot{
 							Name: "data.duplocloud_tenant_aws_kms_key.tenant_kms",
@@ -124,18 +151,22 @@ func (r *Redis) Generate(config *common.Config, client *duplosdk.Client) (*commo
 			}
 
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo redis instance : %s", redis.Identifier)
 
 			outVars := generateRedisOutputVars(varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_ecache_instance."+resourceName, map[string]string{
+				"identifier": redis.Identifier,
+				"arn":        redis.Arn,
+				"endpoint":   redis.Endpoint,
+				"host":       endpointHost(redis.Endpoint),
+			})...)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_ecache_instance." + resourceName,
 					ResourceId:      "v2/subscriptions/" + config.TenantId + "/ECacheDBInstance/" + shortName,
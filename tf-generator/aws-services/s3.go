@@ -1,13 +1,14 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"log"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -17,47 +18,107 @@ import (
 
 const S3_VAR_PREFIX = "s3_"
 
+// S3_MODULE is the local module of a bucket, instantiated for each entry of the S3_MODULE_VAR map with --modules.
+const S3_MODULE = "duplo-s3-bucket"
+const S3_MODULE_VAR = "s3_buckets"
+
+// s3SettingsType is the type of the entries of the S3_MODULE_VAR map, the settings variable of the module.
+var s3SettingsType = cty.Object(map[string]cty.Type{
+	"allow_public_access": cty.Bool,
+	"enable_access_logs":  cty.Bool,
+	"enable_versioning":   cty.Bool,
+	"managed_policies":    cty.List(cty.String),
+	"default_encryption":  cty.String,
+})
+
 type S3Bucket struct {
 }
 
-func (s3 *S3Bucket) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "s3",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_s3_bucket"},
+		DuploAPIs:     []string{"TenantListS3Buckets", "TenantGetS3BucketSettings"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &S3Bucket{} },
+	})
+}
+
+// errS3SettingsMissing is reported for the buckets of a snapshot recorded without their settings.
+var errS3SettingsMissing = errors.New("the settings of the bucket were not read")
+
+// s3Bucket is a bucket with its settings.
+type s3Bucket struct {
+	Bucket   duplosdk.DuploS3Bucket
+	Settings *duplosdk.DuploS3Bucket
+}
+
+func (s3 *S3Bucket) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.TenantListS3Buckets(config.TenantId)
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	if list == nil {
+		return fetched.Set(nil)
+	}
+	buckets := make([]s3Bucket, len(*list))
+	fetchErrs := make([]error, len(*list))
+	err := common.ForEachParallel(ctx, config.Workers(), len(*list), func(i int) error {
+		settings, clientErr := client.TenantGetS3BucketSettings(config.TenantId, (*list)[i].Name)
+		if clientErr != nil {
+			fetchErrs[i] = clientErr
+			return nil
+		}
+		buckets[i] = s3Bucket{Bucket: (*list)[i], Settings: settings}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fetchedBuckets := []s3Bucket{}
+	for i, bucket := range *list {
+		if fetchErrs[i] != nil {
+			fetched.ObjectFailed("s3 bucket "+bucket.Name, fetchErrs[i])
+			continue
+		}
+		fetchedBuckets = append(fetchedBuckets, buckets[i])
+	}
+	return fetched.Set(fetchedBuckets)
+}
+
+func (s3 *S3Bucket) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]s3Bucket
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
+	}
+	if list != nil && config.Modules {
+		return renderS3Module(config, *list)
 	}
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
 		log.Println("[TRACE] <====== S3 bucket TF generation started. =====>")
-		for _, s3 := range *list {
-			shortName := s3.Name
-			if strings.HasPrefix(s3.Name, "duploservices-") {
-				shortName = s3.Name[len("duploservices-"+config.TenantName+"-"):len(s3.Name)]
-				parts := strings.Split(shortName, "-")
-				if len(parts) > 0 {
-					parts = parts[:len(parts)-1]
-				}
-				shortName = strings.Join(parts, "-")
-			}
+		for _, bucket := range *list {
+			s3 := bucket.Bucket
+			shortName := s3ShortName(config, s3.Name)
 			resourceName := common.GetResourceName(shortName)
+			if bucket.Settings == nil {
+				tfContext.ObjectFailed("s3 bucket "+s3.Name, errS3SettingsMissing)
+				continue
+			}
 			log.Printf("[TRACE] Generating terraform config for duplo s3 bucket : %s", shortName)
 			varFullPrefix := S3_VAR_PREFIX + resourceName + "_"
 			// create new empty hcl file object
 			hclFile := hclwrite.NewEmptyFile()
-			s3Settings, _ := client.TenantGetS3BucketSettings(config.TenantId, s3.Name)
+			s3Settings := bucket.Settings
 			inputVars := generateS3Vars(s3Settings, varFullPrefix)
 			tfContext.InputVars = append(tfContext.InputVars, inputVars...)
 
 			// create new file on system
 			path := filepath.Join(workingDir, "s3-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -127,18 +188,20 @@ func (s3 *S3Bucket) Generate(config *common.Config, client *duplosdk.Client) (*c
 			defaultEncrBody.SetAttributeValue("method",
 				cty.StringVal(encryptionMethod))
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo s3 bucket : %s", shortName)
 
-			outVars := generateS3OutputVars(s3, varFullPrefix, resourceName)
+			outVars := generateS3OutputVars(varFullPrefix, "duplocloud_s3_bucket."+resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_s3_bucket."+resourceName, map[string]string{
+				"fullname": s3.Name,
+				"arn":      s3.Arn,
+			})...)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_s3_bucket." + resourceName,
 					ResourceId:      config.TenantId + "/" + shortName,
@@ -152,6 +215,89 @@ func (s3 *S3Bucket) Generate(config *common.Config, client *duplosdk.Client) (*c
 	return &tfContext, nil
 }
 
+// s3ShortName returns the name of a bucket without the prefix of the tenant and the account suffix.
+func s3ShortName(config *common.Config, name string) string {
+	shortName := name
+	if strings.HasPrefix(name, "duploservices-") {
+		shortName = name[len("duploservices-"+config.TenantName+"-"):len(name)]
+		parts := strings.Split(shortName, "-")
+		if len(parts) > 0 {
+			parts = parts[:len(parts)-1]
+		}
+		shortName = strings.Join(parts, "-")
+	}
+	return shortName
+}
+
+// renderS3Module writes the buckets as the entries of the s3_buckets variable, for each of which the project
+// instantiates the duplo-s3-bucket module.
+func renderS3Module(config *common.Config, buckets []s3Bucket) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	tfContext := common.TFContext{}
+	if len(buckets) == 0 {
+		return &tfContext, nil
+	}
+	log.Println("[TRACE] <====== S3 bucket module TF generation started. =====>")
+	entries := map[string]cty.Value{}
+	for _, bucket := range buckets {
+		s3 := bucket.Bucket
+		shortName := s3ShortName(config, s3.Name)
+		settings := bucket.Settings
+		if settings == nil {
+			tfContext.ObjectFailed("s3 bucket "+s3.Name, errS3SettingsMissing)
+			continue
+		}
+		log.Printf("[TRACE] Generating terraform module settings for duplo s3 bucket : %s", shortName)
+		policies := cty.NullVal(cty.List(cty.String))
+		if len(s3.Policies) > 0 {
+			var vals []cty.Value
+			for _, p := range s3.Policies {
+				vals = append(vals, cty.StringVal(p))
+			}
+			policies = cty.ListVal(vals)
+		}
+		encryptionMethod := "Sse"
+		if len(s3.DefaultEncryption) > 0 {
+			encryptionMethod = s3.DefaultEncryption
+		}
+		entries[shortName] = cty.ObjectVal(map[string]cty.Value{
+			"allow_public_access": cty.BoolVal(settings.AllowPublicAccess),
+			"enable_access_logs":  cty.BoolVal(settings.EnableAccessLogs),
+			"enable_versioning":   cty.BoolVal(settings.EnableVersioning),
+			"managed_policies":    policies,
+			"default_encryption":  cty.StringVal(encryptionMethod),
+		})
+
+		address := tfgenerator.ModuleAddress(S3_MODULE_VAR, shortName)
+		tfContext.OutputVars = append(tfContext.OutputVars, generateS3OutputVars(S3_VAR_PREFIX+common.GetResourceName(shortName)+"_", address)...)
+		tfContext.References = append(tfContext.References, common.ResourceReferences(address, map[string]string{
+			"fullname": s3.Name,
+			"arn":      s3.Arn,
+		})...)
+		if config.ImportsEnabled() {
+			tfContext.ImportConfigs = append(tfContext.ImportConfigs, common.ImportConfig{
+				ResourceAddress: address + ".duplocloud_s3_bucket.this",
+				ResourceId:      config.TenantId + "/" + shortName,
+				WorkingDir:      workingDir,
+			})
+		}
+	}
+	tfContext.InputVars = append(tfContext.InputVars, tfgenerator.MapVar(S3_MODULE_VAR, s3SettingsType, entries, "The settings of the S3 buckets, by name."))
+
+	if err := tfgenerator.WriteModule(config, S3_MODULE); err != nil {
+		return nil, err
+	}
+	hclFile := hclwrite.NewEmptyFile()
+	tfgenerator.AppendModuleBlock(hclFile.Body(), S3_MODULE_VAR, S3_MODULE, []tfgenerator.ModuleAttr{
+		{Name: "tenant_id", Value: tfgenerator.LocalTraversal("tenant_id")},
+	})
+	if err := config.Output.WriteFile(filepath.Join(workingDir, "s3.tf"), hclFile.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	log.Println("[TRACE] <====== S3 bucket module TF generation done. =====>")
+	return &tfContext, nil
+}
+
 func generateS3Vars(duplo *duplosdk.DuploS3Bucket, prefix string) []common.VarConfig {
 	varConfigs := make(map[string]common.VarConfig)
 
@@ -183,12 +329,13 @@ func generateS3Vars(duplo *duplosdk.DuploS3Bucket, prefix string) []common.VarCo
 	return vars
 }
 
-func generateS3OutputVars(duplo duplosdk.DuploS3Bucket, prefix, resourceName string) []common.OutputVarConfig {
+// generateS3OutputVars returns the outputs of the bucket at address, a resource or a module instance.
+func generateS3OutputVars(prefix, address string) []common.OutputVarConfig {
 	outVarConfigs := make(map[string]common.OutputVarConfig)
 
 	fullNameVar := common.OutputVarConfig{
 		Name:          prefix + "fullname",
-		ActualVal:     "duplocloud_s3_bucket." + resourceName + ".fullname",
+		ActualVal:     address + ".fullname",
 		DescVal:       "The full name of the S3 bucket.",
 		RootTraversal: true,
 	}
@@ -196,7 +343,7 @@ func generateS3OutputVars(duplo duplosdk.DuploS3Bucket, prefix, resourceName str
 
 	arnVar := common.OutputVarConfig{
 		Name:          prefix + "arn",
-		ActualVal:     "duplocloud_s3_bucket." + resourceName + ".arn",
+		ActualVal:     address + ".arn",
 		DescVal:       "The ARN of the S3 bucket.",
 		RootTraversal: true,
 	}
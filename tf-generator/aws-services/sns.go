@@ -1,12 +1,12 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -19,29 +19,50 @@ const SNS_VAR_PREFIX = "sns_"
 type SNS struct {
 }
 
-func (sns *SNS) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	log.Println("[TRACE] <====== SNS Topic TF generation started. =====>")
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "sns",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_sns_topic"},
+		DuploAPIs:     []string{"TenantListSnsTopic", "TenantGetTenantKmsKey"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &SNS{} },
+	})
+}
+
+// snsSnapshot holds the duplo objects rendered by the sns generator.
+type snsSnapshot struct {
+	List         *[]duplosdk.DuploAwsResource
+	TenantKmsKey *duplosdk.DuploAwsKmsKey
+}
+
+func (sns *SNS) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.TenantListSnsTopic(config.TenantId)
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
 	}
 	tenantKms, clientErr := client.TenantGetTenantKmsKey(config.TenantId)
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
 	}
+	return fetched.Set(snsSnapshot{List: list, TenantKmsKey: tenantKms})
+}
+
+func (sns *SNS) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	log.Println("[TRACE] <====== SNS Topic TF generation started. =====>")
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	data := snsSnapshot{}
+	if err := fetched.Decode(&data); err != nil {
+		return nil, err
+	}
+	list, tenantKms := data.List, data.TenantKmsKey
 	tfContext := common.TFContext{}
 	importConfigs := []common.ImportConfig{}
 	if list != nil {
+		prefix := config.DuploServicesPrefix()
 		for _, sns := range *list {
-			// shortName, err := extractSnsTopicName(client, config.TenantId, sns.Name)
-			shortName, err := extractSnsTopicName(client, config.TenantId, sns.Name)
+			shortName := extractSnsTopicName(config.AccountID, prefix, sns.Name)
 			resourceName := common.GetResourceName(shortName)
-			if err != nil {
-				return nil, err
-			}
 			log.Printf("[TRACE] Generating terraform config for duplo SNS Topic : %s", shortName)
 			varFullPrefix := SNS_VAR_PREFIX + resourceName + "_"
 			// create new empty hcl file object
@@ -49,11 +70,6 @@ func (sns *SNS) Generate(config *common.Config, client *duplosdk.Client) (*commo
 
 			// create new file on system
 			path := filepath.Join(workingDir, "sns-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -75,18 +91,19 @@ func (sns *SNS) Generate(config *common.Config, client *duplosdk.Client) (*commo
 			snsBody.SetAttributeValue("kms_key_id",
 				cty.StringVal(tenantKms.KeyArn))
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo SNS Topic : %s", shortName)
 
 			outVars := generateSnsOutputVars(sns, varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_aws_sns_topic."+resourceName, map[string]string{
+				"arn": sns.Name,
+			})...)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_sns_topic." + resourceName,
 					ResourceId:      config.TenantId + "/" + sns.Name,
@@ -118,17 +135,9 @@ func generateSnsOutputVars(duplo duplosdk.DuploAwsResource, prefix, resourceName
 	return outVars
 }
 
-func extractSnsTopicName(client *duplosdk.Client, tenantID string, topicName string) (string, error) {
-	accountID, err := client.TenantGetAwsAccountID(tenantID)
-	if err != nil {
-		return "", err
-	}
-	prefix, err := client.GetDuploServicesPrefix(tenantID)
-	if err != nil {
-		return "", err
-	}
+func extractSnsTopicName(accountID string, prefix string, topicName string) string {
 	parts := strings.Split(topicName, ":"+accountID+":")
 	fullname := parts[1]
 	name, _ := duplosdk.UnwrapName(prefix, accountID, fullname, true)
-	return name, nil
+	return name
 }
@@ -1,12 +1,12 @@
 package awsservices
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -19,23 +19,39 @@ const SQS_VAR_PREFIX = "sqs_"
 type SQS struct {
 }
 
-func (sqs *SQS) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	log.Println("[TRACE] <====== SQS TF generation started. =====>")
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "sqs",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_sqs_queue"},
+		DuploAPIs:     []string{"TenantListSQS"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &SQS{} },
+	})
+}
+
+func (sqs *SQS) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.TenantListSQS(config.TenantId)
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	return fetched.Set(list)
+}
+
+func (sqs *SQS) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	log.Println("[TRACE] <====== SQS TF generation started. =====>")
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]duplosdk.DuploAwsResource
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
 	}
 	tfContext := common.TFContext{}
 	if list != nil {
+		prefix := config.DuploServicesPrefix()
 		for _, sqs := range *list {
-			shortName, err := extractSqsName(client, config.TenantId, sqs.Name)
+			shortName := extractSqsName(config.AccountID, prefix, sqs.Name)
 			resourceName := common.GetResourceName(shortName)
-			if err != nil {
-				return nil, err
-			}
 			log.Printf("[TRACE] Generating terraform config for duplo SQS : %s", shortName)
 			varFullPrefix := SQS_VAR_PREFIX + resourceName + "_"
 			// create new empty hcl file object
@@ -43,11 +59,6 @@ func (sqs *SQS) Generate(config *common.Config, client *duplosdk.Client) (*commo
 
 			// create new file on system
 			path := filepath.Join(workingDir, "sqs-"+shortName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -71,18 +82,20 @@ func (sqs *SQS) Generate(config *common.Config, client *duplosdk.Client) (*commo
 					cty.BoolVal(strings.HasSuffix(sqs.Name, ".fifo")))
 			}
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo SQS : %s", shortName)
 
 			outVars := generateSQSOutputVars(sqs, varFullPrefix, resourceName)
 			tfContext.OutputVars = append(tfContext.OutputVars, outVars...)
+			tfContext.References = append(tfContext.References, common.ResourceReferences("duplocloud_aws_sqs_queue."+resourceName, map[string]string{
+				"url": sqs.Name,
+				"arn": sqsArn(sqs.Name),
+			})...)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs := []common.ImportConfig{}
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_sqs_queue." + resourceName,
@@ -115,20 +128,23 @@ func generateSQSOutputVars(duplo duplosdk.DuploAwsResource, prefix, resourceName
 	return outVars
 }
 
-func extractSqsName(client *duplosdk.Client, tenantID string, sqsUrl string) (string, error) {
-	accountID, err := client.TenantGetAwsAccountID(tenantID)
-	if err != nil {
-		return "", err
-	}
-	prefix, err := client.GetDuploServicesPrefix(tenantID)
-	if err != nil {
-		return "", err
-	}
+func extractSqsName(accountID string, prefix string, sqsUrl string) string {
 	parts := strings.Split(sqsUrl, "/"+accountID+"/")
 	fullname := parts[1]
 	if strings.HasSuffix(fullname, ".fifo") {
 		fullname = strings.TrimSuffix(fullname, ".fifo")
 	}
 	name, _ := duplosdk.UnwrapName(prefix, accountID, fullname, true)
-	return name, nil
+	return name
+}
+
+// sqsArn returns the ARN of the queue at sqsUrl, e.g. https://sqs.us-west-2.amazonaws.com/123456789012/name,
+// or an empty string when the URL has another form.
+func sqsArn(sqsUrl string) string {
+	parts := strings.Split(strings.TrimPrefix(sqsUrl, "https://"), "/")
+	host := strings.Split(parts[0], ".")
+	if len(parts) != 3 || len(host) < 3 || host[0] != "sqs" {
+		return ""
+	}
+	return "arn:aws:sqs:" + host[1] + ":" + parts[1] + ":" + parts[2]
 }
@@ -1,13 +1,13 @@
 package awsservices
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -21,19 +21,69 @@ const SSM_VAR_PREFIX = "ssm_"
 type SsmParams struct {
 }
 
-func (ssmParams *SsmParams) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	log.Println("[TRACE] <====== Ssm params TF generation started. =====>")
-	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "ssm-params",
+		Project:       tfgenerator.ProjectAwsServices,
+		ResourceTypes: []string{"duplocloud_aws_ssm_parameter"},
+		DuploAPIs:     []string{"SsmParameterList", "SsmParameterGet"},
+		DependsOn:     []string{"aws-services-main"},
+		New:           func() tfgenerator.Generator { return &SsmParams{} },
+	})
+}
+
+// ssmParameter is an SSM parameter with its value.
+type ssmParameter struct {
+	Parameter duplosdk.DuploSsmParameter
+	Details   *duplosdk.DuploSsmParameter
+}
+
+func (ssmParams *SsmParams) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.SsmParameterList(config.TenantId)
 	//Get tenant from duplo
 
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	if list == nil {
+		return fetched.Set(nil)
+	}
+	detailsList := make([]*duplosdk.DuploSsmParameter, len(*list))
+	fetchErrs := make([]error, len(*list))
+	err := common.ForEachParallel(ctx, config.Workers(), len(*list), func(i int) error {
+		ssmDetails, clientErr := client.SsmParameterGet(config.TenantId, (*list)[i].Name)
+		if clientErr != nil {
+			fetchErrs[i] = clientErr
+			return nil
+		}
+		detailsList[i] = ssmDetails
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	params := []ssmParameter{}
+	for i, param := range *list {
+		if fetchErrs[i] != nil {
+			fetched.ObjectFailed("ssm parameter "+param.Name, fetchErrs[i])
+			continue
+		}
+		params = append(params, ssmParameter{Parameter: param, Details: detailsList[i]})
+	}
+	return fetched.Set(params)
+}
+
+func (ssmParams *SsmParams) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	log.Println("[TRACE] <====== Ssm params TF generation started. =====>")
+	workingDir := filepath.Join(config.TFCodePath, config.AwsServicesProject)
+	var list *[]ssmParameter
+	if err := fetched.Decode(&list); err != nil {
+		return nil, err
 	}
 	tfContext := common.TFContext{}
 	if list != nil {
-		for _, ssmParam := range *list {
+		for _, param := range *list {
+			ssmParam := param.Parameter
 			shortName := ssmParam.Name
 			resourceName := common.GetResourceName(shortName)
 			log.Printf("[TRACE] Generating terraform config for duplo SSM Parameter : %s", shortName)
@@ -43,17 +93,8 @@ func (ssmParams *SsmParams) Generate(config *common.Config, client *duplosdk.Cli
 
 			// create new file on system
 			path := filepath.Join(workingDir, "ssm-param-"+resourceName+".tf")
-			tfFile, err := os.Create(path)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
 
-			ssmDetails, err := client.SsmParameterGet(config.TenantId, shortName)
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
+			ssmDetails := param.Details
 			// initialize the body of the new file object
 			rootBody := hclFile.Body()
 
@@ -81,19 +122,17 @@ func (ssmParams *SsmParams) Generate(config *common.Config, client *duplosdk.Cli
 			ssmParamBody.SetAttributeValue("type",
 				cty.StringVal(ssmParam.Type))
 
-			if len(ssmDetails.Value) > 0 {
+			if len(ssmDetails.Value) > 0 && ssmParam.Type == "SecureString" && config.ExternalSecrets() {
+				ssmParamBody.SetAttributeTraversal("value",
+					tfContext.SensitiveVar(SSM_VAR_PREFIX+resourceName+"_value", "Value of the SSM parameter "+shortName+".", cty.StringVal(ssmDetails.Value)))
+			} else if len(ssmDetails.Value) > 0 {
 				valueMap := make(map[string]interface{})
 				err := json.Unmarshal([]byte(ssmDetails.Value), &valueMap)
 				if err == nil {
-					valueMapStr, err := duplosdk.JSONMarshal(valueMap)
-					if err != nil {
-						panic(err)
+					if err := common.SetJSONEncodeAttribute(ssmParamBody, "value", valueMap); err != nil {
+						tfContext.ObjectFailed("ssm parameter "+shortName, err)
+						continue
 					}
-					ssmParamBody.SetAttributeTraversal("value", hcl.Traversal{
-						hcl.TraverseRoot{
-							Name: "jsonencode(" + valueMapStr + ")",
-						},
-					})
 				} else {
 					values := strings.Split(strings.TrimSuffix(ssmDetails.Value, "\n"), "\n")
 					if len(values) > 1 {
@@ -132,15 +171,13 @@ func (ssmParams *SsmParams) Generate(config *common.Config, client *duplosdk.Cli
 					cty.StringVal(ssmDetails.AllowedPattern))
 			}
 			//fmt.Printf("%s", hclFile.Bytes())
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 			log.Printf("[TRACE] Terraform config is generated for duplo SSM parameter : %s", shortName)
 
 			// Import all created resources.
-			if config.GenerateTfState {
+			if config.ImportsEnabled() {
 				importConfigs := []common.ImportConfig{}
 				importConfigs = append(importConfigs, common.ImportConfig{
 					ResourceAddress: "duplocloud_aws_ssm_parameter." + resourceName,
@@ -0,0 +1,185 @@
+package tfgenerator
+
+import (
+	"log"
+	"path"
+	"path/filepath"
+	"tenant-terraform-generator/tf-generator/common"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// backendAttr is an attribute of a backend block, repeated in the config of the remote states reading it.
+type backendAttr struct {
+	name  string
+	value hclwrite.Tokens
+}
+
+func stringAttr(name, value string) backendAttr {
+	return backendAttr{name: name, value: hclwrite.TokensForValue(cty.StringVal(value))}
+}
+
+// BackendEnabled is the Enabled func of the generators writing the terraform backend.
+func BackendEnabled(config *common.Config) bool {
+	return config.Backend.Enabled()
+}
+
+// RenderBackend writes the backend.tf file of a project, given by its directory name.
+func RenderBackend(config *common.Config, project string) error {
+	log.Printf("[TRACE] <====== %s backend TF generation started. =====>", project)
+	hclFile := hclwrite.NewEmptyFile()
+	path := filepath.Join(config.TFCodePath, project, "backend.tf")
+	rootBody := hclFile.Body()
+
+	tfBlockBody := rootBody.AppendNewBlock("terraform",
+		nil).Body()
+	backend := &config.Backend
+	state := config.ProjectState(project)
+	if backend.Type == common.BackendCloud {
+		cloudBody := tfBlockBody.AppendNewBlock("cloud", nil).Body()
+		cloudBody.SetAttributeValue("organization", cty.StringVal(backend.Organization))
+		if len(backend.Hostname) > 0 {
+			cloudBody.SetAttributeValue("hostname", cty.StringVal(backend.Hostname))
+		}
+		workspacesBody := cloudBody.AppendNewBlock("workspaces", nil).Body()
+		workspacesBody.SetAttributeValue("name", cty.StringVal(backend.CloudWorkspace(state)))
+	} else {
+		backendBody := tfBlockBody.AppendNewBlock("backend",
+			[]string{backend.Type}).Body()
+		var attrs []backendAttr
+		switch backend.Type {
+		case common.BackendS3:
+			// The bucket is given to terraform init when it is not configured.
+			attrs = []backendAttr{
+				stringAttr("region", backend.RegionOrDefault()),
+				stringAttr("key", backend.KeyPrefix+state.Key),
+				stringAttr("workspace_key_prefix", state.WorkspaceKeyPrefix),
+				{name: "encrypt", value: hclwrite.TokensForValue(cty.True)},
+			}
+			if len(backend.Bucket) > 0 {
+				attrs = append(attrs, stringAttr("bucket", backend.Bucket))
+			}
+			if len(backend.LockTable) > 0 {
+				attrs = append(attrs, stringAttr("dynamodb_table", backend.LockTable))
+			}
+		case common.BackendHTTP:
+			address := backend.HTTPAddress(state)
+			attrs = []backendAttr{
+				stringAttr("address", address),
+				stringAttr("lock_address", address),
+				stringAttr("unlock_address", address),
+			}
+		default:
+			attrs = backendAttrs(config, project)
+		}
+		for _, attr := range attrs {
+			backendBody.SetAttributeRaw(attr.name, attr.value)
+		}
+	}
+
+	if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
+		return err
+	}
+	log.Printf("[TRACE] <====== %s backend TF generation done. =====>", project)
+	return nil
+}
+
+// AppendRemoteState adds a terraform_remote_state data source named name reading the state of a project,
+// given by its directory name, from the backend of config.
+func AppendRemoteState(rootBody *hclwrite.Body, config *common.Config, name string, project string) {
+	backend := &config.Backend
+	remoteStateBody := rootBody.AppendNewBlock("data",
+		[]string{"terraform_remote_state",
+			name}).Body()
+	backendType := backend.Type
+	switch backendType {
+	case common.BackendCloud:
+		backendType = "remote"
+	case common.BackendNone, "":
+		backendType = common.BackendLocal
+	}
+	remoteStateBody.SetAttributeValue("backend",
+		cty.StringVal(backendType))
+	if state := config.ProjectState(project); backend.Workspaces() && state.Workspace != config.TenantName {
+		// The infrastructure states are in the workspace of the plan.
+		remoteStateBody.SetAttributeValue("workspace", cty.StringVal(state.Workspace))
+	} else if backend.Workspaces() {
+		remoteStateBody.SetAttributeTraversal("workspace", hcl.Traversal{
+			hcl.TraverseRoot{
+				Name: "terraform",
+			},
+			hcl.TraverseAttr{
+				Name: "workspace",
+			},
+		})
+	}
+	configTokens := []ObjectAttrTokens{}
+	for _, attr := range backendAttrs(config, project) {
+		configTokens = append(configTokens, ObjectAttrTokens{
+			Name:  hclwrite.TokensForTraversal(hcl.Traversal{hcl.TraverseRoot{Name: attr.name}}),
+			Value: attr.value,
+		})
+	}
+	remoteStateBody.SetAttributeRaw("config", TokensForObject(configTokens))
+}
+
+// backendAttrs returns the settings locating the state of a project in the backend of config,
+// as the config of a terraform_remote_state data source.
+func backendAttrs(config *common.Config, project string) []backendAttr {
+	backend := &config.Backend
+	state := config.ProjectState(project)
+	key := backend.KeyPrefix + state.Key
+	switch backend.Type {
+	case common.BackendS3:
+		bucket := backendAttr{name: "bucket", value: hclwrite.TokensForTraversal(hcl.Traversal{
+			hcl.TraverseRoot{Name: "local"},
+			hcl.TraverseAttr{Name: "tfstate_bucket"},
+		})}
+		if len(backend.Bucket) > 0 {
+			bucket = stringAttr("bucket", backend.Bucket)
+		}
+		return []backendAttr{
+			bucket,
+			stringAttr("workspace_key_prefix", state.WorkspaceKeyPrefix),
+			stringAttr("key", key),
+			stringAttr("region", backend.RegionOrDefault()),
+		}
+	case common.BackendLocal:
+		return []backendAttr{
+			stringAttr("path", path.Join(backend.PathOrDefault(), key+".tfstate")),
+			stringAttr("workspace_dir", path.Join(backend.PathOrDefault(), key+".tfstate.d")),
+		}
+	case common.BackendHTTP:
+		return []backendAttr{
+			stringAttr("address", backend.HTTPAddress(state)),
+		}
+	case common.BackendGCS:
+		return []backendAttr{
+			stringAttr("bucket", backend.Bucket),
+			stringAttr("prefix", key),
+		}
+	case common.BackendAzurerm:
+		return []backendAttr{
+			stringAttr("resource_group_name", backend.ResourceGroup),
+			stringAttr("storage_account_name", backend.StorageAccount),
+			stringAttr("container_name", backend.Container),
+			stringAttr("key", key+".tfstate"),
+		}
+	case common.BackendCloud:
+		attrs := []backendAttr{stringAttr("organization", backend.Organization)}
+		if len(backend.Hostname) > 0 {
+			attrs = append(attrs, stringAttr("hostname", backend.Hostname))
+		}
+		return append(attrs, backendAttr{name: "workspaces", value: TokensForObject([]ObjectAttrTokens{{
+			Name:  hclwrite.TokensForTraversal(hcl.Traversal{hcl.TraverseRoot{Name: "name"}}),
+			Value: hclwrite.TokensForValue(cty.StringVal(backend.CloudWorkspace(state))),
+		}})})
+	}
+	// Without a backend terraform keeps the states in the project directories.
+	return []backendAttr{
+		stringAttr("path", path.Join("..", project, "terraform.tfstate")),
+		stringAttr("workspace_dir", path.Join("..", project, "terraform.tfstate.d")),
+	}
+}
@@ -0,0 +1,164 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Types of the terraform backends the generated projects keep their state in.
+const (
+	// BackendNone writes no backend, terraform keeps the state in the directory of each project.
+	BackendNone    = "none"
+	BackendS3      = "s3"
+	BackendLocal   = "local"
+	BackendHTTP    = "http"
+	BackendGCS     = "gcs"
+	BackendAzurerm = "azurerm"
+	// BackendCloud writes a cloud block, for Terraform Cloud and Terraform Enterprise.
+	BackendCloud = "cloud"
+
+	// DefaultBackendRegion is the region of the S3 state bucket when none is given.
+	DefaultBackendRegion = "us-west-2"
+	// DefaultBackendPath is the directory of the states of the local backend, relative to the project directories.
+	DefaultBackendPath = "../../state"
+	// CloudMinTerraformVersion is the first terraform version supporting the cloud block.
+	CloudMinTerraformVersion = "1.1.0"
+)
+
+// BackendTypes lists the backend types which can be configured.
+var BackendTypes = []string{BackendS3, BackendLocal, BackendHTTP, BackendGCS, BackendAzurerm, BackendCloud, BackendNone}
+
+// Backend tells where the generated projects keep their state, and how they read the state of each other.
+// Only the fields of Type are used.
+type Backend struct {
+	Type string
+	// Bucket is the bucket of the s3 and gcs backends. Without it the s3 bucket is the duplo-tfstate-<account id>
+	// bucket, given to terraform init like the scripts do.
+	Bucket string
+	// Region is the region of the s3 bucket, DefaultBackendRegion when empty.
+	Region string
+	// LockTable is the DynamoDB table locking the s3 states.
+	LockTable string
+	// KeyPrefix is prepended to the key of the state of every project, e.g. "duplo/" for duplo/aws-services.
+	KeyPrefix string
+	// Path is the directory of the local states, DefaultBackendPath when empty.
+	Path string
+	// Address is the base URL of the http backend, the state of a project is at <address>/<tenant>/<key>.
+	Address string
+	// ResourceGroup, StorageAccount and Container locate the blob container of the azurerm backend.
+	ResourceGroup  string
+	StorageAccount string
+	Container      string
+	// Organization and Hostname select the Terraform Cloud organization, the workspaces are named <tenant>-<key>.
+	Organization string
+	Hostname     string
+}
+
+// ProjectState is the location of the state of a project in the backend.
+type ProjectState struct {
+	Key string
+	// WorkspaceKeyPrefix separates the states of the tenant project from the states of the other projects.
+	WorkspaceKeyPrefix string
+	// Workspace is the terraform workspace of the state: the tenant, or the plan for the infrastructure project.
+	Workspace string
+}
+
+// Enabled reports whether the projects have a backend block.
+func (b *Backend) Enabled() bool {
+	return len(b.Type) > 0 && b.Type != BackendNone
+}
+
+// Workspaces reports whether the backend keeps the state of every tenant in a terraform workspace named after it.
+// The http and cloud backends have a state per tenant instead.
+func (b *Backend) Workspaces() bool {
+	return b.Type != BackendHTTP && b.Type != BackendCloud
+}
+
+// Validate checks the settings needed by the backend type are given.
+func (b *Backend) Validate() error {
+	required := map[string][]string{
+		BackendGCS:     {"bucket", b.Bucket},
+		BackendHTTP:    {"address", b.Address},
+		BackendAzurerm: {"resource group", b.ResourceGroup, "storage account", b.StorageAccount, "container", b.Container},
+		BackendCloud:   {"organization", b.Organization},
+	}
+	known := false
+	for _, t := range BackendTypes {
+		known = known || t == b.Type
+	}
+	if !known {
+		return fmt.Errorf("unknown backend %q, must be one of %s", b.Type, strings.Join(BackendTypes, ", "))
+	}
+	fields := required[b.Type]
+	for i := 0; i < len(fields); i += 2 {
+		if len(fields[i+1]) == 0 {
+			return fmt.Errorf("the %s backend needs a %s", b.Type, fields[i])
+		}
+	}
+	return nil
+}
+
+// RegionOrDefault returns the region of the s3 bucket.
+func (b *Backend) RegionOrDefault() string {
+	if len(b.Region) > 0 {
+		return b.Region
+	}
+	return DefaultBackendRegion
+}
+
+// PathOrDefault returns the directory of the local states.
+func (b *Backend) PathOrDefault() string {
+	if len(b.Path) > 0 {
+		return b.Path
+	}
+	return DefaultBackendPath
+}
+
+// CloudWorkspace returns the name of the Terraform Cloud workspace of the state of a project.
+func (b *Backend) CloudWorkspace(state ProjectState) string {
+	return state.Workspace + "-" + strings.ReplaceAll(b.KeyPrefix+state.Key, "/", "-")
+}
+
+// HTTPAddress returns the address of the state of a project with the http backend.
+func (b *Backend) HTTPAddress(state ProjectState) string {
+	return strings.TrimSuffix(b.Address, "/") + "/" + path.Join(state.Workspace, b.KeyPrefix+state.Key)
+}
+
+// InitBackendConfig returns the -backend-config values terraform init needs for the generated backend block.
+// The default s3 backend is the duplo-tfstate-<account id> bucket, locked by its lock table unless another one is given.
+func (b *Backend) InitBackendConfig(accountID string) []string {
+	if b.Type != BackendS3 || len(b.Bucket) > 0 {
+		return nil
+	}
+	config := []string{"bucket=duplo-tfstate-" + accountID}
+	if len(b.LockTable) == 0 {
+		config = append(config, "dynamodb_table=duplo-tfstate-"+accountID+"-lock")
+	}
+	return config
+}
+
+// ScriptBackendConfig returns the -backend-config arguments the scripts give to terraform init,
+// ok is false when the scripts keep their default, the duplo-tfstate-<account id> bucket and lock table.
+// The other backends are fully configured by the generated backend block. The arguments are expanded when .envrc
+// is sourced, they can only refer to the variables it sets before them.
+func (b *Backend) ScriptBackendConfig() (args string, ok bool) {
+	if b.Type != BackendS3 || len(b.Bucket) > 0 {
+		return "", true
+	}
+	if len(b.LockTable) > 0 {
+		return "-backend-config=bucket=duplo-tfstate-${aws_account_id}", true
+	}
+	return "", false
+}
+
+// ProjectState returns the location of the state of a project of config, given by its directory name.
+func (c *Config) ProjectState(project string) ProjectState {
+	switch project {
+	case c.InfraProject:
+		return ProjectState{Key: "infra", WorkspaceKeyPrefix: "admin:", Workspace: c.PlanID}
+	case c.TenantProject:
+		return ProjectState{Key: "tenant", WorkspaceKeyPrefix: "admin:", Workspace: c.TenantName}
+	}
+	return ProjectState{Key: project, WorkspaceKeyPrefix: "tenant:", Workspace: c.TenantName}
+}
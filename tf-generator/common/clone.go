@@ -0,0 +1,171 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CloneMapping describes the tenant created from the snapshot of another tenant by the clone command.
+// The values left empty are the ones of the source tenant.
+type CloneMapping struct {
+	// Plan is the infrastructure of the new tenant.
+	Plan string `yaml:"plan"`
+	// Certificate is the default of the cert_arn variable.
+	Certificate string `yaml:"certificate"`
+	Account     string `yaml:"account"`
+	Region      string `yaml:"region"`
+	// Substitutions replaces other values of the source tenant, like KMS key IDs, certificate ARNs,
+	// DNS names or image tags, wherever they appear.
+	Substitutions map[string]string `yaml:"substitutions"`
+}
+
+// LoadCloneMapping reads a clone mapping file.
+func LoadCloneMapping(path string) (*CloneMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading mapping file: %s", err)
+	}
+	m := &CloneMapping{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(m); err != nil {
+		return nil, fmt.Errorf("error parsing mapping file %s: %s", path, err)
+	}
+	for from := range m.Substitutions {
+		if len(from) == 0 {
+			return nil, fmt.Errorf("mapping file %s: substitutions cannot replace an empty string", path)
+		}
+	}
+	return m, nil
+}
+
+// substitution replaces old with new in the values of a snapshot. A token substitution only replaces old
+// when it is not part of a longer word, so renaming tenant "dev" leaves "devops" alone.
+type substitution struct {
+	old, new string
+	token    bool
+}
+
+// substitute applies the substitutions in a single pass over v, the first one matching at a position wins
+// and the replaced text is not substituted again.
+func substitute(v string, subs []substitution) string {
+	var b strings.Builder
+	for i := 0; i < len(v); {
+		matched := false
+		for _, s := range subs {
+			if len(s.old) == 0 || !strings.HasPrefix(v[i:], s.old) {
+				continue
+			}
+			end := i + len(s.old)
+			if s.token && (i > 0 && isTokenByte(v[i-1]) || end < len(v) && isTokenByte(v[end])) {
+				continue
+			}
+			b.WriteString(s.new)
+			i, matched = end, true
+			break
+		}
+		if !matched {
+			b.WriteByte(v[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// isTokenByte reports whether c can be part of a name, the tenant names are joined to others with - and . in duplo.
+func isTokenByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// CloneSnapshot returns the snapshot of tenant to, made from the snapshot of another tenant: the values of every
+// object read by the generators are rewritten with the substitutions of the mapping, then the account, region,
+// plan and name of the source tenant. The tenant ID is empty, the new tenant does not exist yet.
+func CloneSnapshot(snapshot *Snapshot, to string, m *CloneMapping) (*Snapshot, error) {
+	source := snapshot.Tenant
+	target := SnapshotTenant{Name: to, AccountID: source.AccountID, PlanID: source.PlanID, Region: source.Region}
+	// The explicit substitutions come first, the longest first, they can contain the account or the region.
+	froms := make([]string, 0, len(m.Substitutions))
+	for from := range m.Substitutions {
+		froms = append(froms, from)
+	}
+	sort.Slice(froms, func(i, j int) bool {
+		if len(froms[i]) != len(froms[j]) {
+			return len(froms[i]) > len(froms[j])
+		}
+		return froms[i] < froms[j]
+	})
+	subs := []substitution{}
+	for _, from := range froms {
+		subs = append(subs, substitution{old: from, new: m.Substitutions[from]})
+	}
+	if len(m.Account) > 0 {
+		target.AccountID = m.Account
+		subs = append(subs, substitution{old: source.AccountID, new: m.Account})
+	}
+	if len(m.Region) > 0 {
+		target.Region = m.Region
+		// The availability zones are named after their region, e.g. us-west-2a.
+		subs = append(subs, substitution{old: source.Region, new: m.Region})
+	}
+	if len(m.Plan) > 0 {
+		target.PlanID = m.Plan
+		subs = append(subs, substitution{old: source.PlanID, new: m.Plan, token: true})
+	}
+	// Also renames the duploservices-<tenant> prefix of the tenant resources.
+	subs = append(subs, substitution{old: source.Name, new: to, token: true})
+
+	clone := &Snapshot{
+		Version:    snapshot.Version,
+		CreatedAt:  snapshot.CreatedAt,
+		Tenant:     target,
+		Generators: map[string]*Fetched{},
+	}
+	for name, f := range snapshot.Generators {
+		c := &Fetched{Failures: f.Failures}
+		if len(f.Data) > 0 {
+			data, err := substituteJSON(f.Data, subs)
+			if err != nil {
+				return nil, fmt.Errorf("error cloning the objects of generator %s: %s", name, err)
+			}
+			c.Data = data
+		}
+		clone.Generators[name] = c
+	}
+	return clone, nil
+}
+
+// substituteJSON applies the substitutions to the strings of a JSON document, numbers are kept as written.
+// The keys are left alone, they are field names.
+func substituteJSON(data json.RawMessage, subs []substitution) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(substituteValue(v, subs))
+}
+
+func substituteValue(v interface{}, subs []substitution) interface{} {
+	switch v := v.(type) {
+	case string:
+		return substitute(v, subs)
+	case []interface{}:
+		for i := range v {
+			v[i] = substituteValue(v[i], subs)
+		}
+		return v
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = substituteValue(value, subs)
+		}
+		return v
+	}
+	return v
+}
@@ -1,21 +1,69 @@
 package common
 
+import "sort"
+
+// DefaultRegion is the region of the tenants of snapshots written before the region was recorded.
+const DefaultRegion = "us-west-2"
+
 type Config struct {
-	TenantId             string
-	TenantName           string
-	CertArn              string
-	CustomerName         string
+	TenantId     string
+	TenantName   string
+	CertArn      string
+	CustomerName string
+	// PlanID is the name of the infrastructure of the tenant.
+	PlanID               string
+	AdminInfraDir        string
 	AdminTenantDir       string
 	AwsServicesDir       string
 	AppDir               string
 	DuploProviderVersion string
+	InfraProject         string
 	TenantProject        string
 	AwsServicesProject   string
 	AppProject           string
 	GenerateTfState      bool
-	S3Backend            bool
-	AccountID            string
-	TFCodePath           string
+	// GenerateInfra adds the project of the infrastructure of the tenant, shared with the other tenants of the plan.
+	GenerateInfra bool
+	// Backend is where the generated projects keep their state.
+	Backend   Backend
+	AccountID string
+	// Region is the AWS region of the tenant, the default of the region variables.
+	Region string
+	// TFCodePath is the directory of the terraform projects, relative to the root of Output.
+	TFCodePath string
+	// Output receives every generated file.
+	Output      Output
+	Parallelism int
+	KeepGoing   bool
+	Merge       bool
+	// ImportBlocks writes the resources to import as import blocks and a manifest instead of running terraform import.
+	ImportBlocks bool
+	// TerraformOptions tells how to find the terraform binary, Terraform is set once it is resolved.
+	TerraformOptions TerraformOptions
+	Terraform        *Terraform
+	// Validate is ValidateTerraform or ValidateStatic, the generated projects are always checked in process.
+	Validate string
+	// Modules writes the objects of the types supporting it with a local module instantiated for each entry of a map variable,
+	// instead of a file per object.
+	Modules bool
+	// TfVars writes the values of the variables to the tfvars.json file of the workspace, see TfVarsPath,
+	// instead of the defaults in vars.tf.
+	TfVars bool
+	// Secrets is SecretsInline or SecretsVariables.
+	Secrets string
+	// RedactSecrets masks the possible secrets found in the generated files, StrictSecrets fails the run when one is left.
+	RedactSecrets bool
+	StrictSecrets bool
+}
+
+// ImportsEnabled reports whether the generators collect the import configs of their resources.
+func (c *Config) ImportsEnabled() bool {
+	return c.GenerateTfState || c.ImportBlocks
+}
+
+// DuploServicesPrefix returns the prefix duplo adds to the names of the tenant resources, like GetDuploServicesPrefix of the client.
+func (c *Config) DuploServicesPrefix() string {
+	return "duploservices-" + c.TenantName
 }
 
 type TFContext struct {
@@ -23,4 +71,49 @@ type TFContext struct {
 	InputVars      []VarConfig
 	OutputVars     []OutputVarConfig
 	ImportConfigs  []ImportConfig
+	// References are the values identifying the generated resources, literals matching them become references.
+	References []Reference
+	// Secrets are the values of the sensitive variables declared in InputVars.
+	Secrets []Secret
+	// Failures lists the objects the generators could not export.
+	Failures []*ObjectError
+}
+
+// MergeTFContexts merges the contexts returned by the generators of a project.
+// Variables and outputs are sorted by name so the generated files do not depend on the order in which generators ran.
+func MergeTFContexts(targetLocation string, contexts []*TFContext) TFContext {
+	merged := TFContext{
+		TargetLocation: targetLocation,
+		InputVars:      []VarConfig{},
+		OutputVars:     []OutputVarConfig{},
+	}
+	for _, c := range contexts {
+		if c == nil {
+			continue
+		}
+		for _, v := range c.InputVars {
+			if len(v.Name) > 0 {
+				merged.InputVars = append(merged.InputVars, v)
+			}
+		}
+		for _, v := range c.OutputVars {
+			if len(v.Name) > 0 {
+				merged.OutputVars = append(merged.OutputVars, v)
+			}
+		}
+		merged.ImportConfigs = append(merged.ImportConfigs, c.ImportConfigs...)
+		merged.References = append(merged.References, c.References...)
+		merged.Secrets = append(merged.Secrets, c.Secrets...)
+		merged.Failures = append(merged.Failures, c.Failures...)
+	}
+	sort.SliceStable(merged.InputVars, func(i, j int) bool {
+		return merged.InputVars[i].Name < merged.InputVars[j].Name
+	})
+	sort.SliceStable(merged.OutputVars, func(i, j int) bool {
+		return merged.OutputVars[i].Name < merged.OutputVars[j].Name
+	})
+	sort.SliceStable(merged.Secrets, func(i, j int) bool {
+		return merged.Secrets[i].Name < merged.Secrets[j].Name
+	})
+	return merged
 }
@@ -0,0 +1,43 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"tenant-terraform-generator/duplosdk"
+)
+
+// ObjectError is a failure to export a duplo object, or a whole generator when Object is empty.
+type ObjectError struct {
+	// Generator is the name of the generator which failed, it is set by the orchestrator.
+	Generator string
+	// Object describes the duplo object which could not be exported, e.g. "duplo service nginx".
+	Object string
+	Err    error
+}
+
+func (e *ObjectError) Error() string {
+	if len(e.Object) == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.Object, e.Err)
+}
+
+func (e *ObjectError) Unwrap() error {
+	return e.Err
+}
+
+// ClientError returns the duplo API error which caused the failure, if any.
+func (e *ObjectError) ClientError() duplosdk.ClientError {
+	var clientErr duplosdk.ClientError
+	if errors.As(e.Err, &clientErr) {
+		return clientErr
+	}
+	return nil
+}
+
+// ObjectFailed records that an object could not be exported, the generator goes on with the next object.
+func (c *TFContext) ObjectFailed(object string, err error) {
+	log.Printf("[TRACE] Skipping %s: %s", object, err)
+	c.Failures = append(c.Failures, &ObjectError{Object: object, Err: err})
+}
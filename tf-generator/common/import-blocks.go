@@ -0,0 +1,110 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	// ImportBlocksFile is written in every project with resources to import.
+	ImportBlocksFile = "imports.tf"
+	// ImportManifestFile and ImportScriptFile are written next to the terraform directory of the tenant.
+	ImportManifestFile = "imports.json"
+	ImportScriptFile   = "imports.sh"
+)
+
+// ImportBlocks writes the import configs of a project as import blocks, so terraform plan imports the resources.
+type ImportBlocks struct {
+	TargetLocation string
+	Output         Output
+	ImportConfigs  []ImportConfig
+}
+
+func (ib *ImportBlocks) Generate() error {
+	log.Println("[TRACE] <====== Import blocks TF generation started. =====>")
+	hclFile := hclwrite.NewEmptyFile()
+	rootBody := hclFile.Body()
+	for i, ic := range UniqueImportConfigs(ib.ImportConfigs) {
+		to, diags := hclsyntax.ParseTraversalAbs([]byte(ic.ResourceAddress), "", hcl.InitialPos)
+		if diags.HasErrors() {
+			return fmt.Errorf("invalid resource address %q: %s", ic.ResourceAddress, diags.Error())
+		}
+		if i > 0 {
+			rootBody.AppendNewline()
+		}
+		importBody := rootBody.AppendNewBlock("import", nil).Body()
+		importBody.SetAttributeTraversal("to", to)
+		importBody.SetAttributeValue("id", cty.StringVal(ic.ResourceId))
+	}
+	path := filepath.Join(ib.TargetLocation, ImportBlocksFile)
+	if err := ib.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
+		return err
+	}
+	log.Println("[TRACE] <====== Import blocks TF generation done. =====>")
+	return nil
+}
+
+// UniqueImportConfigs drops the configs importing an address already imported by a previous config, terraform rejects duplicate import blocks.
+func UniqueImportConfigs(configs []ImportConfig) []ImportConfig {
+	seen := map[string]bool{}
+	unique := []ImportConfig{}
+	for _, ic := range configs {
+		if seen[ic.ResourceAddress] {
+			continue
+		}
+		seen[ic.ResourceAddress] = true
+		unique = append(unique, ic)
+	}
+	return unique
+}
+
+// ImportManifestEntry is a resource to import, Project is the directory of its project relative to the manifest.
+type ImportManifestEntry struct {
+	Project string `json:"project"`
+	Address string `json:"address"`
+	ID      string `json:"id"`
+}
+
+// ImportManifest lists the resources of every project for the teams running the imports in their own pipelines.
+type ImportManifest struct {
+	Imports []ImportManifestEntry `json:"imports"`
+}
+
+// Add records the import configs of the project in projectDir.
+func (m *ImportManifest) Add(projectDir string, configs []ImportConfig) {
+	for _, ic := range UniqueImportConfigs(configs) {
+		m.Imports = append(m.Imports, ImportManifestEntry{Project: projectDir, Address: ic.ResourceAddress, ID: ic.ResourceId})
+	}
+}
+
+// Write writes the manifest in dir as JSON, and as a shell script importing the resources with command (terraform or tofu).
+func (m *ImportManifest) Write(output Output, dir string, command string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := output.WriteFile(path.Join(dir, ImportManifestFile), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	script := &strings.Builder{}
+	script.WriteString("#!/bin/bash -eu\n\n")
+	script.WriteString("# Imports the existing DuploCloud resources into the terraform state, as an alternative to the import blocks of " + ImportBlocksFile + ".\n")
+	script.WriteString("# Run it from this directory once every project is initialized with the tenant workspace selected.\n\n")
+	for _, e := range m.Imports {
+		fmt.Fprintf(script, "%s -chdir=%s import %s %s\n", command, shellQuote(e.Project), shellQuote(e.Address), shellQuote(e.ID))
+	}
+	return output.WriteFile(path.Join(dir, ImportScriptFile), []byte(script.String()), 0755)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,169 @@
+package common
+
+import (
+	"encoding/json"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUniqueImportConfigs(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []ImportConfig
+		want    []ImportConfig
+	}{
+		{name: "none", configs: nil, want: []ImportConfig{}},
+		{
+			name: "unique",
+			configs: []ImportConfig{
+				{ResourceAddress: "duplocloud_tenant.tenant", ResourceId: "v2/admin/TenantV2/t1"},
+				{ResourceAddress: "duplocloud_aws_host.host", ResourceId: "v2/subscriptions/t1/NativeHostV2/i-1"},
+			},
+			want: []ImportConfig{
+				{ResourceAddress: "duplocloud_tenant.tenant", ResourceId: "v2/admin/TenantV2/t1"},
+				{ResourceAddress: "duplocloud_aws_host.host", ResourceId: "v2/subscriptions/t1/NativeHostV2/i-1"},
+			},
+		},
+		{
+			name: "duplicate address keeps the first config",
+			configs: []ImportConfig{
+				{ResourceAddress: "duplocloud_s3_bucket.app", ResourceId: "t1/app"},
+				{ResourceAddress: "duplocloud_tenant.tenant", ResourceId: "v2/admin/TenantV2/t1"},
+				{ResourceAddress: "duplocloud_s3_bucket.app", ResourceId: "t1/app-1"},
+			},
+			want: []ImportConfig{
+				{ResourceAddress: "duplocloud_s3_bucket.app", ResourceId: "t1/app"},
+				{ResourceAddress: "duplocloud_tenant.tenant", ResourceId: "v2/admin/TenantV2/t1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UniqueImportConfigs(tt.configs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportBlocksGenerate(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []ImportConfig
+		want    string
+		// wantErr is part of the expected error, empty when the blocks are generated.
+		wantErr string
+	}{
+		{
+			name: "import blocks",
+			configs: []ImportConfig{
+				{ResourceAddress: "duplocloud_tenant.tenant", ResourceId: "v2/admin/TenantV2/t1"},
+				{ResourceAddress: `module.app.duplocloud_duplo_service.svc["web"]`, ResourceId: "v2/subscriptions/t1/ReplicationControllerApiV2/web"},
+				{ResourceAddress: "duplocloud_tenant.tenant", ResourceId: "v2/admin/TenantV2/t2"},
+			},
+			want: `import {
+  to = duplocloud_tenant.tenant
+  id = "v2/admin/TenantV2/t1"
+}
+
+import {
+  to = module.app.duplocloud_duplo_service.svc["web"]
+  id = "v2/subscriptions/t1/ReplicationControllerApiV2/web"
+}
+`,
+		},
+		{
+			name:    "invalid address",
+			configs: []ImportConfig{{ResourceAddress: "duplocloud_tenant.tenant[0", ResourceId: "v2/admin/TenantV2/t1"}},
+			wantErr: `invalid resource address "duplocloud_tenant.tenant[0"`,
+		},
+		{
+			name:    "empty address",
+			configs: []ImportConfig{{ResourceAddress: "", ResourceId: "v2/admin/TenantV2/t1"}},
+			wantErr: `invalid resource address ""`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := NewMemoryOutput()
+			ib := &ImportBlocks{TargetLocation: "duplo-masp/test/tenant", Output: out, ImportConfigs: tt.configs}
+			err := ib.Generate()
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+				if names := out.Names(); len(names) > 0 {
+					t.Errorf("files were written: %v", names)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, ok := out.File("duplo-masp/test/tenant/" + ImportBlocksFile)
+			if !ok {
+				t.Fatalf("%s was not written", ImportBlocksFile)
+			}
+			if got := string(f.Data); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportManifestWrite(t *testing.T) {
+	m := &ImportManifest{}
+	m.Add("tenant", []ImportConfig{{ResourceAddress: "duplocloud_tenant.tenant", ResourceId: "v2/admin/TenantV2/t1"}})
+	m.Add("app", []ImportConfig{
+		{ResourceAddress: `duplocloud_k8_secret.secret["o'brien"]`, ResourceId: "t1/o'brien"},
+		{ResourceAddress: `duplocloud_k8_secret.secret["o'brien"]`, ResourceId: "t1/o'brien-1"},
+	})
+	out := NewMemoryOutput()
+	if err := m.Write(out, "duplo-masp/test", "tofu"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, ok := out.File("duplo-masp/test/" + ImportManifestFile)
+	if !ok {
+		t.Fatalf("%s was not written", ImportManifestFile)
+	}
+	got := ImportManifest{}
+	if err := json.Unmarshal(f.Data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, m) || len(got.Imports) != 2 {
+		t.Errorf("manifest is %+v, want %+v", got, m)
+	}
+
+	f, ok = out.File("duplo-masp/test/" + ImportScriptFile)
+	if !ok {
+		t.Fatalf("%s was not written", ImportScriptFile)
+	}
+	if f.Mode != 0755 {
+		t.Errorf("%s mode is %v, want it executable", ImportScriptFile, f.Mode)
+	}
+	wantLines := []string{
+		`tofu -chdir='tenant' import 'duplocloud_tenant.tenant' 'v2/admin/TenantV2/t1'`,
+		`tofu -chdir='app' import 'duplocloud_k8_secret.secret["o'\''brien"]' 't1/o'\''brien'`,
+	}
+	for _, line := range wantLines {
+		if !strings.Contains(string(f.Data), line+"\n") {
+			t.Errorf("%s does not contain %s:\n%s", ImportScriptFile, line, f.Data)
+		}
+	}
+}
+
+// TestShellQuote checks the shell reads the quoted strings back unchanged.
+func TestShellQuote(t *testing.T) {
+	for _, s := range []string{"", "t1/app", "o'brien", "''", `a "b" $c \d`} {
+		out, err := exec.Command("sh", "-c", "printf %s "+shellQuote(s)).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != s {
+			t.Errorf("%q was read back as %q", s, out)
+		}
+	}
+}
@@ -2,12 +2,10 @@ package common
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"tenant-terraform-generator/duplosdk"
 
-	"github.com/hashicorp/go-version"
-	"github.com/hashicorp/hc-install/product"
-	"github.com/hashicorp/hc-install/releases"
 	"github.com/hashicorp/terraform-exec/tfexec"
 )
 
@@ -20,37 +18,24 @@ type ImportConfig struct {
 	WorkingDir      string
 }
 
-func (i *Importer) Import(config *Config, importConfig *ImportConfig) {
+func (i *Importer) Import(ctx context.Context, config *Config, importConfig *ImportConfig) error {
 	log.Println("[TRACE] <================================== TF Import in progress. ==================================>")
 	log.Printf("[TRACE] Importing terraform resource  : (%s, %s).", importConfig.ResourceAddress, importConfig.ResourceId)
-	installer := &releases.ExactVersion{
-		Product: product.Terraform,
-		Version: version.Must(version.NewVersion("0.14.11")),
-	}
-
-	execPath, err := installer.Install(context.Background())
-	if err != nil {
-		log.Fatalf("error installing Terraform: %s", err)
-	}
-	tf, err := tfexec.NewTerraform(importConfig.WorkingDir, execPath)
+	tf, err := config.NewTerraform(ctx, importConfig.WorkingDir)
 	if err != nil {
-		log.Fatalf("error running NewTerraform: %s", err)
+		return err
 	}
 	//backend := "-backend-config=bucket=duplo-tfstate-" + config.AccountID + " -backend-config=dynamodb_table=duplo-tfstate-" + config.AccountID + "-lock"
-	//err = tf.Init(context.Background(), tfexec.Upgrade(true), tfexec.BackendConfig("bucket=duplo-tfstate-"+config.AccountID), tfexec.BackendConfig("dynamodb_table=duplo-tfstate-"+config.AccountID+"-lock"))
-	if config.S3Backend {
-		err = tf.Init(context.Background(), tfexec.Upgrade(true), tfexec.BackendConfig("bucket=duplo-tfstate-"+config.AccountID))
-	} else {
-		err = tf.Init(context.Background(), tfexec.Upgrade(true))
-	}
+	//err = tf.Init(ctx, tfexec.Upgrade(true), tfexec.BackendConfig("bucket=duplo-tfstate-"+config.AccountID), tfexec.BackendConfig("dynamodb_table=duplo-tfstate-"+config.AccountID+"-lock"))
+	err = tf.Init(ctx, initOptions(config)...)
 
 	if err != nil {
-		log.Fatalf("error running Init: %s", err)
+		return fmt.Errorf("error running Init: %s", err)
 	}
 
-	workspaceList, activeWorkspace, err := tf.WorkspaceList(context.Background())
+	workspaceList, activeWorkspace, err := tf.WorkspaceList(ctx)
 	if err != nil {
-		log.Fatalf("error running tf workspace list: %s", err)
+		return fmt.Errorf("error running tf workspace list: %s", err)
 	}
 	if len(workspaceList) > 0 {
 		log.Printf("[TRACE] Workspace List (%s).", workspaceList)
@@ -58,26 +43,26 @@ func (i *Importer) Import(config *Config, importConfig *ImportConfig) {
 	}
 
 	if duplosdk.Contains(workspaceList, config.TenantName) {
-		err = tf.WorkspaceSelect(context.Background(), config.TenantName)
+		err = tf.WorkspaceSelect(ctx, config.TenantName)
 		if err != nil {
-			log.Fatalf("error running tf workspace select: %s", err)
+			return fmt.Errorf("error running tf workspace select: %s", err)
 		}
 		log.Printf("[TRACE] (%s) workspace is selected.", config.TenantName)
 	} else {
-		err := tf.WorkspaceNew(context.Background(), config.TenantName)
+		err := tf.WorkspaceNew(ctx, config.TenantName)
 		if err != nil {
-			log.Fatalf("error running tf workspace new: %s", err)
+			return fmt.Errorf("error running tf workspace new: %s", err)
 		}
 		log.Printf("[TRACE] (%s) workspace is created.", config.TenantName)
 	}
 
-	err = tf.Import(context.Background(), importConfig.ResourceAddress, importConfig.ResourceId)
+	err = tf.Import(ctx, importConfig.ResourceAddress, importConfig.ResourceId)
 	if err != nil {
-		log.Fatalf("error running Import: %s", err)
+		return fmt.Errorf("error running Import: %s", err)
 	}
-	_, err = tf.Show(context.Background())
+	_, err = tf.Show(ctx)
 	if err != nil {
-		log.Fatalf("error running Show: %s", err)
+		return fmt.Errorf("error running Show: %s", err)
 	}
 
 	//_, err = json.Marshal(state.Values)
@@ -85,19 +70,20 @@ func (i *Importer) Import(config *Config, importConfig *ImportConfig) {
 
 	log.Printf("[TRACE] Terraform resource (%s, %s) is imported.", importConfig.ResourceAddress, importConfig.ResourceId)
 	log.Println("[TRACE] <====================================================================>")
+	return nil
 }
 
-func (i *Importer) ImportWithoutInit(config *Config, importConfig *ImportConfig, tf *tfexec.Terraform) {
+func (i *Importer) ImportWithoutInit(ctx context.Context, config *Config, importConfig *ImportConfig, tf *tfexec.Terraform) error {
 	log.Println("[TRACE] <================================== TF Import in progress. ==================================>")
 	log.Printf("[TRACE] Importing terraform resource  : (%s, %s).", importConfig.ResourceAddress, importConfig.ResourceId)
 
-	err := tf.Import(context.Background(), importConfig.ResourceAddress, importConfig.ResourceId)
+	err := tf.Import(ctx, importConfig.ResourceAddress, importConfig.ResourceId)
 	if err != nil {
-		log.Fatalf("error running Import: %s", err)
+		return fmt.Errorf("error running Import: %s", err)
 	}
-	_, err = tf.Show(context.Background())
+	_, err = tf.Show(ctx)
 	if err != nil {
-		log.Fatalf("error running Show: %s", err)
+		return fmt.Errorf("error running Show: %s", err)
 	}
 
 	//_, err = json.Marshal(state.Values)
@@ -105,4 +91,5 @@ func (i *Importer) ImportWithoutInit(config *Config, importConfig *ImportConfig,
 
 	log.Printf("[TRACE] Terraform resource (%s, %s) is imported.", importConfig.ResourceAddress, importConfig.ResourceId)
 	log.Println("[TRACE] <====================================================================>")
+	return nil
 }
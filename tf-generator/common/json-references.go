@@ -0,0 +1,130 @@
+package common
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"tenant-terraform-generator/duplosdk"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// JSONReferenceRule is a path of a JSON document whose string values name an object of a kind, e.g. a secret.
+// Path is made of keys separated by dots, "*" matches every element of an array and every value of an object.
+// Keys are matched case-insensitively: duplo accepts the Kubernetes specs in both casings.
+type JSONReferenceRule struct {
+	Path string
+	Kind string
+}
+
+// jsonReferencePrefix marks the values replaced by RewriteJSONReferences, JSONEncodeTokens writes them as
+// bare references. The documents come from duplo, their strings are not expected to contain a NUL character.
+const jsonReferencePrefix = "\x00reference:"
+
+// jsonReferencePattern matches the marked values in a document encoded by duplosdk.JSONMarshal.
+var jsonReferencePattern = regexp.MustCompile(`"\\u0000reference:(?:[^"\\]|\\.)*"`)
+
+// JSONReferences maps the names of the generated objects of every kind to the expressions referring to them,
+// e.g. "app-secret" of kind secret to duplocloud_k8_secret.app_secret.secret_name.
+type JSONReferences map[string]map[string]string
+
+// Add records the expression referring to the object of a kind named name.
+func (refs JSONReferences) Add(kind, name, expression string) {
+	if refs[kind] == nil {
+		refs[kind] = map[string]string{}
+	}
+	refs[kind][name] = expression
+}
+
+// RewriteJSONReferences replaces the values of doc found at the path of a rule which name an object of refs
+// with its reference, and returns the number of values replaced. The references are only written as such by
+// SetJSONEncodeAttribute and JSONEncodeTokens.
+// doc is a decoded JSON document, made of maps, slices and values, and it is changed in place.
+func RewriteJSONReferences(doc interface{}, rules []JSONReferenceRule, refs JSONReferences) int {
+	replaced := 0
+	for _, rule := range rules {
+		names := refs[rule.Kind]
+		if len(names) == 0 {
+			continue
+		}
+		walkJSON(doc, strings.Split(rule.Path, "."), func(value string) (string, bool) {
+			expression, ok := names[value]
+			if !ok {
+				return "", false
+			}
+			replaced++
+			return jsonReferencePrefix + expression, true
+		})
+	}
+	return replaced
+}
+
+// walkJSON calls replace with the string values of node at path, and sets the values it returns.
+func walkJSON(node interface{}, path []string, replace func(string) (string, bool)) {
+	if len(path) == 0 {
+		return
+	}
+	step, last := path[0], len(path) == 1
+	visit := func(value interface{}, set func(string)) {
+		if !last {
+			walkJSON(value, path[1:], replace)
+			return
+		}
+		if s, ok := value.(string); ok {
+			if r, ok := replace(s); ok {
+				set(r)
+			}
+		}
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, value := range n {
+			if step == "*" || strings.EqualFold(key, step) {
+				key := key
+				visit(value, func(r string) { n[key] = r })
+			}
+		}
+	case []interface{}:
+		if step != "*" {
+			return
+		}
+		for i, value := range n {
+			i := i
+			visit(value, func(r string) { n[i] = r })
+		}
+	}
+}
+
+// SetJSONEncodeAttribute sets the attribute name of body to a jsonencode call of doc.
+// Strings of doc are HCL templates, and the values set by RewriteJSONReferences are written as bare references,
+// e.g. { "Name" : duplocloud_k8_secret.app_secret.secret_name }.
+func SetJSONEncodeAttribute(body *hclwrite.Body, name string, doc interface{}) error {
+	tokens, err := JSONEncodeTokens(doc)
+	if err != nil {
+		return err
+	}
+	body.SetAttributeRaw(name, tokens)
+	return nil
+}
+
+// JSONEncodeTokens returns the jsonencode call of doc set by SetJSONEncodeAttribute, for the documents
+// written inside another expression.
+func JSONEncodeTokens(doc interface{}) (hclwrite.Tokens, error) {
+	docStr, err := duplosdk.JSONMarshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	docStr = jsonReferencePattern.ReplaceAllStringFunc(docStr, func(quoted string) string {
+		var value string
+		if err := json.Unmarshal([]byte(quoted), &value); err != nil {
+			return quoted
+		}
+		return strings.TrimPrefix(value, jsonReferencePrefix)
+	})
+	return hclwrite.TokensForTraversal(hcl.Traversal{
+		hcl.TraverseRoot{
+			Name: "jsonencode(" + docStr + ")",
+		},
+	}), nil
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+func TestJSONEncodeReferences(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{
+  "Env": [{"Name": "KEY", "ValueFrom": {"SecretKeyRef": {"Name": "app-secret", "Key": "app-secret"}}}],
+  "envFrom": [{"configMapRef": {"name": "app-config"}}, {"configMapRef": {"name": "other"}}]
+}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	rules := []JSONReferenceRule{
+		{Path: "Env.*.ValueFrom.SecretKeyRef.Name", Kind: "secret"},
+		{Path: "EnvFrom.*.ConfigMapRef.Name", Kind: "configmap"},
+	}
+	refs := JSONReferences{}
+	refs.Add("secret", "app-secret", "duplocloud_k8_secret.app_secret.secret_name")
+	refs.Add("configmap", "app-config", `module.config_maps.names["app-config"]`)
+	if got := RewriteJSONReferences(doc, rules, refs); got != 2 {
+		t.Errorf("%d values replaced, want 2", got)
+	}
+
+	tokens, err := JSONEncodeTokens(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(tokens.Bytes())
+	for _, want := range []string{
+		`"Name": duplocloud_k8_secret.app_secret.secret_name`,
+		`"name": module.config_maps.names["app-config"]`,
+		`"Key": "app-secret"`,
+		`"name": "other"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("jsonencode does not contain %s:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "${") || strings.Contains(got, `\u0000`) {
+		t.Errorf("jsonencode contains an interpolation or a marker:\n%s", got)
+	}
+	if _, diags := hclsyntax.ParseConfig([]byte("locals {\n  doc = "+got+"\n}\n"), "main.tf", hcl.InitialPos); diags.HasErrors() {
+		t.Errorf("jsonencode is not valid: %s", diags.Error())
+	}
+}
@@ -0,0 +1,225 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ManifestFile is the name of the file recording the files generated in a tenant directory.
+const ManifestFile = ".tenant-terraform-generator.json"
+
+// ConflictSuffix is appended to the name of a generated file when the file on disk was edited since the last run.
+const ConflictSuffix = ".new"
+
+const manifestVersion = 1
+
+// Manifest records the files written by the last run and the checksum of their content.
+type Manifest struct {
+	Version int `json:"version"`
+	// Files maps the names of the generated files, relative to the tenant directory, to their sha256.
+	Files map[string]string `json:"files"`
+}
+
+// MergeReport describes what a MergeOutput did to the files of the previous run.
+type MergeReport struct {
+	// Conflicts lists the files edited since the last run, the new content was written next to them with ConflictSuffix.
+	Conflicts []string
+	// Removed lists the generated files which were not generated again, e.g. because their duplo object was deleted.
+	Removed []string
+	// KeptStale lists the files which were not generated again but were edited since the last run, or kept because the run failed.
+	KeptStale []string
+}
+
+// MergeOutput writes to a directory without touching the files it did not generate.
+// The files it owns are recorded in a manifest in the tenant directory: a file edited since the last run is not overwritten,
+// and a file which is no longer generated is removed when Finish is called.
+type MergeOutput struct {
+	Dir *DirOutput
+	// TenantDir is the directory of the tenant in the output, every file is expected to be written below it.
+	TenantDir string
+	// KeepStale disables the removal of the files which were not generated again,
+	// it is set when the run failed and files could be missing only because of the failure.
+	KeepStale bool
+
+	previous  Manifest
+	mu        sync.Mutex
+	written   map[string]bool
+	conflicts map[string]string
+}
+
+// NewMergeOutput creates a MergeOutput and loads the manifest of the previous run, if any.
+func NewMergeOutput(dir *DirOutput, tenantDir string) (*MergeOutput, error) {
+	o := &MergeOutput{
+		Dir:       dir,
+		TenantDir: tenantDir,
+		previous:  Manifest{Version: manifestVersion, Files: map[string]string{}},
+		written:   map[string]bool{},
+		conflicts: map[string]string{},
+	}
+	data, err := os.ReadFile(o.Path(path.Join(tenantDir, ManifestFile)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &o.previous); err != nil {
+		return nil, fmt.Errorf("error parsing manifest %s: %s", ManifestFile, err)
+	}
+	if o.previous.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d in %s", o.previous.Version, ManifestFile)
+	}
+	if o.previous.Files == nil {
+		o.previous.Files = map[string]string{}
+	}
+	return o, nil
+}
+
+// Path returns the local path of a file or directory of the output.
+func (o *MergeOutput) Path(name string) string {
+	return o.Dir.Path(name)
+}
+
+func (o *MergeOutput) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	name, err := cleanOutputName(name)
+	if err != nil {
+		return err
+	}
+	rel, err := o.relative(name)
+	if err != nil {
+		return err
+	}
+	// Formatted like terraform fmt would, so files left by a run without a manifest can be adopted.
+	data = formatTF(name, data)
+	current, err := fileChecksum(o.Path(name))
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	previous, generated := o.previous.Files[rel]
+	// The file is ours when it does not exist, or still has the content we generated last time.
+	if len(current) == 0 || (generated && current == previous) || current == checksum(data) {
+		o.written[rel] = true
+		delete(o.conflicts, rel)
+		return o.Dir.WriteFile(name, data, perm)
+	}
+	log.Printf("[TRACE] %s was edited since the last run, writing the generated file to %s", name, name+ConflictSuffix)
+	o.conflicts[rel] = previous
+	o.written[rel+ConflictSuffix] = true
+	return o.Dir.WriteFile(name+ConflictSuffix, data, perm)
+}
+
+// Written reports whether the file at the local path p was written by this run, the other files belong to the user.
+func (o *MergeOutput) Written(p string) bool {
+	rel, err := filepath.Rel(o.Path(o.TenantDir), p)
+	if err != nil {
+		return false
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.written[filepath.ToSlash(rel)]
+}
+
+func (o *MergeOutput) relative(name string) (string, error) {
+	prefix := o.TenantDir + "/"
+	if !strings.HasPrefix(name, prefix) {
+		return "", fmt.Errorf("generated file %s is outside of the tenant directory %s", name, o.TenantDir)
+	}
+	return strings.TrimPrefix(name, prefix), nil
+}
+
+// Close does nothing, Finish saves the manifest once the generated files are formatted.
+func (o *MergeOutput) Close() error {
+	return nil
+}
+
+// Finish removes the stale generated files and saves the manifest.
+// It must be called after terraform fmt, the checksums are read from the files on disk.
+func (o *MergeOutput) Finish() (*MergeReport, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	report := &MergeReport{}
+	next := Manifest{Version: manifestVersion, Files: map[string]string{}}
+	for rel := range o.written {
+		sum, err := fileChecksum(o.Path(path.Join(o.TenantDir, rel)))
+		if err != nil {
+			return nil, err
+		}
+		if len(sum) > 0 {
+			next.Files[rel] = sum
+		}
+	}
+	for rel, previous := range o.conflicts {
+		report.Conflicts = append(report.Conflicts, path.Join(o.TenantDir, rel))
+		// Keep the checksum of the last generated content so the file stays a conflict until it is resolved.
+		if len(previous) > 0 {
+			next.Files[rel] = previous
+		}
+	}
+	for rel, previous := range o.previous.Files {
+		if _, conflict := o.conflicts[rel]; o.written[rel] || conflict {
+			continue
+		}
+		name := path.Join(o.TenantDir, rel)
+		current, err := fileChecksum(o.Path(name))
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(current) == 0:
+		case o.KeepStale:
+			report.KeptStale = append(report.KeptStale, name)
+			next.Files[rel] = previous
+		case current != previous:
+			// Edited files are left to the user, they are no longer tracked.
+			report.KeptStale = append(report.KeptStale, name)
+		default:
+			log.Printf("[TRACE] Removing %s which is no longer generated", name)
+			if err := os.Remove(o.Path(name)); err != nil {
+				return nil, err
+			}
+			report.Removed = append(report.Removed, name)
+		}
+	}
+	sort.Strings(report.Conflicts)
+	sort.Strings(report.Removed)
+	sort.Strings(report.KeptStale)
+
+	data, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Dir.WriteFile(path.Join(o.TenantDir, ManifestFile), append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// fileChecksum returns the sha256 of a file, or an empty string when it does not exist.
+func fileChecksum(p string) (string, error) {
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return checksum(data), nil
+}
@@ -0,0 +1,238 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const mergeTenantDir = "duplo-masp/test"
+
+// mergeRun writes files with a MergeOutput of root as a run of the generator would, and returns its report.
+func mergeRun(t *testing.T, root string, keepStale bool, files map[string]string) *MergeReport {
+	t.Helper()
+	o, err := NewMergeOutput(&DirOutput{Root: root}, mergeTenantDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := o.WriteFile(mergeTenantDir+"/"+name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	o.KeepStale = keepStale
+	report, err := o.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func readMergeFile(t *testing.T, root, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, mergeTenantDir, name))
+	if os.IsNotExist(err) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func writeMergeFile(t *testing.T, root, name, data string) {
+	t.Helper()
+	p := filepath.Join(root, mergeTenantDir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// trackedFiles returns the files recorded in the manifest of root.
+func trackedFiles(t *testing.T, root string) []string {
+	t.Helper()
+	m := Manifest{}
+	if err := json.Unmarshal([]byte(readMergeFile(t, root, ManifestFile)), &m); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for name := range m.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func checkMergeReport(t *testing.T, got *MergeReport, want MergeReport) {
+	t.Helper()
+	for _, list := range []*[]string{&got.Conflicts, &got.Removed, &got.KeptStale, &want.Conflicts, &want.Removed, &want.KeptStale} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("report is %+v, want %+v", *got, want)
+	}
+}
+
+const (
+	mainV1 = "locals {\n  version = 1\n}\n"
+	mainV2 = "locals {\n  version = 2\n}\n"
+	edited = "locals {\n  version = 1\n  edited  = true\n}\n"
+)
+
+func TestMergeRewritesUneditedFile(t *testing.T) {
+	root := t.TempDir()
+	mergeRun(t, root, false, map[string]string{"app/main.tf": mainV1})
+	report := mergeRun(t, root, false, map[string]string{"app/main.tf": mainV2})
+
+	checkMergeReport(t, report, MergeReport{})
+	if got := readMergeFile(t, root, "app/main.tf"); got != mainV2 {
+		t.Errorf("main.tf is %q, want %q", got, mainV2)
+	}
+	if got := readMergeFile(t, root, "app/main.tf"+ConflictSuffix); got != "" {
+		t.Errorf("main.tf%s was written", ConflictSuffix)
+	}
+}
+
+func TestMergeEditedFileConflicts(t *testing.T) {
+	root := t.TempDir()
+	mergeRun(t, root, false, map[string]string{"app/main.tf": mainV1})
+	writeMergeFile(t, root, "app/main.tf", edited)
+	report := mergeRun(t, root, false, map[string]string{"app/main.tf": mainV2})
+
+	checkMergeReport(t, report, MergeReport{Conflicts: []string{mergeTenantDir + "/app/main.tf"}})
+	if got := readMergeFile(t, root, "app/main.tf"); got != edited {
+		t.Errorf("the edited main.tf was overwritten with %q", got)
+	}
+	if got := readMergeFile(t, root, "app/main.tf"+ConflictSuffix); got != mainV2 {
+		t.Errorf("main.tf%s is %q, want %q", ConflictSuffix, got, mainV2)
+	}
+}
+
+func TestMergeConflictPersistsUntilResolved(t *testing.T) {
+	root := t.TempDir()
+	mergeRun(t, root, false, map[string]string{"app/main.tf": mainV1})
+	writeMergeFile(t, root, "app/main.tf", edited)
+	mergeRun(t, root, false, map[string]string{"app/main.tf": mainV2})
+
+	// The next run still finds the file edited, even though it was not generated with this content.
+	report := mergeRun(t, root, false, map[string]string{"app/main.tf": mainV2})
+	checkMergeReport(t, report, MergeReport{Conflicts: []string{mergeTenantDir + "/app/main.tf"}})
+	if got := readMergeFile(t, root, "app/main.tf"); got != edited {
+		t.Errorf("the edited main.tf was overwritten with %q", got)
+	}
+
+	// The user resolves the conflict by taking the generated file, which is tracked again from the next run.
+	writeMergeFile(t, root, "app/main.tf", readMergeFile(t, root, "app/main.tf"+ConflictSuffix))
+	if err := os.Remove(filepath.Join(root, mergeTenantDir, "app", "main.tf"+ConflictSuffix)); err != nil {
+		t.Fatal(err)
+	}
+	report = mergeRun(t, root, false, map[string]string{"app/main.tf": mainV2})
+	checkMergeReport(t, report, MergeReport{})
+	report = mergeRun(t, root, false, map[string]string{"app/main.tf": mainV1})
+	checkMergeReport(t, report, MergeReport{})
+	if got := readMergeFile(t, root, "app/main.tf"); got != mainV1 {
+		t.Errorf("main.tf is %q, want %q", got, mainV1)
+	}
+}
+
+func TestMergeRemovesFileNoLongerGenerated(t *testing.T) {
+	root := t.TempDir()
+	mergeRun(t, root, false, map[string]string{"app/main.tf": mainV1, "app/svc-old.tf": mainV1})
+	report := mergeRun(t, root, false, map[string]string{"app/main.tf": mainV1})
+
+	checkMergeReport(t, report, MergeReport{Removed: []string{mergeTenantDir + "/app/svc-old.tf"}})
+	if _, err := os.Stat(filepath.Join(root, mergeTenantDir, "app", "svc-old.tf")); !os.IsNotExist(err) {
+		t.Errorf("svc-old.tf was not removed: %v", err)
+	}
+	if got, want := trackedFiles(t, root), []string{"app/main.tf"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tracked files are %v, want %v", got, want)
+	}
+}
+
+func TestMergeKeepsEditedStaleFile(t *testing.T) {
+	root := t.TempDir()
+	mergeRun(t, root, false, map[string]string{"app/main.tf": mainV1, "app/svc-old.tf": mainV1})
+	writeMergeFile(t, root, "app/svc-old.tf", edited)
+	report := mergeRun(t, root, false, map[string]string{"app/main.tf": mainV1})
+
+	checkMergeReport(t, report, MergeReport{KeptStale: []string{mergeTenantDir + "/app/svc-old.tf"}})
+	if got := readMergeFile(t, root, "app/svc-old.tf"); got != edited {
+		t.Errorf("svc-old.tf is %q, want the edited file", got)
+	}
+	if got, want := trackedFiles(t, root), []string{"app/main.tf"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tracked files are %v, want %v", got, want)
+	}
+	// The file belongs to the user now, it is no longer reported.
+	report = mergeRun(t, root, false, map[string]string{"app/main.tf": mainV1})
+	checkMergeReport(t, report, MergeReport{})
+}
+
+func TestMergeKeepStale(t *testing.T) {
+	root := t.TempDir()
+	mergeRun(t, root, false, map[string]string{"app/main.tf": mainV1, "app/svc-old.tf": mainV1})
+	// A failed run keeps the files it did not generate, and still tracks them.
+	report := mergeRun(t, root, true, map[string]string{"app/main.tf": mainV1})
+
+	checkMergeReport(t, report, MergeReport{KeptStale: []string{mergeTenantDir + "/app/svc-old.tf"}})
+	if got := readMergeFile(t, root, "app/svc-old.tf"); got != mainV1 {
+		t.Errorf("svc-old.tf is %q, want %q", got, mainV1)
+	}
+	if got, want := trackedFiles(t, root), []string{"app/main.tf", "app/svc-old.tf"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tracked files are %v, want %v", got, want)
+	}
+	report = mergeRun(t, root, false, map[string]string{"app/main.tf": mainV1})
+	checkMergeReport(t, report, MergeReport{Removed: []string{mergeTenantDir + "/app/svc-old.tf"}})
+}
+
+func TestMergeAdoptsFilesWithoutManifest(t *testing.T) {
+	root := t.TempDir()
+	// Left by a run without --merge: the same content is adopted, other content is kept as edited.
+	writeMergeFile(t, root, "app/main.tf", mainV1)
+	writeMergeFile(t, root, "app/vars.tf", edited)
+	report := mergeRun(t, root, false, map[string]string{"app/main.tf": mainV1, "app/vars.tf": mainV1})
+
+	checkMergeReport(t, report, MergeReport{Conflicts: []string{mergeTenantDir + "/app/vars.tf"}})
+	if got, want := trackedFiles(t, root), []string{"app/main.tf", "app/vars.tf.new"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tracked files are %v, want %v", got, want)
+	}
+	mergeRun(t, root, false, map[string]string{"app/main.tf": mainV2})
+	if got := readMergeFile(t, root, "app/main.tf"); got != mainV2 {
+		t.Errorf("the adopted main.tf is %q, want %q", got, mainV2)
+	}
+}
+
+func TestMergeWritten(t *testing.T) {
+	root := t.TempDir()
+	writeMergeFile(t, root, "app/custom.tf", edited)
+	o, err := NewMergeOutput(&DirOutput{Root: root}, mergeTenantDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.WriteFile(mergeTenantDir+"/app/main.tf", []byte(mainV1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !o.Written(o.Path(mergeTenantDir + "/app/main.tf")) {
+		t.Errorf("main.tf is not reported as written")
+	}
+	if o.Written(o.Path(mergeTenantDir + "/app/custom.tf")) {
+		t.Errorf("custom.tf of the user is reported as written")
+	}
+}
+
+func TestMergeRejectsUnknownManifestVersion(t *testing.T) {
+	root := t.TempDir()
+	writeMergeFile(t, root, ManifestFile, `{"version": 2, "files": {}}`)
+	_, err := NewMergeOutput(&DirOutput{Root: root}, mergeTenantDir)
+	if err == nil || !strings.Contains(err.Error(), "unsupported manifest version 2") {
+		t.Errorf("got error %v, want the version to be rejected", err)
+	}
+}
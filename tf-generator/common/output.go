@@ -0,0 +1,312 @@
+package common
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Output receives the generated files.
+// Names are slash separated and relative to the root of the output, e.g. "duplo/test/terraform/app/main.tf".
+// Generators run concurrently so implementations must be safe for concurrent use.
+type Output interface {
+	// WriteFile writes a whole file, replacing it if it was already written.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	// Close flushes the output, no file can be written afterwards.
+	Close() error
+}
+
+// NewOutput creates the output described by spec:
+// "-" or "stdout" streams the files to stdout, a path ending in .tar.gz, .tgz or .zip writes an archive,
+// and any other value is the directory the files are written to.
+func NewOutput(spec string) (Output, error) {
+	switch {
+	case len(spec) == 0:
+		return nil, fmt.Errorf("output must not be empty")
+	case spec == "-" || spec == "stdout":
+		return NewStreamOutput(os.Stdout), nil
+	case strings.HasSuffix(spec, ".tar.gz") || strings.HasSuffix(spec, ".tgz"):
+		return NewArchiveOutput(spec, ArchiveTarGz), nil
+	case strings.HasSuffix(spec, ".zip"):
+		return NewArchiveOutput(spec, ArchiveZip), nil
+	}
+	return &DirOutput{Root: spec}, nil
+}
+
+// LocalOutput is implemented by the outputs writing to the local file system, terraform can only run on those.
+type LocalOutput interface {
+	Output
+	// Path returns the local path of a file or directory of the output.
+	Path(name string) string
+}
+
+// DirOutput writes the files below a directory of the local file system.
+type DirOutput struct {
+	Root string
+}
+
+// Path returns the local path of a file or directory of the output.
+func (o *DirOutput) Path(name string) string {
+	return filepath.Join(o.Root, filepath.FromSlash(name))
+}
+
+func (o *DirOutput) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	p := o.Path(name)
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, perm)
+}
+
+// RemoveAll removes a directory of the output and everything it contains.
+func (o *DirOutput) RemoveAll(name string) error {
+	return os.RemoveAll(o.Path(name))
+}
+
+func (o *DirOutput) Close() error {
+	return nil
+}
+
+// MemoryFile is a file written to a MemoryOutput.
+type MemoryFile struct {
+	Data []byte
+	Mode fs.FileMode
+}
+
+// MemoryOutput keeps the files in memory, it is meant for tests and for using the generators as a library.
+type MemoryOutput struct {
+	mu    sync.Mutex
+	files map[string]MemoryFile
+}
+
+// NewMemoryOutput creates an empty MemoryOutput.
+func NewMemoryOutput() *MemoryOutput {
+	return &MemoryOutput{files: map[string]MemoryFile{}}
+}
+
+func (o *MemoryOutput) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	name, err := cleanOutputName(name)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.files[name] = MemoryFile{Data: append([]byte(nil), data...), Mode: perm}
+	return nil
+}
+
+// Names returns the names of the written files, sorted.
+func (o *MemoryOutput) Names() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	names := make([]string, 0, len(o.files))
+	for name := range o.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// File returns a written file.
+func (o *MemoryOutput) File(name string) (MemoryFile, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	f, ok := o.files[path.Clean(name)]
+	return f, ok
+}
+
+func (o *MemoryOutput) Close() error {
+	return nil
+}
+
+// Archive formats supported by ArchiveOutput.
+const (
+	ArchiveTarGz = "tar.gz"
+	ArchiveZip   = "zip"
+)
+
+// ArchiveOutput writes all the files to a single tar.gz or zip archive when it is closed.
+// Entries are sorted by name so the archive does not depend on the order in which generators ran.
+type ArchiveOutput struct {
+	MemoryOutput
+	Path   string
+	Format string
+}
+
+// NewArchiveOutput creates an output writing an archive of the given format to path.
+func NewArchiveOutput(path string, format string) *ArchiveOutput {
+	return &ArchiveOutput{MemoryOutput: *NewMemoryOutput(), Path: path, Format: format}
+}
+
+func (o *ArchiveOutput) Close() error {
+	if dir := filepath.Dir(o.Path); dir != "." {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(o.Path)
+	if err != nil {
+		return err
+	}
+	if err := o.writeArchive(f); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing archive %s: %s", o.Path, err)
+	}
+	return f.Close()
+}
+
+func (o *ArchiveOutput) writeArchive(w io.Writer) error {
+	modTime := time.Now()
+	switch o.Format {
+	case ArchiveTarGz:
+		gw := gzip.NewWriter(w)
+		tw := tar.NewWriter(gw)
+		for _, name := range o.Names() {
+			f, _ := o.File(name)
+			hdr := &tar.Header{Name: name, Mode: int64(f.Mode.Perm()), Size: int64(len(f.Data)), ModTime: modTime, Typeflag: tar.TypeReg}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if _, err := tw.Write(f.Data); err != nil {
+				return err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		return gw.Close()
+	case ArchiveZip:
+		zw := zip.NewWriter(w)
+		for _, name := range o.Names() {
+			f, _ := o.File(name)
+			hdr := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+			hdr.SetMode(f.Mode.Perm())
+			fw, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			if _, err := fw.Write(f.Data); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	}
+	return fmt.Errorf("unknown archive format %q", o.Format)
+}
+
+// StreamOutput prints all the files to a writer when it is closed, each one preceded by a header line with its name.
+type StreamOutput struct {
+	MemoryOutput
+	w io.Writer
+}
+
+// NewStreamOutput creates an output printing the files to w.
+func NewStreamOutput(w io.Writer) *StreamOutput {
+	return &StreamOutput{MemoryOutput: *NewMemoryOutput(), w: w}
+}
+
+func (o *StreamOutput) Close() error {
+	for _, name := range o.Names() {
+		f, _ := o.File(name)
+		if _, err := fmt.Fprintf(o.w, "# ==> %s <==\n%s", name, f.Data); err != nil {
+			return err
+		}
+		if len(f.Data) > 0 && f.Data[len(f.Data)-1] != '\n' {
+			fmt.Fprintln(o.w)
+		}
+	}
+	return nil
+}
+
+// FormattedOutput formats the terraform files written to out, it is used when terraform fmt cannot run on the output.
+func FormattedOutput(out Output) Output {
+	return formattedOutput{out}
+}
+
+type formattedOutput struct {
+	Output
+}
+
+func (o formattedOutput) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return o.Output.WriteFile(name, formatTF(name, data), perm)
+}
+
+func formatTF(name string, data []byte) []byte {
+	if strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tfvars") {
+		return hclwrite.Format(data)
+	}
+	return data
+}
+
+// ReadOutputFile returns a file already written to out, or found in its directory on the local file system.
+func ReadOutputFile(out Output, name string) ([]byte, bool) {
+	switch o := out.(type) {
+	case formattedOutput:
+		return ReadOutputFile(o.Output, name)
+	case interface {
+		File(name string) (MemoryFile, bool)
+	}:
+		f, ok := o.File(name)
+		return f.Data, ok
+	case LocalOutput:
+		data, err := os.ReadFile(o.Path(name))
+		return data, err == nil
+	}
+	return nil, false
+}
+
+// CopyDirToOutput writes every file below the local directory src to the output directory dest.
+func CopyDirToOutput(out Output, src string, dest string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return out.WriteFile(path.Join(dest, filepath.ToSlash(rel)), data, info.Mode().Perm())
+	})
+}
+
+// CopyFSToOutput writes every file of fsys, e.g. files embedded in the executable, to the output directory dest.
+func CopyFSToOutput(out Output, fsys fs.FS, dest string) error {
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		return out.WriteFile(path.Join(dest, p), data, 0644)
+	})
+}
+
+func cleanOutputName(name string) (string, error) {
+	name = path.Clean(filepath.ToSlash(name))
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("output file name %q must be relative to the output", name)
+	}
+	return name, nil
+}
@@ -1,9 +1,7 @@
 package common
 
 import (
-	"fmt"
 	"log"
-	"os"
 	"path/filepath"
 
 	"github.com/hashicorp/hcl/v2"
@@ -20,10 +18,11 @@ type OutputVarConfig struct {
 
 type OutputVars struct {
 	TargetLocation string
+	Output         Output
 	OutputVars     []OutputVarConfig
 }
 
-func (ov *OutputVars) Generate() {
+func (ov *OutputVars) Generate() error {
 	if len(ov.OutputVars) > 0 {
 		log.Println("[TRACE] <====== Output Variables TF generation started. =====>")
 
@@ -31,11 +30,6 @@ func (ov *OutputVars) Generate() {
 		hclFile := hclwrite.NewEmptyFile()
 		// create new file on system
 		path := filepath.Join(ov.TargetLocation, "outputs.tf")
-		tfFile, err := os.Create(path)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
 
 		// initialize the body of the new file object
 		rootBody := hclFile.Body()
@@ -64,12 +58,10 @@ func (ov *OutputVars) Generate() {
 			}
 		}
 
-		fmt.Printf("%s", hclFile.Bytes())
-		_, err = tfFile.Write(hclFile.Bytes())
-		if err != nil {
-			fmt.Println(err)
-			return
+		if err := ov.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
+			return err
 		}
 		log.Println("[TRACE] <====== Output Variables TF generation done. =====>")
 	}
+	return nil
 }
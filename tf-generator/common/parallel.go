@@ -0,0 +1,58 @@
+package common
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultParallelism is the number of concurrent workers used when Config.Parallelism is not set.
+const DefaultParallelism = 8
+
+// Workers returns the number of concurrent workers configured for the run.
+func (c *Config) Workers() int {
+	if c.Parallelism > 0 {
+		return c.Parallelism
+	}
+	return DefaultParallelism
+}
+
+// ForEachParallel calls fn for every index in [0, n) using at most workers goroutines.
+// fn must only write results to its own index so the outcome does not depend on scheduling,
+// and the error returned is the one of the lowest failing index.
+// No new calls are started once ctx is done, ctx.Err() is returned when no call failed.
+func ForEachParallel(ctx context.Context, workers, n int, fn func(i int) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+	errs := make([]error, n)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+dispatch:
+	for i := 0; i < n; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
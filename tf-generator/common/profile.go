@@ -0,0 +1,178 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileFile is the config file holding named profiles for DuploCloud portals.
+//
+//	default_profile: prod
+//	profiles:
+//	  prod:
+//	    host: https://prod.duplocloud.net
+//	    token:
+//	      env: DUPLO_PROD_TOKEN
+//	    customer: duplo-msp
+//	    provider_version: 0.8.0
+type ProfileFile struct {
+	DefaultProfile string              `yaml:"default_profile"`
+	Profiles       map[string]*Profile `yaml:"profiles"`
+}
+
+// Profile holds the settings of one DuploCloud portal.
+type Profile struct {
+	Host            string          `yaml:"host"`
+	Token           *TokenSource    `yaml:"token"`
+	SSLNoVerify     *bool           `yaml:"ssl_no_verify"`
+	Customer        string          `yaml:"customer"`
+	CertArn         string          `yaml:"cert_arn"`
+	ProviderVersion string          `yaml:"provider_version"`
+	Projects        ProfileProjects `yaml:"projects"`
+	Backend         ProfileBackend  `yaml:"backend"`
+}
+
+// ProfileProjects overrides the names of the generated terraform projects.
+type ProfileProjects struct {
+	Infra       string `yaml:"infra"`
+	Tenant      string `yaml:"tenant"`
+	AwsServices string `yaml:"aws_services"`
+	App         string `yaml:"app"`
+}
+
+// ProfileBackend holds the terraform backend settings of a profile.
+type ProfileBackend struct {
+	S3             *bool  `yaml:"s3"`
+	Type           string `yaml:"type"`
+	Bucket         string `yaml:"bucket"`
+	Region         string `yaml:"region"`
+	LockTable      string `yaml:"lock_table"`
+	KeyPrefix      string `yaml:"key_prefix"`
+	Path           string `yaml:"path"`
+	Address        string `yaml:"address"`
+	ResourceGroup  string `yaml:"resource_group"`
+	StorageAccount string `yaml:"storage_account"`
+	Container      string `yaml:"container"`
+	Organization   string `yaml:"organization"`
+	Hostname       string `yaml:"hostname"`
+}
+
+// TokenSource tells where the DuploCloud token of a profile is read from, exactly one source must be set.
+type TokenSource struct {
+	Value   string `yaml:"value"`
+	Env     string `yaml:"env"`
+	File    string `yaml:"file"`
+	Command string `yaml:"command"`
+}
+
+// DefaultProfileFilePath returns the path of the profile file used when none is given.
+func DefaultProfileFilePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".tenant-terraform-generator.yaml")
+}
+
+// LoadProfileFile reads and validates a profile file.
+func LoadProfileFile(path string) (*ProfileFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading profile file: %s", err)
+	}
+	pf := &ProfileFile{}
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	if err := dec.Decode(pf); err != nil {
+		return nil, fmt.Errorf("error parsing profile file %s: %s", path, err)
+	}
+	for name, p := range pf.Profiles {
+		if p == nil {
+			return nil, fmt.Errorf("profile %q in %s is empty", name, path)
+		}
+		if err := p.Token.validate(); err != nil {
+			return nil, fmt.Errorf("profile %q in %s: %s", name, path, err)
+		}
+	}
+	return pf, nil
+}
+
+// Profile returns the named profile, or the default profile when name is empty.
+func (pf *ProfileFile) Profile(name string) (*Profile, error) {
+	if len(name) == 0 {
+		name = pf.DefaultProfile
+	}
+	if len(name) == 0 {
+		return nil, nil
+	}
+	p, ok := pf.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(pf.Profiles))
+		for n := range pf.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("profile %q not found, available profiles: %s", name, strings.Join(names, ", "))
+	}
+	return p, nil
+}
+
+func (ts *TokenSource) validate() error {
+	if ts == nil {
+		return nil
+	}
+	count := 0
+	for _, s := range []string{ts.Value, ts.Env, ts.File, ts.Command} {
+		if len(s) > 0 {
+			count++
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("token must have exactly one of value, env, file or command")
+	}
+	return nil
+}
+
+// Resolve reads the token from its source.
+func (ts *TokenSource) Resolve() (string, error) {
+	if ts == nil {
+		return "", nil
+	}
+	switch {
+	case len(ts.Value) > 0:
+		return ts.Value, nil
+	case len(ts.Env) > 0:
+		token := os.Getenv(ts.Env)
+		if len(token) == 0 {
+			return "", fmt.Errorf("env variable \"%s\" holding the token is empty", ts.Env)
+		}
+		return token, nil
+	case len(ts.File) > 0:
+		path := ts.File
+		if strings.HasPrefix(path, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			path = filepath.Join(home, path[2:])
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("error reading token file: %s", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case len(ts.Command) > 0:
+		out, err := exec.Command("sh", "-c", ts.Command).Output()
+		if err != nil {
+			return "", fmt.Errorf("error running token command %q: %s", ts.Command, err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,176 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadProfileFile(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		// profile is the name of the profile to select, empty for the default one.
+		profile string
+		// want is the host of the selected profile, empty when there is none.
+		want string
+		// wantErr is part of the expected error, of the loading or the selection of the profile.
+		wantErr string
+	}{
+		{
+			name: "default profile",
+			file: `default_profile: prod
+profiles:
+  prod:
+    host: https://prod.duplocloud.net
+    token:
+      env: DUPLO_PROD_TOKEN
+    backend:
+      type: gcs
+      bucket: states
+  qa:
+    host: https://qa.duplocloud.net
+`,
+			want: "https://prod.duplocloud.net",
+		},
+		{
+			name: "named profile",
+			file: `default_profile: prod
+profiles:
+  prod:
+    host: https://prod.duplocloud.net
+  qa:
+    host: https://qa.duplocloud.net
+`,
+			profile: "qa",
+			want:    "https://qa.duplocloud.net",
+		},
+		{
+			name: "no default profile",
+			file: `profiles:
+  prod:
+    host: https://prod.duplocloud.net
+`,
+		},
+		{
+			name: "unknown profile",
+			file: `profiles:
+  prod:
+    host: https://prod.duplocloud.net
+  dev:
+    host: https://dev.duplocloud.net
+`,
+			profile: "qa",
+			wantErr: `profile "qa" not found, available profiles: dev, prod`,
+		},
+		{
+			name: "unknown field",
+			file: `profiles:
+  prod:
+    hostname: https://prod.duplocloud.net
+`,
+			wantErr: "field hostname not found",
+		},
+		{
+			name:    "invalid yaml",
+			file:    "profiles: [prod\n",
+			wantErr: "error parsing profile file",
+		},
+		{
+			name: "empty profile",
+			file: `profiles:
+  prod:
+`,
+			wantErr: `profile "prod" in`,
+		},
+		{
+			name: "several token sources",
+			file: `profiles:
+  prod:
+    token:
+      value: abc
+      env: DUPLO_PROD_TOKEN
+`,
+			wantErr: "token must have exactly one of value, env, file or command",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "profiles.yaml")
+			if err := os.WriteFile(path, []byte(tt.file), 0600); err != nil {
+				t.Fatal(err)
+			}
+			pf, err := LoadProfileFile(path)
+			var p *Profile
+			if err == nil {
+				p, err = pf.Profile(tt.profile)
+			}
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			host := ""
+			if p != nil {
+				host = p.Host
+			}
+			if host != tt.want {
+				t.Errorf("host is %q, want %q", host, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadProfileFileMissing(t *testing.T) {
+	_, err := LoadProfileFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "error reading profile file") {
+		t.Errorf("got error %v, want the file to be missing", err)
+	}
+}
+
+func TestTokenSourceResolve(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DUPLO_TEST_TOKEN", "env-token")
+	t.Setenv("DUPLO_EMPTY_TOKEN", "")
+	tests := []struct {
+		name    string
+		source  *TokenSource
+		want    string
+		wantErr string
+	}{
+		{name: "none", source: nil},
+		{name: "value", source: &TokenSource{Value: "value-token"}, want: "value-token"},
+		{name: "env", source: &TokenSource{Env: "DUPLO_TEST_TOKEN"}, want: "env-token"},
+		{name: "empty env", source: &TokenSource{Env: "DUPLO_EMPTY_TOKEN"}, wantErr: `env variable "DUPLO_EMPTY_TOKEN" holding the token is empty`},
+		{name: "file", source: &TokenSource{File: tokenFile}, want: "file-token"},
+		{name: "missing file", source: &TokenSource{File: filepath.Join(dir, "missing")}, wantErr: "error reading token file"},
+		{name: "command", source: &TokenSource{Command: "echo command-token"}, want: "command-token"},
+		{name: "failing command", source: &TokenSource{Command: "exit 1"}, wantErr: `error running token command "exit 1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.source.Resolve()
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("token is %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
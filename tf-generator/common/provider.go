@@ -3,13 +3,10 @@ package common
 import (
 	"fmt"
 	"log"
-	"os"
 	"path/filepath"
 
 	"github.com/hashicorp/hcl/v2"
 
-	"tenant-terraform-generator/duplosdk"
-
 	"github.com/hashicorp/hcl/v2/hclwrite"
 
 	"github.com/zclconf/go-cty/cty"
@@ -18,7 +15,7 @@ import (
 type Provider struct {
 }
 
-func (p *Provider) Generate(config *Config, client *duplosdk.Client) {
+func (p *Provider) Generate(config *Config) error {
 	log.Println("[TRACE] <====== Provider TF generation started. =====>")
 	log.Printf("Config - %s", fmt.Sprintf("%#v", config))
 	// create new empty hcl file object
@@ -28,23 +25,6 @@ func (p *Provider) Generate(config *Config, client *duplosdk.Client) {
 	tenantProject := filepath.Join(config.TFCodePath, config.TenantProject, "providers.tf")
 	awsServicesProject := filepath.Join(config.TFCodePath, config.AwsServicesProject, "providers.tf")
 	appProject := filepath.Join(config.TFCodePath, config.AppProject, "providers.tf")
-	tenantProjectFile, err := os.Create(tenantProject)
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-	awsServicesProjectFile, err := os.Create(awsServicesProject)
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-
-	appProjectFile, err := os.Create(appProject)
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-
 	// initialize the body of the new file object
 	rootBody := hclFile.Body()
 
@@ -53,7 +33,7 @@ func (p *Provider) Generate(config *Config, client *duplosdk.Client) {
 		nil)
 	tfBlockBody := tfBlock.Body()
 	tfBlockBody.SetAttributeValue("required_version",
-		cty.StringVal(">= 0.14.11"))
+		cty.StringVal(">= "+config.MinTerraformVersion()))
 
 	reqProvsBlock := tfBlockBody.AppendNewBlock("required_providers",
 		nil)
@@ -89,16 +69,17 @@ func (p *Provider) Generate(config *Config, client *duplosdk.Client) {
 	})
 	awsProviderBody.AppendNewline()
 
-	fmt.Printf("%s", hclFile.Bytes())
-	_, err = tenantProjectFile.Write(hclFile.Bytes())
-	if err != nil {
-		fmt.Println(err)
-		return
+	if config.GenerateInfra {
+		infraProject := filepath.Join(config.TFCodePath, config.InfraProject, "providers.tf")
+		if err := config.Output.WriteFile(infraProject, hclFile.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	if err := config.Output.WriteFile(tenantProject, hclFile.Bytes(), 0644); err != nil {
+		return err
 	}
-	_, err = appProjectFile.Write(hclFile.Bytes())
-	if err != nil {
-		fmt.Println(err)
-		return
+	if err := config.Output.WriteFile(appProject, hclFile.Bytes(), 0644); err != nil {
+		return err
 	}
 	reqProvsBlockBody.SetAttributeValue("random",
 		cty.ObjectVal(map[string]cty.Value{
@@ -111,11 +92,10 @@ func (p *Provider) Generate(config *Config, client *duplosdk.Client) {
 	randomProviderBody := randomProvider.Body()
 	randomProviderBody.AppendNewline()
 
-	_, err = awsServicesProjectFile.Write(hclFile.Bytes())
-	if err != nil {
-		fmt.Println(err)
-		return
+	if err := config.Output.WriteFile(awsServicesProject, hclFile.Bytes(), 0644); err != nil {
+		return err
 	}
 
 	log.Println("[TRACE] <====== Provider TF generation done. =====>")
+	return nil
 }
@@ -0,0 +1,389 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// minReferenceValueLength is the length of the shortest value indexed, shorter values match unrelated literals too easily.
+const minReferenceValueLength = 8
+
+// Reference is a value identifying a generated resource, like the full name or the ARN of a bucket,
+// with the attribute of the resource which exports it.
+type Reference struct {
+	Value string
+	// Address is the address of the resource, e.g. duplocloud_s3_bucket.assets.
+	Address string
+	// Attribute is the attribute exporting Value, e.g. arn or endpoints["vpc"].
+	Attribute string
+}
+
+// Expression returns the reference to the attribute, e.g. duplocloud_s3_bucket.assets.arn.
+func (r Reference) Expression() string {
+	return r.Address + "." + r.Attribute
+}
+
+// ResourceReferences returns the references to the attributes of the resource at address, values are by attribute name.
+// Empty values are skipped.
+func ResourceReferences(address string, values map[string]string) []Reference {
+	refs := []Reference{}
+	for attr, value := range values {
+		if len(value) > 0 {
+			refs = append(refs, Reference{Value: value, Address: address, Attribute: attr})
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Attribute < refs[j].Attribute
+	})
+	return refs
+}
+
+// ReferenceIndex maps the values identifying the resources of every project to the attributes exporting them.
+type ReferenceIndex struct {
+	refs map[string]*indexedReference
+	// values lists the indexed values, longest first.
+	values []string
+}
+
+type indexedReference struct {
+	Reference
+	Project   string
+	traversal hcl.Traversal
+	// ambiguous is set when the value identifies several resources, it is then left as is.
+	ambiguous bool
+}
+
+// NewReferenceIndex creates an empty index.
+func NewReferenceIndex() *ReferenceIndex {
+	return &ReferenceIndex{refs: map[string]*indexedReference{}}
+}
+
+// Add indexes the references of the resources generated in project.
+func (idx *ReferenceIndex) Add(project string, refs []Reference) {
+	for _, r := range refs {
+		if len(r.Value) < minReferenceValueLength {
+			continue
+		}
+		traversal, diags := hclsyntax.ParseTraversalAbs([]byte(r.Expression()), "", hcl.InitialPos)
+		if diags.HasErrors() {
+			log.Printf("[TRACE] Skipping reference %s: %s", r.Expression(), diags.Error())
+			continue
+		}
+		if prev, ok := idx.refs[r.Value]; ok {
+			if prev.Project != project || prev.Address != r.Address {
+				log.Printf("[TRACE] %q identifies both %s and %s, it is not replaced.", r.Value, prev.Address, r.Address)
+				prev.ambiguous = true
+			}
+			continue
+		}
+		idx.refs[r.Value] = &indexedReference{Reference: r, Project: project, traversal: traversal}
+		idx.values = append(idx.values, r.Value)
+	}
+	sort.SliceStable(idx.values, func(i, j int) bool {
+		return len(idx.values[i]) > len(idx.values[j])
+	})
+}
+
+// Len returns the number of values indexed.
+func (idx *ReferenceIndex) Len() int {
+	return len(idx.refs)
+}
+
+// ReferenceRewriter replaces the literals of the resources of a project which equal or contain an indexed value
+// with references to the attribute exporting the value.
+// A literal equal to a value becomes a reference, and a value found in a longer literal, a jsonencoded blob or
+// a JSON document becomes an interpolation. A resource never refers to itself nor to a resource depending on it.
+type ReferenceRewriter struct {
+	Index   *ReferenceIndex
+	Project string
+	// Remote returns the expression referencing the attribute of a resource of another project,
+	// or nil when the project cannot refer to it.
+	Remote func(ref Reference, project string) hcl.Traversal
+
+	// dependencies are the resources each resource of the project refers to, by address.
+	dependencies map[string]map[string]bool
+}
+
+// Rewrite rewrites the terraform files of the project, by name, and returns the files which changed.
+func (rw *ReferenceRewriter) Rewrite(files map[string][]byte) (map[string][]byte, error) {
+	changed := map[string][]byte{}
+	if rw.Index.Len() == 0 {
+		return changed, nil
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rw.dependencies = map[string]map[string]bool{}
+	parsed := map[string]*hclwrite.File{}
+	for _, name := range names {
+		f, diags := hclwrite.ParseConfig(files[name], name, hcl.InitialPos)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("error parsing %s: %s", name, diags.Error())
+		}
+		parsed[name] = f
+		syntaxFile, diags := hclsyntax.ParseConfig(files[name], name, hcl.InitialPos)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("error parsing %s: %s", name, diags.Error())
+		}
+		for _, block := range syntaxFile.Body.(*hclsyntax.Body).Blocks {
+			if address, ok := rewrittenBlockAddress(block.Type, block.Labels); ok {
+				rw.addDependencies(address, block.Body)
+			}
+		}
+	}
+
+	for _, name := range names {
+		fileChanged := false
+		for _, block := range parsed[name].Body().Blocks() {
+			address, ok := rewrittenBlockAddress(block.Type(), block.Labels())
+			if !ok {
+				continue
+			}
+			if rw.rewriteBody(block.Body(), address) {
+				fileChanged = true
+			}
+		}
+		if fileChanged {
+			changed[name] = parsed[name].Bytes()
+		}
+	}
+	return changed, nil
+}
+
+// rewrittenBlockAddress returns the address of a resource or module block, whose literals are rewritten.
+// The modules get the documents of the objects which refer to other resources, e.g. the specs of the services.
+func rewrittenBlockAddress(blockType string, labels []string) (string, bool) {
+	switch {
+	case blockType == "resource" && len(labels) == 2:
+		return labels[0] + "." + labels[1], true
+	case blockType == "module" && len(labels) == 1:
+		return "module." + labels[0], true
+	}
+	return "", false
+}
+
+// addDependencies records the resources referenced by the expressions of body and of its nested blocks.
+func (rw *ReferenceRewriter) addDependencies(address string, body *hclsyntax.Body) {
+	for _, attr := range body.Attributes {
+		for _, traversal := range hclsyntax.Variables(attr.Expr) {
+			if len(traversal) < 2 {
+				continue
+			}
+			if step, ok := traversal[1].(hcl.TraverseAttr); ok {
+				rw.addDependency(address, traversal.RootName()+"."+step.Name)
+			}
+		}
+	}
+	for _, nested := range body.Blocks {
+		rw.addDependencies(address, nested.Body)
+	}
+}
+
+func (rw *ReferenceRewriter) addDependency(from, to string) {
+	if rw.dependencies[from] == nil {
+		rw.dependencies[from] = map[string]bool{}
+	}
+	rw.dependencies[from][to] = true
+}
+
+// dependsOn reports whether the resource at from refers to the resource at to, directly or not.
+func (rw *ReferenceRewriter) dependsOn(from, to string) bool {
+	seen := map[string]bool{}
+	pending := []string{from}
+	for len(pending) > 0 {
+		address := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if address == to {
+			return true
+		}
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+		for dep := range rw.dependencies[address] {
+			pending = append(pending, dep)
+		}
+	}
+	return false
+}
+
+// rewriteBody rewrites the attributes of a resource block and of its nested blocks, except lifecycle.
+func (rw *ReferenceRewriter) rewriteBody(body *hclwrite.Body, address string) bool {
+	changed := false
+	attrs := body.Attributes()
+	attrNames := make([]string, 0, len(attrs))
+	for name := range attrs {
+		attrNames = append(attrNames, name)
+	}
+	sort.Strings(attrNames)
+	for _, name := range attrNames {
+		if name == "depends_on" || name == "provider" || name == "source" && strings.HasPrefix(address, "module.") {
+			continue
+		}
+		if tokens, ok := rw.rewriteTokens(attrs[name].Expr().BuildTokens(nil), address); ok {
+			body.SetAttributeRaw(name, tokens)
+			changed = true
+		}
+	}
+	for _, nested := range body.Blocks() {
+		if nested.Type() == "lifecycle" {
+			continue
+		}
+		if rw.rewriteBody(nested.Body(), address) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// rewriteTokens rewrites the quoted strings of an expression, object keys are left as is.
+func (rw *ReferenceRewriter) rewriteTokens(tokens hclwrite.Tokens, address string) (hclwrite.Tokens, bool) {
+	closing := map[int]int{}
+	open := []int{}
+	for i, t := range tokens {
+		switch t.Type {
+		case hclsyntax.TokenOQuote:
+			open = append(open, i)
+		case hclsyntax.TokenCQuote:
+			if len(open) > 0 {
+				closing[open[len(open)-1]] = i
+				open = open[:len(open)-1]
+			}
+		}
+	}
+
+	out := hclwrite.Tokens{}
+	changed := false
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.Type {
+		case hclsyntax.TokenOQuote:
+			end, ok := closing[i]
+			if !ok {
+				break
+			}
+			if end+1 < len(tokens) && (tokens[end+1].Type == hclsyntax.TokenEqual || tokens[end+1].Type == hclsyntax.TokenColon) {
+				out = append(out, tokens[i:end+1]...)
+				i = end
+				continue
+			}
+			if end == i+2 && tokens[i+1].Type == hclsyntax.TokenQuotedLit {
+				if traversal := rw.resolve(string(tokens[i+1].Bytes), address); traversal != nil {
+					reference := hclwrite.TokensForTraversal(traversal)
+					reference[0].SpacesBefore = t.SpacesBefore
+					out = append(out, reference...)
+					i = end
+					changed = true
+					continue
+				}
+			}
+		case hclsyntax.TokenQuotedLit:
+			if replaced, ok := rw.rewriteLiteral(t, address); ok {
+				out = append(out, replaced...)
+				changed = true
+				continue
+			}
+		}
+		out = append(out, t)
+	}
+	return out, changed
+}
+
+// rewriteLiteral replaces the indexed values found in a part of a quoted string with interpolations.
+// Values are only matched as a whole word, the longest value found first at a position wins.
+func (rw *ReferenceRewriter) rewriteLiteral(t *hclwrite.Token, address string) (hclwrite.Tokens, bool) {
+	lit := string(t.Bytes)
+	type match struct {
+		start, end int
+		value      string
+	}
+	matches := []match{}
+	for _, value := range rw.Index.values {
+		if len(value) > len(lit) || rw.Index.refs[value].ambiguous {
+			continue
+		}
+		for offset := 0; ; {
+			i := strings.Index(lit[offset:], value)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			end := start + len(value)
+			if (start == 0 || !isWordByte(lit[start-1])) && (end == len(lit) || !isWordByte(lit[end])) {
+				matches = append(matches, match{start: start, end: end, value: value})
+			}
+			offset = start + 1
+		}
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].end > matches[j].end
+	})
+
+	out := hclwrite.Tokens{}
+	last := 0
+	for _, m := range matches {
+		if m.start < last {
+			continue
+		}
+		traversal := rw.resolve(m.value, address)
+		if traversal == nil {
+			continue
+		}
+		if m.start > last {
+			out = append(out, &hclwrite.Token{Type: hclsyntax.TokenQuotedLit, Bytes: []byte(lit[last:m.start])})
+		}
+		out = append(out, &hclwrite.Token{Type: hclsyntax.TokenTemplateInterp, Bytes: []byte("${")})
+		for _, rt := range hclwrite.TokensForTraversal(traversal) {
+			rt.SpacesBefore = 0
+			out = append(out, rt)
+		}
+		out = append(out, &hclwrite.Token{Type: hclsyntax.TokenTemplateSeqEnd, Bytes: []byte("}")})
+		last = m.end
+	}
+	if last == 0 {
+		return nil, false
+	}
+	if last < len(lit) {
+		out = append(out, &hclwrite.Token{Type: hclsyntax.TokenQuotedLit, Bytes: []byte(lit[last:])})
+	}
+	out[0].SpacesBefore = t.SpacesBefore
+	return out, true
+}
+
+// resolve returns the reference replacing value in the resource at address, or nil when value is left as is.
+func (rw *ReferenceRewriter) resolve(value, address string) hcl.Traversal {
+	ref, ok := rw.Index.refs[value]
+	if !ok || ref.ambiguous {
+		return nil
+	}
+	if ref.Project != rw.Project {
+		if rw.Remote == nil {
+			return nil
+		}
+		return rw.Remote(ref.Reference, ref.Project)
+	}
+	if ref.Address == address || rw.dependsOn(ref.Address, address) {
+		return nil
+	}
+	rw.addDependency(address, ref.Address)
+	return ref.traversal
+}
+
+func isWordByte(c byte) bool {
+	return c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
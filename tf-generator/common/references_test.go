@@ -0,0 +1,94 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+func TestReferenceRewriter(t *testing.T) {
+	index := NewReferenceIndex()
+	index.Add("aws-services", ResourceReferences("duplocloud_s3_bucket.assets", map[string]string{
+		"fullname": "duploservices-test-assets-1000",
+		"arn":      "arn:aws:s3:::duploservices-test-assets-1000",
+	}))
+	index.Add("aws-services", ResourceReferences("duplocloud_aws_sqs_queue.jobs", map[string]string{
+		"arn": "arn:aws:sqs:us-west-2:1000:duploservices-test-jobs",
+	}))
+	index.Add("aws-services", ResourceReferences("duplocloud_aws_lambda_function.worker", map[string]string{
+		"fullname": "duploservices-test-worker",
+	}))
+	index.Add("aws-services", []Reference{{Value: "shared-value", Address: "duplocloud_aws_sns_topic.a", Attribute: "arn"}})
+	index.Add("aws-services", []Reference{{Value: "shared-value", Address: "duplocloud_aws_sns_topic.b", Attribute: "arn"}})
+	index.Add("tenant", ResourceReferences("duplocloud_tenant.tenant", map[string]string{
+		"tenant_id": "6a3e1c52-0000-4000-8000-000000000001",
+	}))
+
+	files := map[string][]byte{
+		"lambda.tf": []byte(`resource "duplocloud_aws_lambda_function" "worker" {
+  name      = "duploservices-test-worker"
+  s3_bucket = "duploservices-test-assets-1000"
+  policy    = jsonencode({ "Resource" : "arn:aws:s3:::duploservices-test-assets-1000/*", "duploservices-test-assets-1000" : "x" })
+  other     = "duploservices-test-assets-10000 shared-value"
+  tenant    = "6a3e1c52-0000-4000-8000-000000000001"
+  lifecycle {
+    ignore_changes = ["duploservices-test-assets-1000"]
+  }
+}
+`),
+		"sqs.tf": []byte(`resource "duplocloud_aws_sqs_queue" "jobs" {
+  description = "Triggers duploservices-test-worker"
+}
+resource "duplocloud_s3_bucket" "assets" {
+  notify = "arn:aws:sqs:us-west-2:1000:duploservices-test-jobs"
+}
+`),
+	}
+	rewriter := &ReferenceRewriter{
+		Index:   index,
+		Project: "aws-services",
+		Remote: func(ref Reference, project string) hcl.Traversal {
+			return hcl.Traversal{hcl.TraverseRoot{Name: "remote"}, hcl.TraverseAttr{Name: ref.Attribute}}
+		},
+	}
+	changed, err := rewriter.Rewrite(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"lambda.tf": `resource "duplocloud_aws_lambda_function" "worker" {
+  name      = "duploservices-test-worker"
+  s3_bucket = duplocloud_s3_bucket.assets.fullname
+  policy    = jsonencode({ "Resource" : "${duplocloud_s3_bucket.assets.arn}/*", "duploservices-test-assets-1000" : "x" })
+  other     = "duploservices-test-assets-10000 shared-value"
+  tenant    = remote.tenant_id
+  lifecycle {
+    ignore_changes = ["duploservices-test-assets-1000"]
+  }
+}
+`,
+		// The bucket cannot refer to the queue, the queue depends on it through the lambda function.
+		"sqs.tf": `resource "duplocloud_aws_sqs_queue" "jobs" {
+  description = "Triggers ${duplocloud_aws_lambda_function.worker.fullname}"
+}
+resource "duplocloud_s3_bucket" "assets" {
+  notify = "arn:aws:sqs:us-west-2:1000:duploservices-test-jobs"
+}
+`,
+	}
+	for name, w := range want {
+		got := string(hclwrite.Format(changed[name]))
+		if got != w {
+			t.Errorf("%s is\n%s\nwant\n%s", name, got, w)
+		}
+	}
+	if len(changed) != len(want) {
+		names := []string{}
+		for name := range changed {
+			names = append(names, name)
+		}
+		t.Errorf("changed files are %s", strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,190 @@
+package common
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"tenant-terraform-generator/duplosdk"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// SecretRule matches a kind of secret in the source of an attribute.
+// When Pattern has a group, only the text of the first group is the secret, the rest is context.
+type SecretRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// SecretRules are the patterns the generated files are scanned for, before the high entropy strings.
+var SecretRules = []SecretRule{
+	{Name: "aws-access-key-id", Pattern: regexp.MustCompile(`\b((?:AKIA|ASIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA|A3T[A-Z0-9])[A-Z0-9]{16})\b`)},
+	{Name: "aws-secret-access-key", Pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key\\?["']?\s*[:=]\s*\\?["']?([A-Za-z0-9/+=]{40})\b`)},
+	{Name: "private-key", Pattern: regexp.MustCompile(`(-----BEGIN[A-Z ]*PRIVATE KEY-----(?s:.*?)(?:-----END[A-Z ]*PRIVATE KEY-----|$))`)},
+	{Name: "jwt", Pattern: regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,})`)},
+	{Name: "connection-string", Pattern: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@"'$\\]+:([^\s/@"'$\\]+)@`)},
+}
+
+const (
+	// HighEntropyRule names the findings of random looking strings, like generated passwords and API keys.
+	HighEntropyRule = "high-entropy-string"
+
+	minEntropyLength = 20
+	// minEntropy is the Shannon entropy, in bits per character, above which a string looks random.
+	minEntropy = 4.0
+)
+
+var entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/=_-]{20,}`)
+
+// SecretFinding is a possible secret of a generated file.
+type SecretFinding struct {
+	File string
+	Line int
+	// Resource is the address of the block holding the attribute, e.g. duplocloud_duplo_service.nginx.
+	Resource string
+	// Attribute is the path of the attribute in the block, e.g. environment.variables.
+	Attribute string
+	Rule      string
+	// Start and End are the byte offsets of the secret in the file.
+	Start int
+	End   int
+}
+
+// ScanSecrets returns the possible secrets found in the attributes of a terraform file, sorted by offset.
+// Files which cannot be parsed are scanned as a whole.
+func ScanSecrets(name string, data []byte) []SecretFinding {
+	findings := []SecretFinding{}
+	file, diags := hclsyntax.ParseConfig(data, name, hcl.InitialPos)
+	if diags.HasErrors() {
+		findings = scanSource(data, 0, len(data), findings, SecretFinding{File: name, Resource: "-", Attribute: "-"})
+	} else {
+		findings = scanBody(data, file.Body.(*hclsyntax.Body), findings, SecretFinding{File: name, Resource: "-"}, "")
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Start < findings[j].Start
+	})
+	for i := range findings {
+		findings[i].Line = 1 + strings.Count(string(data[:findings[i].Start]), "\n")
+	}
+	return findings
+}
+
+func scanBody(data []byte, body *hclsyntax.Body, findings []SecretFinding, at SecretFinding, prefix string) []SecretFinding {
+	names := make([]string, 0, len(body.Attributes))
+	for name := range body.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r := body.Attributes[name].Expr.Range()
+		at.Attribute = prefix + name
+		findings = scanSource(data, r.Start.Byte, r.End.Byte, findings, at)
+	}
+	for _, block := range body.Blocks {
+		if len(prefix) == 0 && at.Resource == "-" {
+			blockAt := at
+			blockAt.Resource = blockAddress(block)
+			findings = scanBody(data, block.Body, findings, blockAt, "")
+			continue
+		}
+		findings = scanBody(data, block.Body, findings, at, prefix+block.Type+".")
+	}
+	return findings
+}
+
+// blockAddress returns the address of a top level block, like terraform shows it.
+func blockAddress(block *hclsyntax.Block) string {
+	switch {
+	case block.Type == "resource":
+		return strings.Join(block.Labels, ".")
+	case len(block.Labels) == 0:
+		return block.Type
+	default:
+		return block.Type + "." + strings.Join(block.Labels, ".")
+	}
+}
+
+// scanSource appends the secrets found in data[start:end] to findings.
+// A text matched by a rule is not reported again as a high entropy string.
+func scanSource(data []byte, start, end int, findings []SecretFinding, at SecretFinding) []SecretFinding {
+	src := string(data[start:end])
+	matched := [][2]int{}
+	for _, rule := range SecretRules {
+		for _, m := range rule.Pattern.FindAllStringSubmatchIndex(src, -1) {
+			if len(m) >= 4 && m[2] >= 0 {
+				m = m[2:4]
+			}
+			// Values redacted by a previous run.
+			if src[m[0]:m[1]] == duplosdk.MaskedValue {
+				continue
+			}
+			at.Rule, at.Start, at.End = rule.Name, start+m[0], start+m[1]
+			findings = append(findings, at)
+			matched = append(matched, [2]int{m[0], m[1]})
+		}
+	}
+	for _, m := range entropyCandidate.FindAllStringIndex(src, -1) {
+		if overlaps(matched, m[0], m[1]) || !looksRandom(src[m[0]:m[1]]) {
+			continue
+		}
+		at.Rule, at.Start, at.End = HighEntropyRule, start+m[0], start+m[1]
+		findings = append(findings, at)
+	}
+	return findings
+}
+
+func overlaps(ranges [][2]int, start, end int) bool {
+	for _, r := range ranges {
+		if start < r[1] && r[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// looksRandom reports whether s mixes lower case letters, upper case letters and digits with a high entropy,
+// names and identifiers rarely do.
+func looksRandom(s string) bool {
+	if len(s) < minEntropyLength {
+		return false
+	}
+	var lower, upper, digit bool
+	counts := map[rune]int{}
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+		counts[c]++
+	}
+	if !lower || !upper || !digit {
+		return false
+	}
+	entropy := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(len(s))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= minEntropy
+}
+
+// RedactSecrets replaces the findings of ScanSecrets in data with duplosdk.MaskedValue.
+func RedactSecrets(data []byte, findings []SecretFinding) []byte {
+	redacted := []byte{}
+	last := 0
+	for _, f := range findings {
+		if f.Start < last {
+			continue
+		}
+		redacted = append(redacted, data[last:f.Start]...)
+		redacted = append(redacted, duplosdk.MaskedValue...)
+		last = f.End
+	}
+	return append(redacted, data[last:]...)
+}
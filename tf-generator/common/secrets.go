@@ -0,0 +1,100 @@
+package common
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	// SecretsInline writes the secret values in the generated resources, as they are read from duplo.
+	SecretsInline = "inline"
+	// SecretsVariables writes the secret values to SecretsFile, the resources refer to them through sensitive variables.
+	SecretsVariables = "variables"
+
+	// SecretsFile holds the values of the sensitive variables of a project, terraform loads it automatically.
+	SecretsFile = "secrets.auto.tfvars"
+)
+
+// Secret is the value of a sensitive variable, written to SecretsFile.
+type Secret struct {
+	Name  string
+	Value cty.Value
+}
+
+// ExternalSecrets reports whether the secret values are kept out of the generated resources.
+func (c *Config) ExternalSecrets() bool {
+	return c.Secrets == SecretsVariables
+}
+
+// SensitiveVar declares a sensitive variable holding value, a string or a map of strings,
+// and returns the traversal referring to it.
+func (c *TFContext) SensitiveVar(name, desc string, value cty.Value) hcl.Traversal {
+	typeVal := "string"
+	if value.Type().IsMapType() {
+		typeVal = "map(string)"
+	}
+	c.InputVars = append(c.InputVars, VarConfig{
+		Name:      name,
+		TypeVal:   typeVal,
+		DescVal:   desc,
+		Sensitive: true,
+	})
+	c.Secrets = append(c.Secrets, Secret{Name: name, Value: value})
+	return hcl.Traversal{
+		hcl.TraverseRoot{
+			Name: "var",
+		},
+		hcl.TraverseAttr{
+			Name: name,
+		},
+	}
+}
+
+// SecretVars writes the values of the sensitive variables of a project to SecretsFile,
+// with a .gitignore keeping it out of the repository the project is committed to.
+type SecretVars struct {
+	TargetLocation string
+	Output         Output
+	Secrets        []Secret
+}
+
+func (s *SecretVars) Generate() error {
+	if len(s.Secrets) == 0 {
+		return nil
+	}
+	log.Println("[TRACE] <====== Secret variables generation started. =====>")
+	hclFile := hclwrite.NewEmptyFile()
+	rootBody := hclFile.Body()
+	for _, secret := range s.Secrets {
+		rootBody.SetAttributeValue(secret.Name, secret.Value)
+	}
+	if err := s.Output.WriteFile(filepath.Join(s.TargetLocation, SecretsFile), hclFile.Bytes(), 0600); err != nil {
+		return err
+	}
+	if err := s.ignoreSecretsFile(); err != nil {
+		return err
+	}
+	log.Println("[TRACE] <====== Secret variables generation done. =====>")
+	return nil
+}
+
+// ignoreSecretsFile adds SecretsFile to the .gitignore of the project, keeping the entries it already has.
+func (s *SecretVars) ignoreSecretsFile() error {
+	name := filepath.Join(s.TargetLocation, ".gitignore")
+	gitignore, _ := ReadOutputFile(s.Output, name)
+	for _, line := range strings.Split(string(gitignore), "\n") {
+		if strings.TrimSpace(line) == SecretsFile || strings.TrimSpace(line) == "/"+SecretsFile {
+			return nil
+		}
+	}
+	if len(gitignore) > 0 && !bytes.HasSuffix(gitignore, []byte("\n")) {
+		gitignore = append(gitignore, '\n')
+	}
+	return s.Output.WriteFile(name, append(gitignore, SecretsFile+"\n"...), 0644)
+}
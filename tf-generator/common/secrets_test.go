@@ -0,0 +1,26 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSecretVarsKeepsGitignore(t *testing.T) {
+	out := NewMemoryOutput()
+	out.WriteFile("app/.gitignore", []byte(".terraform"), 0644)
+	s := SecretVars{
+		TargetLocation: "app",
+		Output:         out,
+		Secrets:        []Secret{{Name: "k8s_secret_app_data", Value: cty.StringVal("value")}},
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, _ := out.File("app/.gitignore")
+	if want := ".terraform\n" + SecretsFile + "\n"; string(f.Data) != want {
+		t.Errorf(".gitignore is %q, want %q", f.Data, want)
+	}
+}
@@ -0,0 +1,183 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"tenant-terraform-generator/duplosdk"
+)
+
+// SnapshotVersion is the version of the snapshots written by this version of the generator.
+// It must be increased when a generator changes the data it stores in a way older snapshots cannot be rendered with.
+const SnapshotVersion = 1
+
+// Snapshot holds every duplo object of a tenant read by the generators, so the terraform projects can be rendered without the portal.
+type Snapshot struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	Tenant    SnapshotTenant `json:"tenant"`
+	// Generators maps the name of every registered generator to the objects it read.
+	Generators map[string]*Fetched `json:"generators"`
+}
+
+// SnapshotTenant identifies the tenant of a snapshot.
+type SnapshotTenant struct {
+	Name      string `json:"name"`
+	ID        string `json:"id"`
+	AccountID string `json:"account_id"`
+	PlanID    string `json:"plan_id,omitempty"`
+	Region    string `json:"region,omitempty"`
+}
+
+// Fetched holds the duplo objects read by one generator.
+type Fetched struct {
+	// Data is the JSON encoding of the objects, in a format private to the generator.
+	Data json.RawMessage `json:"data,omitempty"`
+	// Failures lists the objects which could not be read, or the whole generator when an entry has no object.
+	Failures []*ObjectError `json:"failures,omitempty"`
+}
+
+// Set stores the objects read by a generator, v is encoded to JSON.
+func (f *Fetched) Set(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f.Data = data
+	return nil
+}
+
+// Decode reads the objects stored with Set into v.
+func (f *Fetched) Decode(v interface{}) error {
+	if len(f.Data) == 0 {
+		return fmt.Errorf("no objects were read")
+	}
+	if err := json.Unmarshal(f.Data, v); err != nil {
+		return fmt.Errorf("error decoding the objects from the snapshot: %s", err)
+	}
+	return nil
+}
+
+// ObjectFailed records that an object could not be read, the generator goes on with the next object.
+func (f *Fetched) ObjectFailed(object string, err error) {
+	log.Printf("[TRACE] Skipping %s: %s", object, err)
+	f.Failures = append(f.Failures, &ObjectError{Object: object, Err: err})
+}
+
+// Failed reports whether the generator itself failed, it has nothing to render then.
+func (f *Fetched) Failed() bool {
+	for _, e := range f.Failures {
+		if len(e.Object) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Failures returns the objects which could not be read, ordered by generator.
+func (s *Snapshot) Failures() []*ObjectError {
+	names := make([]string, 0, len(s.Generators))
+	for name := range s.Generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	failures := []*ObjectError{}
+	for _, name := range names {
+		failures = append(failures, s.Generators[name].Failures...)
+	}
+	return failures
+}
+
+// SaveSnapshot writes a snapshot to path.
+func SaveSnapshot(path string, snapshot *Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// LoadSnapshot reads a snapshot written by SaveSnapshot.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("error parsing snapshot %s: %s", path, err)
+	}
+	if snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("snapshot %s has version %d, this generator renders version %d", path, snapshot.Version, SnapshotVersion)
+	}
+	if snapshot.Generators == nil {
+		snapshot.Generators = map[string]*Fetched{}
+	}
+	return snapshot, nil
+}
+
+// objectErrorJSON is the form of an ObjectError in a snapshot, the duplo API error is kept so the failure report is the same once loaded.
+type objectErrorJSON struct {
+	Generator string `json:"generator,omitempty"`
+	Object    string `json:"object,omitempty"`
+	Error     string `json:"error"`
+	Status    int    `json:"status,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+func (e *ObjectError) MarshalJSON() ([]byte, error) {
+	j := objectErrorJSON{Generator: e.Generator, Object: e.Object, Error: e.Err.Error()}
+	if clientErr := e.ClientError(); clientErr != nil {
+		j.Status, j.URL = clientErr.Status(), clientErr.URL()
+		j.Message, _ = clientErr.Response()["Message"].(string)
+	}
+	return json.Marshal(j)
+}
+
+func (e *ObjectError) UnmarshalJSON(data []byte) error {
+	j := objectErrorJSON{}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	e.Generator, e.Object, e.Err = j.Generator, j.Object, errors.New(j.Error)
+	if j.Status != 0 || len(j.URL) > 0 {
+		e.Err = &snapshotClientError{message: j.Error, status: j.Status, url: j.URL, response: map[string]interface{}{"Message": j.Message}}
+	}
+	return nil
+}
+
+// snapshotClientError is a duplo API error loaded from a snapshot.
+type snapshotClientError struct {
+	message  string
+	status   int
+	url      string
+	response map[string]interface{}
+}
+
+var _ duplosdk.ClientError = &snapshotClientError{}
+
+func (e *snapshotClientError) Error() string {
+	return e.message
+}
+
+func (e *snapshotClientError) Status() int {
+	return e.status
+}
+
+func (e *snapshotClientError) PossibleMissingAPI() bool {
+	return e.status == 500 || e.status == 404
+}
+
+func (e *snapshotClientError) URL() string {
+	return e.url
+}
+
+func (e *snapshotClientError) Response() map[string]interface{} {
+	return e.response
+}
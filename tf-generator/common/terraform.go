@@ -0,0 +1,278 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hc-install/product"
+	"github.com/hashicorp/hc-install/releases"
+	"github.com/hashicorp/terraform-exec/tfexec"
+)
+
+const (
+	// MinTerraformVersion is the oldest terraform version the generated projects support.
+	MinTerraformVersion = "0.14.11"
+	// ImportBlocksMinTerraformVersion is the first terraform version supporting import blocks.
+	ImportBlocksMinTerraformVersion = "1.5.0"
+
+	// DefaultTerraformVersion is downloaded when no terraform binary is found and no version constraint is given,
+	// ImportBlocksTerraformVersion replaces it when the projects need a newer version, for import blocks or a cloud block.
+	DefaultTerraformVersion      = "0.14.11"
+	ImportBlocksTerraformVersion = "1.5.7"
+)
+
+// TerraformOptions tells where the terraform binary running validate, fmt and import is found.
+// The binary is looked for in this order: Path, terraform and tofu on PATH, the versions cached in InstallDir,
+// and finally downloaded to InstallDir unless Offline is set.
+type TerraformOptions struct {
+	// Path is a terraform or tofu executable, used even when it does not match VersionConstraint.
+	Path string
+	// VersionConstraint restricts the versions which are used, e.g. "~> 1.5.0".
+	VersionConstraint string
+	// InstallDir holds the downloaded versions, one directory per version.
+	InstallDir string
+	Offline    bool
+}
+
+// Terraform is a resolved terraform or OpenTofu binary.
+type Terraform struct {
+	ExecPath string
+	Version  *version.Version
+}
+
+// Command returns the name the binary is run with, terraform or tofu.
+func (t *Terraform) Command() string {
+	if strings.HasPrefix(filepath.Base(t.ExecPath), "tofu") {
+		return "tofu"
+	}
+	return "terraform"
+}
+
+// DefaultTerraformInstallDir returns the directory caching the downloaded terraform versions.
+func DefaultTerraformInstallDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "tenant-terraform-generator", "terraform")
+}
+
+// MinTerraformVersion returns the oldest terraform version supporting the syntax generated with the config.
+func (c *Config) MinTerraformVersion() string {
+	if c.UseImportBlocks() {
+		return ImportBlocksMinTerraformVersion
+	}
+	return c.minTerraformVersion()
+}
+
+// minTerraformVersion returns the oldest terraform version the projects can be generated for, import blocks are left
+// out when the binary is too old for them.
+func (c *Config) minTerraformVersion() string {
+	if c.Backend.Type == BackendCloud {
+		return CloudMinTerraformVersion
+	}
+	return MinTerraformVersion
+}
+
+// UseImportBlocks reports whether the resources to import are written as import blocks. With a terraform binary
+// older than 1.5 only the import manifest and script are written.
+func (c *Config) UseImportBlocks() bool {
+	if !c.ImportBlocks {
+		return false
+	}
+	return c.Terraform == nil || c.Terraform.Version.GreaterThanOrEqual(version.Must(version.NewVersion(ImportBlocksMinTerraformVersion)))
+}
+
+// ResolveTerraform finds the terraform binary of the config, unless it is already known.
+// It is meant to be called before generating, the generated syntax depends on the version of the binary.
+func (c *Config) ResolveTerraform(ctx context.Context) error {
+	if c.Terraform != nil {
+		return nil
+	}
+	downloadVersion := DefaultTerraformVersion
+	if c.ImportBlocks || c.Backend.Type == BackendCloud {
+		downloadVersion = ImportBlocksTerraformVersion
+	}
+	tf, err := ResolveTerraform(ctx, c.TerraformOptions, c.minTerraformVersion(), downloadVersion)
+	if err != nil {
+		return err
+	}
+	c.Terraform = tf
+	if c.ImportBlocks && !c.UseImportBlocks() {
+		log.Printf("[TRACE] %s %s does not support import blocks, the resources to import are only listed in %s and %s.",
+			tf.Command(), tf.Version, ImportManifestFile, ImportScriptFile)
+	}
+	return nil
+}
+
+// NewTerraform returns a terraform runner for workingDir, the binary is resolved on first use.
+func (c *Config) NewTerraform(ctx context.Context, workingDir string) (*tfexec.Terraform, error) {
+	if err := c.ResolveTerraform(ctx); err != nil {
+		return nil, err
+	}
+	tf, err := tfexec.NewTerraform(workingDir, c.Terraform.ExecPath)
+	if err != nil {
+		return nil, fmt.Errorf("error running NewTerraform: %s", err)
+	}
+	return tf, nil
+}
+
+// ResolveTerraform finds a terraform binary of at least minVersion matching the constraint of opts.
+// downloadVersion is installed when none is found and opts has no version constraint.
+func ResolveTerraform(ctx context.Context, opts TerraformOptions, minVersion string, downloadVersion string) (*Terraform, error) {
+	constraints, err := version.NewConstraint(">= " + minVersion)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.VersionConstraint) > 0 {
+		userConstraints, err := version.NewConstraint(opts.VersionConstraint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid terraform version constraint %q: %s", opts.VersionConstraint, err)
+		}
+		constraints = append(constraints, userConstraints...)
+	}
+
+	if len(opts.Path) > 0 {
+		tf, err := terraformAt(ctx, opts.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !tf.Version.GreaterThanOrEqual(version.Must(version.NewVersion(minVersion))) {
+			return nil, fmt.Errorf("terraform %s at %s is too old, the generated projects need %s or newer", tf.Version, tf.ExecPath, minVersion)
+		}
+		log.Printf("[TRACE] Using %s %s at %s.", tf.Command(), tf.Version, tf.ExecPath)
+		return tf, nil
+	}
+	for _, name := range []string{"terraform", "tofu"} {
+		execPath, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		tf, err := terraformAt(ctx, execPath)
+		if err != nil {
+			log.Printf("[TRACE] Skipping %s: %s", execPath, err)
+			continue
+		}
+		if !constraints.Check(tf.Version) {
+			log.Printf("[TRACE] Skipping %s %s at %s, it does not match %s.", name, tf.Version, execPath, constraints)
+			continue
+		}
+		log.Printf("[TRACE] Using %s %s at %s.", name, tf.Version, execPath)
+		return tf, nil
+	}
+	if tf := cachedTerraform(opts.InstallDir, constraints); tf != nil {
+		log.Printf("[TRACE] Using terraform %s cached at %s.", tf.Version, tf.ExecPath)
+		return tf, nil
+	}
+	if opts.Offline {
+		return nil, fmt.Errorf("no terraform binary matching %s found on PATH or in %s, and downloading is disabled", constraints, opts.InstallDir)
+	}
+	return installTerraform(ctx, opts, constraints, downloadVersion)
+}
+
+// terraformAt returns the binary at execPath with its version.
+func terraformAt(ctx context.Context, execPath string) (*Terraform, error) {
+	tf, err := tfexec.NewTerraform(os.TempDir(), execPath)
+	if err != nil {
+		return nil, fmt.Errorf("error running NewTerraform: %s", err)
+	}
+	v, _, err := tf.Version(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the version of %s: %s", execPath, err)
+	}
+	return &Terraform{ExecPath: execPath, Version: v}, nil
+}
+
+// cachedTerraform returns the newest version installed in installDir which matches constraints.
+func cachedTerraform(installDir string, constraints version.Constraints) *Terraform {
+	if len(installDir) == 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(installDir)
+	if err != nil {
+		return nil
+	}
+	found := []*Terraform{}
+	for _, e := range entries {
+		v, err := version.NewVersion(e.Name())
+		if err != nil || !e.IsDir() || !constraints.Check(v) {
+			continue
+		}
+		execPath := filepath.Join(installDir, e.Name(), product.Terraform.BinaryName())
+		if _, err := os.Stat(execPath); err != nil {
+			continue
+		}
+		found = append(found, &Terraform{ExecPath: execPath, Version: v})
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Version.GreaterThan(found[j].Version)
+	})
+	return found[0]
+}
+
+// installTerraform downloads terraform to a directory of opts.InstallDir named after its version,
+// downloadVersion without a version constraint or the latest version matching it.
+func installTerraform(ctx context.Context, opts TerraformOptions, constraints version.Constraints, downloadVersion string) (*Terraform, error) {
+	installDir := ""
+	if len(opts.InstallDir) > 0 {
+		if err := os.MkdirAll(opts.InstallDir, 0755); err != nil {
+			return nil, fmt.Errorf("error creating terraform install directory: %s", err)
+		}
+		dir, err := os.MkdirTemp(opts.InstallDir, ".download-")
+		if err != nil {
+			return nil, fmt.Errorf("error creating terraform install directory: %s", err)
+		}
+		defer os.RemoveAll(dir)
+		installDir = dir
+	}
+	var execPath string
+	var err error
+	if len(opts.VersionConstraint) == 0 {
+		log.Printf("[TRACE] Downloading terraform %s.", downloadVersion)
+		installer := &releases.ExactVersion{
+			Product:    product.Terraform,
+			Version:    version.Must(version.NewVersion(downloadVersion)),
+			InstallDir: installDir,
+		}
+		execPath, err = installer.Install(ctx)
+	} else {
+		log.Printf("[TRACE] Downloading the latest terraform matching %s.", constraints)
+		installer := &releases.LatestVersion{
+			Product:     product.Terraform,
+			Constraints: constraints,
+			InstallDir:  installDir,
+		}
+		execPath, err = installer.Install(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error installing Terraform: %s", err)
+	}
+	tf, err := terraformAt(ctx, execPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(installDir) == 0 {
+		return tf, nil
+	}
+	// Another run may have cached the same version meanwhile, the first one is kept.
+	versionDir := filepath.Join(opts.InstallDir, tf.Version.String())
+	if err := os.Rename(installDir, versionDir); err != nil {
+		if cached := cachedTerraform(opts.InstallDir, version.MustConstraints(version.NewConstraint("= "+tf.Version.String()))); cached != nil {
+			return cached, nil
+		}
+		return nil, fmt.Errorf("error caching terraform %s: %s", tf.Version, err)
+	}
+	tf.ExecPath = filepath.Join(versionDir, filepath.Base(execPath))
+	log.Printf("[TRACE] Terraform %s is cached at %s.", tf.Version, tf.ExecPath)
+	return tf, nil
+}
@@ -0,0 +1,254 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+)
+
+// fakeTerraform writes an executable at dir/name answering terraform version -json with v.
+func fakeTerraform(t *testing.T, dir, name, v string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(dir, name)
+	script := fmt.Sprintf("#!/bin/sh\necho '{\"terraform_version\": \"%s\", \"provider_selections\": {}}'\n", v)
+	if err := os.WriteFile(p, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestResolveTerraform(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake terraform binaries are shell scripts")
+	}
+	tests := []struct {
+		name string
+		// path is the version of the binary passed as TerraformOptions.Path, empty for none.
+		path string
+		// onPath and cached map the binaries on PATH and in the install dir to their version.
+		onPath     map[string]string
+		cached     []string
+		constraint string
+		minVersion string
+		// want is the version of the resolved binary, wantCommand its command.
+		want        string
+		wantCommand string
+		// wantErr is part of the expected error, empty when a binary is found.
+		wantErr string
+	}{
+		{
+			name:        "path",
+			path:        "1.3.0",
+			onPath:      map[string]string{"terraform": "1.5.7"},
+			want:        "1.3.0",
+			wantCommand: "terraform",
+		},
+		{
+			name:        "path ignores the constraint",
+			path:        "1.3.0",
+			constraint:  "~> 1.5.0",
+			want:        "1.3.0",
+			wantCommand: "terraform",
+		},
+		{
+			name:    "path too old",
+			path:    "0.13.7",
+			wantErr: "terraform 0.13.7 at",
+		},
+		{
+			name:        "terraform on PATH",
+			onPath:      map[string]string{"terraform": "1.5.7", "tofu": "1.6.0"},
+			cached:      []string{"1.6.0"},
+			want:        "1.5.7",
+			wantCommand: "terraform",
+		},
+		{
+			name:        "tofu on PATH matching the constraint",
+			onPath:      map[string]string{"terraform": "1.5.7", "tofu": "1.6.0"},
+			constraint:  ">= 1.6.0",
+			want:        "1.6.0",
+			wantCommand: "tofu",
+		},
+		{
+			name:        "too old on PATH",
+			onPath:      map[string]string{"terraform": "0.13.7"},
+			cached:      []string{"0.14.11"},
+			want:        "0.14.11",
+			wantCommand: "terraform",
+		},
+		{
+			name:        "newest cached",
+			cached:      []string{"0.14.11", "1.5.7", "1.3.0"},
+			want:        "1.5.7",
+			wantCommand: "terraform",
+		},
+		{
+			name:        "cached matching the constraint",
+			onPath:      map[string]string{"terraform": "1.5.7"},
+			cached:      []string{"0.14.11", "1.5.7", "1.3.0"},
+			constraint:  "~> 1.3.0",
+			want:        "1.3.0",
+			wantCommand: "terraform",
+		},
+		{
+			name:       "cached older than the minimum version",
+			cached:     []string{"0.14.11"},
+			minVersion: CloudMinTerraformVersion,
+			wantErr:    "no terraform binary matching",
+		},
+		{
+			name:       "invalid constraint",
+			constraint: "~> one",
+			wantErr:    `invalid terraform version constraint "~> one"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := TerraformOptions{VersionConstraint: tt.constraint, InstallDir: filepath.Join(dir, "cache"), Offline: true}
+			if len(tt.path) > 0 {
+				opts.Path = fakeTerraform(t, filepath.Join(dir, "path"), "terraform", tt.path)
+			}
+			for name, v := range tt.onPath {
+				fakeTerraform(t, filepath.Join(dir, "bin"), name, v)
+			}
+			t.Setenv("PATH", filepath.Join(dir, "bin"))
+			for _, v := range tt.cached {
+				fakeTerraform(t, filepath.Join(dir, "cache", v), "terraform", v)
+			}
+			minVersion := tt.minVersion
+			if len(minVersion) == 0 {
+				minVersion = MinTerraformVersion
+			}
+
+			tf, err := ResolveTerraform(context.Background(), opts, minVersion, DefaultTerraformVersion)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tf.Version.String() != tt.want || tf.Command() != tt.wantCommand {
+				t.Errorf("resolved %s %s at %s, want %s %s", tf.Command(), tf.Version, tf.ExecPath, tt.wantCommand, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigTerraformVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		importBlocks bool
+		backend      string
+		// terraform is the version of the resolved binary, empty when none is resolved.
+		terraform       string
+		wantBlocks      bool
+		requiredVersion string
+	}{
+		{name: "default", requiredVersion: MinTerraformVersion},
+		{name: "cloud backend", backend: BackendCloud, terraform: "1.3.0", requiredVersion: CloudMinTerraformVersion},
+		{name: "import blocks without binary", importBlocks: true, wantBlocks: true, requiredVersion: ImportBlocksMinTerraformVersion},
+		{name: "import blocks", importBlocks: true, terraform: "1.5.0", wantBlocks: true, requiredVersion: ImportBlocksMinTerraformVersion},
+		{name: "import blocks with terraform 1.4", importBlocks: true, terraform: "1.4.6", requiredVersion: MinTerraformVersion},
+		{name: "import blocks with terraform 1.4 and cloud backend", importBlocks: true, backend: BackendCloud, terraform: "1.4.6",
+			requiredVersion: CloudMinTerraformVersion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{ImportBlocks: tt.importBlocks, Backend: Backend{Type: tt.backend}}
+			if len(tt.terraform) > 0 {
+				config.Terraform = &Terraform{ExecPath: "terraform", Version: version.Must(version.NewVersion(tt.terraform))}
+			}
+			if got := config.UseImportBlocks(); got != tt.wantBlocks {
+				t.Errorf("import blocks are used: %t, want %t", got, tt.wantBlocks)
+			}
+			if got := config.MinTerraformVersion(); got != tt.requiredVersion {
+				t.Errorf("required version is %s, want %s", got, tt.requiredVersion)
+			}
+		})
+	}
+}
+
+// TestConfigResolveTerraformWithoutImportBlocks checks a terraform older than 1.5 is used with --import-blocks,
+// instead of failing the run.
+func TestConfigResolveTerraformWithoutImportBlocks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake terraform binaries are shell scripts")
+	}
+	dir := t.TempDir()
+	fakeTerraform(t, dir, "terraform", "1.3.0")
+	t.Setenv("PATH", dir)
+	config := &Config{ImportBlocks: true, TerraformOptions: TerraformOptions{Offline: true}}
+	if err := config.ResolveTerraform(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if config.Terraform.Version.String() != "1.3.0" {
+		t.Errorf("resolved terraform %s, want 1.3.0", config.Terraform.Version)
+	}
+	if config.UseImportBlocks() {
+		t.Errorf("import blocks are used with terraform 1.3.0")
+	}
+}
+
+// TestInitBackendConfig checks the backend settings terraform init is given, with a fake terraform recording its arguments.
+func TestInitBackendConfig(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake terraform binaries are shell scripts")
+	}
+	tests := []struct {
+		name    string
+		backend Backend
+		want    []string
+	}{
+		{name: "default s3", backend: Backend{Type: BackendS3},
+			want: []string{"-backend-config=bucket=duplo-tfstate-100000000000", "-backend-config=dynamodb_table=duplo-tfstate-100000000000-lock"}},
+		{name: "s3 with a lock table", backend: Backend{Type: BackendS3, LockTable: "locks"},
+			want: []string{"-backend-config=bucket=duplo-tfstate-100000000000"}},
+		{name: "s3 with a bucket", backend: Backend{Type: BackendS3, Bucket: "states", LockTable: "locks"}},
+		{name: "local", backend: Backend{Type: BackendLocal}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			p := fakeTerraform(t, dir, "terraform", "1.5.7")
+			argsFile := filepath.Join(dir, "args")
+			script, err := os.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			script = []byte(strings.Replace(string(script), "\n", "\nfor a in \"$@\"; do echo \"$a\" >> "+argsFile+"; done\n", 1))
+			if err := os.WriteFile(p, script, 0755); err != nil {
+				t.Fatal(err)
+			}
+			config := &Config{AccountID: "100000000000", Backend: tt.backend, TerraformOptions: TerraformOptions{Path: p, Offline: true}}
+			if _, err := (&TfInitializer{Config: config}).Init(context.Background(), config, dir); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(argsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, arg := range strings.Split(string(data), "\n") {
+				if strings.HasPrefix(arg, "-backend-config=") {
+					got = append(got, arg)
+				}
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("terraform init got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
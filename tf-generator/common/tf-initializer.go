@@ -2,138 +2,129 @@ package common
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"tenant-terraform-generator/duplosdk"
 
-	"github.com/hashicorp/go-version"
-	"github.com/hashicorp/hc-install/product"
-	"github.com/hashicorp/hc-install/releases"
 	"github.com/hashicorp/terraform-exec/tfexec"
 )
 
 type TfInitializer struct {
 	WorkingDir string
 	Config     *Config
+	// Workspace is selected by InitWithWorkspace, the tenant when empty.
+	Workspace string
 }
 
-func (tfi *TfInitializer) InitWithWorkspace() *tfexec.Terraform {
-	log.Println("[TRACE] <================================== TF init in progress. ==================================>")
-	installer := &releases.ExactVersion{
-		Product: product.Terraform,
-		Version: version.Must(version.NewVersion("0.14.11")),
+// initOptions returns the options of terraform init for the backend of config.
+func initOptions(config *Config) []tfexec.InitOption {
+	opts := []tfexec.InitOption{tfexec.Upgrade(true)}
+	for _, bc := range config.Backend.InitBackendConfig(config.AccountID) {
+		opts = append(opts, tfexec.BackendConfig(bc))
 	}
+	return opts
+}
 
-	execPath, err := installer.Install(context.Background())
-	if err != nil {
-		log.Fatalf("error installing Terraform: %s", err)
-	}
-	tf, err := tfexec.NewTerraform(tfi.WorkingDir, execPath)
+func (tfi *TfInitializer) InitWithWorkspace(ctx context.Context) (*tfexec.Terraform, error) {
+	log.Println("[TRACE] <================================== TF init in progress. ==================================>")
+	tf, err := tfi.Config.NewTerraform(ctx, tfi.WorkingDir)
 	if err != nil {
-		log.Fatalf("error running NewTerraform: %s", err)
+		return nil, err
 	}
 	//backend := "-backend-config=bucket=duplo-tfstate-" + config.AccountID + " -backend-config=dynamodb_table=duplo-tfstate-" + config.AccountID + "-lock"
-	//err = tf.Init(context.Background(), tfexec.Upgrade(true), tfexec.BackendConfig("bucket=duplo-tfstate-"+config.AccountID), tfexec.BackendConfig("dynamodb_table=duplo-tfstate-"+config.AccountID+"-lock"))
-	if tfi.Config.S3Backend {
-		err = tf.Init(context.Background(), tfexec.Upgrade(true), tfexec.BackendConfig("bucket=duplo-tfstate-"+tfi.Config.AccountID))
-	} else {
-		err = tf.Init(context.Background(), tfexec.Upgrade(true))
-	}
+	//err = tf.Init(ctx, tfexec.Upgrade(true), tfexec.BackendConfig("bucket=duplo-tfstate-"+config.AccountID), tfexec.BackendConfig("dynamodb_table=duplo-tfstate-"+config.AccountID+"-lock"))
+	err = tf.Init(ctx, initOptions(tfi.Config)...)
 
 	if err != nil {
-		log.Fatalf("error running Init: %s", err)
+		return nil, fmt.Errorf("error running Init: %s", err)
+	}
+	if !tfi.Config.Backend.Workspaces() {
+		log.Printf("[TRACE] The %s backend keeps a state per tenant, no workspace is selected.", tfi.Config.Backend.Type)
+		return tf, nil
 	}
 
-	workspaceList, activeWorkspace, err := tf.WorkspaceList(context.Background())
+	workspace := tfi.Workspace
+	if len(workspace) == 0 {
+		workspace = tfi.Config.TenantName
+	}
+	workspaceList, activeWorkspace, err := tf.WorkspaceList(ctx)
 	if err != nil {
-		log.Fatalf("error running tf workspace list: %s", err)
+		return nil, fmt.Errorf("error running tf workspace list: %s", err)
 	}
 	if len(workspaceList) > 0 {
 		log.Printf("[TRACE] Workspace List (%s).", workspaceList)
 		log.Printf("[TRACE] Active Workspace (%s).", activeWorkspace)
 	}
 
-	if duplosdk.Contains(workspaceList, tfi.Config.TenantName) {
-		err = tf.WorkspaceSelect(context.Background(), tfi.Config.TenantName)
+	if duplosdk.Contains(workspaceList, workspace) {
+		err = tf.WorkspaceSelect(ctx, workspace)
 		if err != nil {
-			log.Fatalf("error running tf workspace select: %s", err)
+			return nil, fmt.Errorf("error running tf workspace select: %s", err)
 		}
-		log.Printf("[TRACE] (%s) workspace is selected.", tfi.Config.TenantName)
+		log.Printf("[TRACE] (%s) workspace is selected.", workspace)
 	} else {
-		err := tf.WorkspaceNew(context.Background(), tfi.Config.TenantName)
+		err := tf.WorkspaceNew(ctx, workspace)
 		if err != nil {
-			log.Fatalf("error running tf workspace new: %s", err)
+			return nil, fmt.Errorf("error running tf workspace new: %s", err)
 		}
-		log.Printf("[TRACE] (%s) workspace is created.", tfi.Config.TenantName)
+		log.Printf("[TRACE] (%s) workspace is created.", workspace)
 	}
-	log.Printf("[TRACE] Terraform initialized with new workspace - %s", tfi.Config.TenantName)
+	log.Printf("[TRACE] Terraform initialized with new workspace - %s", workspace)
 	log.Println("[TRACE] <====================================================================>")
-	return tf
+	return tf, nil
 }
 
-func (tfi *TfInitializer) Init(config *Config, workingDir string) *tfexec.Terraform {
-	installer := &releases.ExactVersion{
-		Product: product.Terraform,
-		Version: version.Must(version.NewVersion("0.14.11")),
-	}
-
-	execPath, err := installer.Install(context.Background())
-	if err != nil {
-		log.Fatalf("error installing Terraform: %s", err)
-	}
-	tf, err := tfexec.NewTerraform(workingDir, execPath)
+func (tfi *TfInitializer) Init(ctx context.Context, config *Config, workingDir string) (*tfexec.Terraform, error) {
+	tf, err := config.NewTerraform(ctx, workingDir)
 	if err != nil {
-		log.Fatalf("error running NewTerraform: %s", err)
-	}
-	if config.S3Backend {
-		err = tf.Init(context.Background(), tfexec.Upgrade(true), tfexec.BackendConfig("bucket=duplo-tfstate-"+config.AccountID), tfexec.BackendConfig("dynamodb_table=duplo-tfstate-"+config.AccountID+"-lock"))
-	} else {
-		err = tf.Init(context.Background(), tfexec.Upgrade(true))
+		return nil, err
 	}
+	err = tf.Init(ctx, initOptions(config)...)
 
 	if err != nil {
-		log.Fatalf("error running Init: %s", err)
+		return nil, fmt.Errorf("error running Init: %s", err)
 	}
-	return tf
+	return tf, nil
 }
 
-func (tfi *TfInitializer) NewWorkspace(config *Config, tf *tfexec.Terraform) {
-	workspaceList, activeWorkspace, err := tf.WorkspaceList(context.Background())
+func (tfi *TfInitializer) NewWorkspace(ctx context.Context, config *Config, tf *tfexec.Terraform) error {
+	workspaceList, activeWorkspace, err := tf.WorkspaceList(ctx)
 	if err != nil {
-		log.Fatalf("error running tf workspace list: %s", err)
+		return fmt.Errorf("error running tf workspace list: %s", err)
 	}
 	if len(workspaceList) > 0 {
 		log.Printf("[TRACE] Workspace List (%s).", workspaceList)
 		log.Printf("[TRACE] Active Workspace (%s).", activeWorkspace)
 	}
 	if !duplosdk.Contains(workspaceList, tfi.Config.TenantName) {
-		err := tf.WorkspaceNew(context.Background(), config.TenantName)
+		err := tf.WorkspaceNew(ctx, config.TenantName)
 		if err != nil {
-			log.Fatalf("error running tf workspace new: %s", err)
+			return fmt.Errorf("error running tf workspace new: %s", err)
 		}
 		log.Printf("[TRACE] (%s) workspace is created.", config.TenantName)
 	}
-
+	return nil
 }
 
-func (tfi *TfInitializer) DeleteWorkspace(config *Config, tf *tfexec.Terraform) {
-	workspaceList, activeWorkspace, err := tf.WorkspaceList(context.Background())
+func (tfi *TfInitializer) DeleteWorkspace(ctx context.Context, config *Config, tf *tfexec.Terraform) error {
+	workspaceList, activeWorkspace, err := tf.WorkspaceList(ctx)
 	if err != nil {
-		log.Fatalf("error running tf workspace list: %s", err)
+		return fmt.Errorf("error running tf workspace list: %s", err)
 	}
 	if len(workspaceList) > 0 {
 		log.Printf("[TRACE] Workspace List (%s).", workspaceList)
 		log.Printf("[TRACE] Active Workspace (%s).", activeWorkspace)
 	}
 	if duplosdk.Contains(workspaceList, tfi.Config.TenantName) {
-		err := tf.WorkspaceSelect(context.Background(), "default")
+		err := tf.WorkspaceSelect(ctx, "default")
 		if err != nil {
-			log.Fatalf("error running tf workspace select(default): %s", err)
+			return fmt.Errorf("error running tf workspace select(default): %s", err)
 		}
-		err = tf.WorkspaceDelete(context.Background(), config.TenantName)
+		err = tf.WorkspaceDelete(ctx, config.TenantName)
 		if err != nil {
-			log.Fatalf("error running tf workspace delete: %s", err)
+			return fmt.Errorf("error running tf workspace delete: %s", err)
 		}
 		log.Printf("[TRACE] Workspace deleted (%s).", config.TenantName)
 	}
-
+	return nil
 }
@@ -0,0 +1,352 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Validation modes of the generated projects.
+const (
+	// ValidateTerraform runs terraform validate and fmt on the projects written to a directory, after the static checks.
+	ValidateTerraform = "terraform"
+	// ValidateStatic only runs the static checks of ValidateTerraformFiles, terraform is not needed.
+	ValidateStatic = "static"
+)
+
+// Traversal roots which are not references to a resource.
+var nonResourceRoots = map[string]bool{
+	"count":     true,
+	"each":      true,
+	"path":      true,
+	"self":      true,
+	"terraform": true,
+}
+
+// ProjectFiles returns the terraform files written to the directory dir of out, by file name.
+// The names are local paths when the output is a directory, and names of the output otherwise.
+func ProjectFiles(out Output, dir string) (map[string][]byte, error) {
+	if f, ok := out.(formattedOutput); ok {
+		out = f.Output
+	}
+	files := map[string][]byte{}
+	switch o := out.(type) {
+	case LocalOutput:
+		entries, err := os.ReadDir(o.Path(dir))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".tf") {
+				continue
+			}
+			p := filepath.Join(o.Path(dir), e.Name())
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			files[p] = data
+		}
+	case interface {
+		Names() []string
+		File(name string) (MemoryFile, bool)
+	}:
+		for _, name := range o.Names() {
+			if path.Dir(name) == dir && strings.HasSuffix(name, ".tf") {
+				f, _ := o.File(name)
+				files[name] = f.Data
+			}
+		}
+	default:
+		return nil, fmt.Errorf("the files written to %T cannot be read back", out)
+	}
+	return files, nil
+}
+
+// ValidateTerraformFiles checks the files of a terraform project without terraform, the providers are not needed.
+// Besides the syntax, it checks that every variable, local, resource, data source and module which is referenced
+// is declared once, and that the bodies of jsonencode calls are valid.
+func ValidateTerraformFiles(files map[string][]byte) hcl.Diagnostics {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parser := hclparse.NewParser()
+	diags := hcl.Diagnostics{}
+	bodies := []*hclsyntax.Body{}
+	for _, name := range names {
+		f, parseDiags := parser.ParseHCL(files[name], name)
+		diags = append(diags, parseDiags...)
+		if f == nil || parseDiags.HasErrors() {
+			continue
+		}
+		bodies = append(bodies, f.Body.(*hclsyntax.Body))
+	}
+
+	declared := map[string]hcl.Range{}
+	declare := func(kind, address string, rng hcl.Range) {
+		if prev, ok := declared[address]; ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("Duplicate %s %s", kind, address),
+				Detail:   fmt.Sprintf("It is already declared at %s.", rangeString(prev)),
+				Subject:  rng.Ptr(),
+			})
+			return
+		}
+		declared[address] = rng
+	}
+	for _, body := range bodies {
+		for _, block := range body.Blocks {
+			rng := block.DefRange()
+			switch block.Type {
+			case "variable", "output", "module":
+				if len(block.Labels) != 1 {
+					diags = append(diags, labelsDiagnostic(block, 1))
+					continue
+				}
+				kind := block.Type
+				if kind == "variable" {
+					kind = "var"
+				}
+				declare(block.Type, kind+"."+block.Labels[0], rng)
+			case "resource", "data":
+				if len(block.Labels) != 2 {
+					diags = append(diags, labelsDiagnostic(block, 2))
+					continue
+				}
+				address := block.Labels[0] + "." + block.Labels[1]
+				if block.Type == "data" {
+					address = "data." + address
+				}
+				declare(block.Type, address, rng)
+			case "locals":
+				for _, attr := range block.Body.Attributes {
+					declare("local", "local."+attr.Name, attr.NameRange)
+				}
+			}
+		}
+	}
+
+	for _, body := range bodies {
+		for _, block := range body.Blocks {
+			switch block.Type {
+			case "terraform", "moved":
+				continue
+			}
+			diags = append(diags, checkBlockReferences(block, declared, map[string]bool{})...)
+		}
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Subject, diags[j].Subject
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Line < b.Start.Line
+	})
+	return diags
+}
+
+// ValidationProblem is an error found by ValidateTerraformFiles.
+type ValidationProblem struct {
+	// Location is the file and line of the problem, e.g. main.tf:12.
+	Location string
+	Message  string
+}
+
+// ValidationError lists the problems of a project, it is printed with one problem per line.
+type ValidationError struct {
+	Problems []ValidationProblem
+}
+
+// NewValidationError returns the errors of diags as a ValidationError, or nil when there are none.
+func NewValidationError(diags hcl.Diagnostics) error {
+	problems := []ValidationProblem{}
+	for _, d := range diags {
+		if d.Severity != hcl.DiagError {
+			continue
+		}
+		p := ValidationProblem{Message: d.Summary}
+		if len(d.Detail) > 0 {
+			p.Message += ": " + d.Detail
+		}
+		if d.Subject != nil {
+			p.Location = rangeString(*d.Subject)
+		}
+		problems = append(problems, p)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = p.Message
+		if len(p.Location) > 0 {
+			lines[i] = p.Location + ": " + p.Message
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func rangeString(rng hcl.Range) string {
+	return fmt.Sprintf("%s:%d", rng.Filename, rng.Start.Line)
+}
+
+func labelsDiagnostic(block *hclsyntax.Block, want int) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  fmt.Sprintf("Invalid %s block", block.Type),
+		Detail:   fmt.Sprintf("A %s block needs %d labels, got %d.", block.Type, want, len(block.Labels)),
+		Subject:  block.DefRange().Ptr(),
+	}
+}
+
+// checkBlockReferences checks the expressions of a block and of its nested blocks.
+// iterators are the names of the enclosing dynamic blocks, which are valid traversal roots in their content.
+func checkBlockReferences(block *hclsyntax.Block, declared map[string]hcl.Range, iterators map[string]bool) hcl.Diagnostics {
+	diags := hcl.Diagnostics{}
+	attrNames := make([]string, 0, len(block.Body.Attributes))
+	for name := range block.Body.Attributes {
+		attrNames = append(attrNames, name)
+	}
+	sort.Strings(attrNames)
+	for _, name := range attrNames {
+		attr := block.Body.Attributes[name]
+		switch {
+		case name == "provider" || name == "providers":
+			// Provider configurations are not values.
+			continue
+		case block.Type == "variable" && name == "type":
+			continue
+		case block.Type == "import" && name == "to":
+			traversal, toDiags := hcl.AbsTraversalForExpr(attr.Expr)
+			diags = append(diags, toDiags...)
+			if !toDiags.HasErrors() {
+				diags = append(diags, checkTraversal(traversal, declared, iterators)...)
+			}
+			continue
+		}
+		for _, traversal := range hclsyntax.Variables(attr.Expr) {
+			diags = append(diags, checkTraversal(traversal, declared, iterators)...)
+		}
+		diags = append(diags, checkJSONEncode(attr.Expr)...)
+	}
+	for _, nested := range block.Body.Blocks {
+		if nested.Type == "lifecycle" {
+			continue
+		}
+		nestedIterators := iterators
+		if nested.Type == "dynamic" && len(nested.Labels) == 1 {
+			nestedIterators = map[string]bool{nested.Labels[0]: true}
+			if attr, ok := nested.Body.Attributes["iterator"]; ok {
+				nestedIterators = map[string]bool{hcl.ExprAsKeyword(attr.Expr): true}
+			}
+			for k := range iterators {
+				nestedIterators[k] = true
+			}
+		}
+		diags = append(diags, checkBlockReferences(nested, declared, nestedIterators)...)
+	}
+	return diags
+}
+
+// checkTraversal checks that the object a traversal refers to is declared.
+func checkTraversal(traversal hcl.Traversal, declared map[string]hcl.Range, iterators map[string]bool) hcl.Diagnostics {
+	root := traversal.RootName()
+	if nonResourceRoots[root] || iterators[root] {
+		return nil
+	}
+	steps := 2
+	kind := "resource"
+	switch root {
+	case "var":
+		kind = "variable"
+	case "local":
+		kind = "local value"
+	case "module":
+		kind = "module"
+	case "data":
+		kind = "data source"
+		steps = 3
+	}
+	names := []string{root}
+	for _, step := range traversal[1:] {
+		if len(names) == steps {
+			break
+		}
+		attr, ok := step.(hcl.TraverseAttr)
+		if !ok {
+			break
+		}
+		names = append(names, attr.Name)
+	}
+	address := strings.Join(names, ".")
+	if len(names) < steps {
+		return hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid reference",
+			Detail:   fmt.Sprintf("%q is not a reference to a %s.", address, kind),
+			Subject:  traversal.SourceRange().Ptr(),
+		}}
+	}
+	if _, ok := declared[address]; ok {
+		return nil
+	}
+	return hcl.Diagnostics{{
+		Severity: hcl.DiagError,
+		Summary:  fmt.Sprintf("Reference to undeclared %s", kind),
+		Detail:   fmt.Sprintf("%s is not declared in this project.", address),
+		Subject:  traversal.SourceRange().Ptr(),
+	}}
+}
+
+// checkJSONEncode checks the jsonencode calls of an expression: they take one argument,
+// which is evaluated when it has no references, to catch invalid keys, values and templates.
+func checkJSONEncode(expr hclsyntax.Expression) hcl.Diagnostics {
+	return hclsyntax.VisitAll(expr, func(node hclsyntax.Node) hcl.Diagnostics {
+		call, ok := node.(*hclsyntax.FunctionCallExpr)
+		if !ok || call.Name != "jsonencode" {
+			return nil
+		}
+		if len(call.Args) != 1 || call.ExpandFinal {
+			return hcl.Diagnostics{{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid jsonencode call",
+				Detail:   fmt.Sprintf("jsonencode takes exactly one argument, got %d.", len(call.Args)),
+				Subject:  call.Range().Ptr(),
+			}}
+		}
+		if len(hclsyntax.Variables(call.Args[0])) > 0 || hasFunctionCall(call.Args[0]) {
+			return nil
+		}
+		_, diags := call.Args[0].Value(nil)
+		return diags
+	})
+}
+
+func hasFunctionCall(expr hclsyntax.Expression) bool {
+	found := false
+	hclsyntax.VisitAll(expr, func(node hclsyntax.Node) hcl.Diagnostics {
+		if _, ok := node.(*hclsyntax.FunctionCallExpr); ok {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
@@ -0,0 +1,109 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTerraformFiles(t *testing.T) {
+	base := `variable "region" {
+  type = string
+}
+locals {
+  tenant_id = "t1"
+}
+resource "duplocloud_tenant" "tenant" {
+  account_name = var.region
+}
+`
+	tests := []struct {
+		name string
+		main string
+		// want lists the expected problems, empty when the project is valid.
+		want []string
+	}{
+		{
+			name: "valid references",
+			main: `resource "duplocloud_k8_secret" "app" {
+  tenant_id   = duplocloud_tenant.tenant.tenant_id
+  secret_data = jsonencode({ "KEY" : "${local.tenant_id}-value" })
+  dynamic "setting" {
+    for_each = var.region == "" ? [] : [1]
+    content {
+      value = setting.value
+    }
+  }
+  lifecycle {
+    ignore_changes = [secret_data]
+  }
+}
+`,
+		},
+		{
+			name: "undeclared references",
+			main: `resource "duplocloud_k8_secret" "app" {
+  tenant_id = duplocloud_tenant.other.tenant_id
+  name      = var.name
+  data      = local.data
+}
+`,
+			want: []string{
+				"main.tf:2: Reference to undeclared resource: duplocloud_tenant.other",
+				"main.tf:3: Reference to undeclared variable: var.name",
+				"main.tf:4: Reference to undeclared local value: local.data",
+			},
+		},
+		{
+			name: "duplicate blocks",
+			main: `variable "region" {}
+resource "duplocloud_tenant" "tenant" {}
+`,
+			want: []string{
+				"main.tf:1: Duplicate variable var.region: It is already declared at base.tf:1.",
+				"main.tf:2: Duplicate resource duplocloud_tenant.tenant: It is already declared at base.tf:7.",
+			},
+		},
+		{
+			name: "invalid jsonencode body",
+			main: `resource "duplocloud_k8_secret" "app" {
+  secret_data = jsonencode({ "KEY" : "value" }, 1)
+  config      = jsonencode({ "KEY" : "${secret}" })
+}
+`,
+			want: []string{
+				"main.tf:2: Invalid jsonencode call",
+				"main.tf:3: Invalid reference",
+			},
+		},
+		{
+			name: "syntax error",
+			main: "resource \"duplocloud_tenant\" \"other\" {\n  account_name = \n}\n",
+			want: []string{"main.tf:2: "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string][]byte{"base.tf": []byte(base), "main.tf": []byte(tt.main)}
+			err := NewValidationError(ValidateTerraformFiles(files))
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected problems:\n%s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("no problem found, want %q", tt.want)
+			}
+			problems := err.(*ValidationError).Problems
+			if len(problems) != len(tt.want) {
+				t.Fatalf("got %d problems, want %d:\n%s", len(problems), len(tt.want), err)
+			}
+			for i, want := range tt.want {
+				got := problems[i].Location + ": " + problems[i].Message
+				if !strings.HasPrefix(got, want) {
+					t.Errorf("problem %d is %q, want it to start with %q", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -1,29 +1,41 @@
 package common
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
-	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 )
 
 type VarConfig struct {
 	Name       string
 	TypeVal    string
 	DefaultVal string
-	DescVal    string
+	// Value is the default of the variables of a collection type, written instead of DefaultVal.
+	Value   *cty.Value
+	DescVal string
+	// Sensitive hides the value of the variable in the plans, its value is in SecretsFile.
+	Sensitive bool
 }
 
 type Vars struct {
 	TargetLocation string
+	Output         Output
 	Vars           []VarConfig
+	// NoDefaults declares the variables without their default, the values are in a TfVarsFile.
+	NoDefaults bool
 }
 
-func (v *Vars) Generate() {
+func (v *Vars) Generate() error {
 
 	if len(v.Vars) > 0 {
 		log.Println("[TRACE] <====== Variables TF generation started. =====>")
@@ -32,11 +44,6 @@ func (v *Vars) Generate() {
 		hclFile := hclwrite.NewEmptyFile()
 		// create new file on system
 		path := filepath.Join(v.TargetLocation, "vars.tf")
-		tfFile, err := os.Create(path)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
 
 		// initialize the body of the new file object
 		rootBody := hclFile.Body()
@@ -45,7 +52,9 @@ func (v *Vars) Generate() {
 				varblock := rootBody.AppendNewBlock("variable",
 					[]string{varConfig.Name})
 				varBody := varblock.Body()
-				if len(varConfig.DefaultVal) > 0 {
+				if varConfig.Value != nil && !v.NoDefaults {
+					varBody.SetAttributeValue("default", *varConfig.Value)
+				} else if len(varConfig.DefaultVal) > 0 && !v.NoDefaults {
 					if varConfig.DefaultVal == "null" {
 						varBody.SetAttributeValue("default",
 							cty.NullVal(cty.String))
@@ -68,21 +77,123 @@ func (v *Vars) Generate() {
 						cty.StringVal(varConfig.DescVal))
 				}
 
-				varBody.SetAttributeTraversal("type", hcl.Traversal{
-					hcl.TraverseRoot{
-						Name: varConfig.TypeVal,
-					},
-				})
+				if tokens := typeConstructorTokens(varConfig.TypeVal); tokens != nil {
+					varBody.SetAttributeRaw("type", tokens)
+				} else {
+					varBody.SetAttributeTraversal("type", hcl.Traversal{
+						hcl.TraverseRoot{
+							Name: varConfig.TypeVal,
+						},
+					})
+				}
+				if varConfig.Sensitive {
+					varBody.SetAttributeValue("sensitive", cty.True)
+				}
 			}
 
 		}
 
-		fmt.Printf("%s", hclFile.Bytes())
-		_, err = tfFile.Write(hclFile.Bytes())
-		if err != nil {
-			fmt.Println(err)
-			return
+		if err := v.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
+			return err
 		}
 		log.Println("[TRACE] <====== Variables TF generation done. =====>")
 	}
+	return nil
+}
+
+// TfVarsPath returns the tfvars.json file holding the values of the variables of a project, given by its directory
+// name, in the workspace of the project. The scripts give it to terraform with -var-file.
+func (c *Config) TfVarsPath(project string) string {
+	return path.Join(path.Dir(c.TFCodePath), "config", c.ProjectState(project).Workspace, project+".tfvars.json")
+}
+
+// TfVarsFile writes the values of the variables of a project, the defaults of Vars, to a tfvars.json file.
+type TfVarsFile struct {
+	Path   string
+	Output Output
+	Vars   []VarConfig
+}
+
+func (t *TfVarsFile) Generate() error {
+	values := map[string]interface{}{}
+	for _, varConfig := range t.Vars {
+		if len(varConfig.Name) == 0 {
+			continue
+		}
+		if varConfig.Value != nil {
+			data, err := ctyjson.Marshal(*varConfig.Value, varConfig.Value.Type())
+			if err != nil {
+				return fmt.Errorf("error encoding the value of variable %s: %s", varConfig.Name, err)
+			}
+			values[varConfig.Name] = json.RawMessage(data)
+		} else if len(varConfig.DefaultVal) > 0 {
+			values[varConfig.Name] = tfVarValue(varConfig)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	log.Println("[TRACE] <====== Variable values generation started. =====>")
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := t.Output.WriteFile(t.Path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	log.Println("[TRACE] <====== Variable values generation done. =====>")
+	return nil
+}
+
+// tfVarValue returns the JSON value of the default of a variable, written like Vars does.
+func tfVarValue(varConfig VarConfig) interface{} {
+	if varConfig.DefaultVal == "null" {
+		return nil
+	}
+	switch varConfig.TypeVal {
+	case "number":
+		if _, err := strconv.ParseFloat(varConfig.DefaultVal, 64); err == nil {
+			return json.Number(varConfig.DefaultVal)
+		}
+	case "bool":
+		if b, err := strconv.ParseBool(varConfig.DefaultVal); err == nil {
+			return b
+		}
+	}
+	return varConfig.DefaultVal
+}
+
+// TypeString returns the type constraint of the variables holding values of type t, e.g. map(object({...})).
+func TypeString(t cty.Type) string {
+	switch {
+	case t.IsListType():
+		return "list(" + TypeString(t.ElementType()) + ")"
+	case t.IsMapType():
+		return "map(" + TypeString(t.ElementType()) + ")"
+	case t.IsObjectType():
+		names := make([]string, 0, len(t.AttributeTypes()))
+		for name := range t.AttributeTypes() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		s := "object({\n"
+		for _, name := range names {
+			s += name + " = " + TypeString(t.AttributeType(name)) + "\n"
+		}
+		return s + "})"
+	}
+	return t.FriendlyNameForConstraint()
+}
+
+// typeConstructorTokens returns the tokens of a type constraint calling a type constructor, like list(string),
+// nil for the primitive types.
+func typeConstructorTokens(typeVal string) hclwrite.Tokens {
+	if !strings.Contains(typeVal, "(") {
+		return nil
+	}
+	f, diags := hclwrite.ParseConfig([]byte("type = "+typeVal+"\n"), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil
+	}
+	return f.Body().GetAttribute("type").Expr().BuildTokens(nil)
 }
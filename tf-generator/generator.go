@@ -1,6 +1,7 @@
 package tfgenerator
 
 import (
+	"context"
 	"tenant-terraform-generator/duplosdk"
 
 	"github.com/hashicorp/hcl/v2/hclwrite"
@@ -10,8 +11,13 @@ import (
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 )
 
+// Generator writes the terraform code of one kind of duplo resource, in two phases so the duplo objects can be saved in a snapshot.
 type Generator interface {
-	Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error)
+	// Fetch reads the duplo objects of the generator and stores them in fetched, without rendering anything.
+	// It must stop early and return an error once ctx is done, the client it receives is bound to ctx.
+	Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error
+	// Render writes the terraform code of the objects stored by Fetch, possibly read back from a snapshot file.
+	Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error)
 }
 
 type ObjectAttrTokens struct {
@@ -0,0 +1,32 @@
+package infra
+
+import (
+	"context"
+	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
+	"tenant-terraform-generator/tf-generator/common"
+)
+
+type InfraBackend struct {
+}
+
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:    "infra-backend",
+		Project: tfgenerator.ProjectInfra,
+		Enabled: func(config *common.Config) bool { return config.GenerateInfra && tfgenerator.BackendEnabled(config) },
+		New:     func() tfgenerator.Generator { return &InfraBackend{} },
+	})
+}
+
+// Fetch does nothing, the backend only depends on the config.
+func (ib *InfraBackend) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
+	return nil
+}
+
+func (ib *InfraBackend) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	if err := tfgenerator.RenderBackend(config, config.InfraProject); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
@@ -0,0 +1,258 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
+	"tenant-terraform-generator/tf-generator/common"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const SUBNET_VAR_PREFIX = "subnet_"
+
+type Infra struct {
+}
+
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "infra",
+		Project:       tfgenerator.ProjectInfra,
+		ResourceTypes: []string{"duplocloud_infrastructure", "duplocloud_infrastructure_subnet", "duplocloud_infrastructure_setting"},
+		DuploAPIs:     []string{"InfrastructureGet", "InfrastructureGetConfig"},
+		Enabled:       func(config *common.Config) bool { return config.GenerateInfra },
+		New:           func() tfgenerator.Generator { return &Infra{} },
+	})
+}
+
+// infraSnapshot holds the duplo objects rendered by the infra generator.
+type infraSnapshot struct {
+	Infra  *duplosdk.DuploInfrastructure
+	Config *duplosdk.DuploInfrastructureConfig
+}
+
+func (i *Infra) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
+	if len(config.PlanID) == 0 {
+		return fmt.Errorf("tenant %s has no infrastructure", config.TenantName)
+	}
+	infra, clientErr := client.InfrastructureGet(config.PlanID)
+	if clientErr != nil {
+		return clientErr
+	}
+	if infra == nil {
+		return fmt.Errorf("infrastructure %s not found", config.PlanID)
+	}
+	infraConfig, clientErr := client.InfrastructureGetConfig(config.PlanID)
+	if clientErr != nil {
+		return clientErr
+	}
+	return fetched.Set(infraSnapshot{Infra: infra, Config: infraConfig})
+}
+
+func (i *Infra) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	log.Println("[TRACE] <====== Infrastructure TF generation started. =====>")
+	workingDir := filepath.Join(config.TFCodePath, config.InfraProject)
+	data := infraSnapshot{}
+	if err := fetched.Decode(&data); err != nil {
+		return nil, err
+	}
+	infra := data.Infra
+	tfContext := common.TFContext{}
+	tfContext.InputVars = generateInfraVars(infra)
+
+	hclFile := hclwrite.NewEmptyFile()
+	path := filepath.Join(workingDir, "main.tf")
+	rootBody := hclFile.Body()
+
+	// Add duplocloud_infrastructure resource
+	infraBody := rootBody.AppendNewBlock("resource",
+		[]string{"duplocloud_infrastructure",
+			"infra"}).Body()
+	infraBody.SetAttributeTraversal("infra_name", hcl.Traversal{
+		hcl.TraverseRoot{
+			Name: "var",
+		},
+		hcl.TraverseAttr{
+			Name: "infra_name",
+		},
+	})
+	infraBody.SetAttributeValue("cloud",
+		cty.NumberIntVal(int64(infra.Cloud)))
+	infraBody.SetAttributeTraversal("region", hcl.Traversal{
+		hcl.TraverseRoot{
+			Name: "var",
+		},
+		hcl.TraverseAttr{
+			Name: "region",
+		},
+	})
+	infraBody.SetAttributeValue("azcount",
+		cty.NumberIntVal(int64(infra.AzCount)))
+	infraBody.SetAttributeValue("enable_k8_cluster",
+		cty.BoolVal(infra.EnableK8Cluster))
+	infraBody.SetAttributeValue("enable_ecs_cluster",
+		cty.BoolVal(infra.EnableECSCluster))
+	infraBody.SetAttributeValue("enable_container_insights",
+		cty.BoolVal(infra.EnableContainerInsights))
+	infraBody.SetAttributeValue("address_prefix",
+		cty.StringVal(infra.AddressPrefix))
+	infraBody.SetAttributeValue("subnet_cidr",
+		cty.NumberIntVal(int64(infra.SubnetCidr)))
+	infraNameRef := hcl.Traversal{
+		hcl.TraverseRoot{
+			Name: "duplocloud_infrastructure.infra",
+		},
+		hcl.TraverseAttr{
+			Name: "infra_name",
+		},
+	}
+
+	// Add duplocloud_infrastructure_setting resource
+	settings := []duplosdk.DuploKeyStringValue{}
+	if infra.CustomData != nil {
+		settings = *infra.CustomData
+	}
+	if len(settings) > 0 {
+		rootBody.AppendNewline()
+		settingBody := rootBody.AppendNewBlock("resource",
+			[]string{"duplocloud_infrastructure_setting",
+				"infra"}).Body()
+		settingBody.SetAttributeTraversal("infra_name", infraNameRef)
+		for _, s := range settings {
+			kvBody := settingBody.AppendNewBlock("setting",
+				nil).Body()
+			kvBody.SetAttributeValue("key",
+				cty.StringVal(s.Key))
+			kvBody.SetAttributeValue("value",
+				cty.StringVal(s.Value))
+		}
+	}
+
+	// Add duplocloud_infrastructure_subnet resources
+	subnets := customSubnets(infra.Name, data.Config)
+	for _, subnet := range subnets {
+		resourceName := common.GetResourceName(subnet.Name)
+		log.Printf("[TRACE] Generating terraform config for duplo infrastructure subnet : %s", subnet.Name)
+		rootBody.AppendNewline()
+		subnetBody := rootBody.AppendNewBlock("resource",
+			[]string{"duplocloud_infrastructure_subnet",
+				resourceName}).Body()
+		subnetBody.SetAttributeValue("name",
+			cty.StringVal(subnet.Name))
+		subnetBody.SetAttributeTraversal("infra_name", infraNameRef)
+		subnetBody.SetAttributeValue("cidr_block",
+			cty.StringVal(subnet.AddressPrefix))
+		subnetBody.SetAttributeValue("type",
+			cty.StringVal(subnet.SubnetType))
+		subnetBody.SetAttributeValue("zone",
+			cty.StringVal(subnet.Zone))
+		tfContext.OutputVars = append(tfContext.OutputVars, common.OutputVarConfig{
+			Name:          SUBNET_VAR_PREFIX + resourceName + "_id",
+			ActualVal:     "duplocloud_infrastructure_subnet." + resourceName + ".subnet_id",
+			DescVal:       "The ID of the " + subnet.Name + " subnet.",
+			RootTraversal: true,
+		})
+	}
+
+	if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	tfContext.OutputVars = append(tfContext.OutputVars, generateInfraOutputVars()...)
+
+	// Import all created resources.
+	if config.ImportsEnabled() {
+		tfContext.ImportConfigs = append(tfContext.ImportConfigs, common.ImportConfig{
+			ResourceAddress: "duplocloud_infrastructure.infra",
+			ResourceId:      "v2/admin/InfrastructureV2/" + infra.Name,
+			WorkingDir:      workingDir,
+		})
+		if len(settings) > 0 {
+			tfContext.ImportConfigs = append(tfContext.ImportConfigs, common.ImportConfig{
+				ResourceAddress: "duplocloud_infrastructure_setting.infra",
+				ResourceId:      infra.Name,
+				WorkingDir:      workingDir,
+			})
+		}
+		for _, subnet := range subnets {
+			tfContext.ImportConfigs = append(tfContext.ImportConfigs, common.ImportConfig{
+				ResourceAddress: "duplocloud_infrastructure_subnet." + common.GetResourceName(subnet.Name),
+				ResourceId:      infra.Name + "/" + subnet.Name + "/" + subnet.AddressPrefix,
+				WorkingDir:      workingDir,
+			})
+		}
+	}
+	log.Println("[TRACE] <====== Infrastructure TF generation done. =====>")
+	return &tfContext, nil
+}
+
+// customSubnets returns the subnets added to an infrastructure, sorted by name.
+// Duplo creates a public and a private subnet per availability zone with the infrastructure, named
+// <infra>-<zone>-public and <infra>-<zone>-private, they are managed by duplocloud_infrastructure.
+func customSubnets(infraName string, infraConfig *duplosdk.DuploInfrastructureConfig) []duplosdk.DuploInfrastructureVnetSubnet {
+	subnets := []duplosdk.DuploInfrastructureVnetSubnet{}
+	if infraConfig == nil || infraConfig.Vnet == nil || infraConfig.Vnet.Subnets == nil {
+		return subnets
+	}
+	for _, subnet := range *infraConfig.Vnet.Subnets {
+		// Interpreted like InfrastructureGetSubnet does.
+		if len(subnet.SubnetType) == 0 {
+			subnet.SubnetType = "private"
+			if strings.Contains(strings.ToLower(subnet.Name), "public") {
+				subnet.SubnetType = "public"
+			}
+		}
+		if strings.EqualFold(subnet.Name, infraName+"-"+subnet.Zone+"-"+subnet.SubnetType) {
+			continue
+		}
+		subnets = append(subnets, subnet)
+	}
+	sort.Slice(subnets, func(i, j int) bool {
+		return subnets[i].Name < subnets[j].Name
+	})
+	return subnets
+}
+
+func generateInfraVars(infra *duplosdk.DuploInfrastructure) []common.VarConfig {
+	return []common.VarConfig{
+		{
+			Name:       "infra_name",
+			DefaultVal: infra.Name,
+			TypeVal:    "string",
+		},
+		{
+			Name:       "region",
+			DefaultVal: infra.Region,
+			TypeVal:    "string",
+		},
+	}
+}
+
+func generateInfraOutputVars() []common.OutputVarConfig {
+	return []common.OutputVarConfig{
+		{
+			Name:          "infra_name",
+			ActualVal:     "duplocloud_infrastructure.infra.infra_name",
+			DescVal:       "The duplo infra name.",
+			RootTraversal: true,
+		},
+		{
+			Name:          "vpc_id",
+			ActualVal:     "duplocloud_infrastructure.infra.vpc_id",
+			DescVal:       "The VPC or VNet ID.",
+			RootTraversal: true,
+		},
+		{
+			Name:          "region",
+			ActualVal:     "var.region",
+			DescVal:       "The duplo plan region.",
+			RootTraversal: true,
+		},
+	}
+}
@@ -0,0 +1,110 @@
+package tfgenerator
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"tenant-terraform-generator/tf-generator/common"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ModulesDir holds the local modules, in this package and next to the generated projects.
+const ModulesDir = "modules"
+
+// moduleSources are the local modules, embedded so that the executable writes them from any working directory.
+//
+//go:embed modules
+var moduleSources embed.FS
+
+// ModuleAttr is an argument of a module block given by a traversal, e.g. tenant_id = local.tenant_id.
+type ModuleAttr struct {
+	Name  string
+	Value hcl.Traversal
+}
+
+// LocalTraversal returns the traversal of local.name.
+func LocalTraversal(name string) hcl.Traversal {
+	return hcl.Traversal{
+		hcl.TraverseRoot{
+			Name: "local",
+		},
+		hcl.TraverseAttr{
+			Name: name,
+		},
+	}
+}
+
+// WriteModule copies the local module source, e.g. duplo-service, next to the projects.
+func WriteModule(config *common.Config, source string) error {
+	dir := path.Join(ModulesDir, source)
+	if _, err := fs.Stat(moduleSources, dir); err != nil {
+		return fmt.Errorf("error writing module %s: it is not embedded in the executable: %s", source, err)
+	}
+	src, err := fs.Sub(moduleSources, dir)
+	if err != nil {
+		return fmt.Errorf("error writing module %s: %s", source, err)
+	}
+	return common.CopyFSToOutput(config.Output, src, path.Join(config.TFCodePath, dir))
+}
+
+// AppendModuleBlock adds a module block named name to rootBody, instantiating the local module source once per
+// entry of the map variable of the same name. The key of an entry is given as the name argument and its value
+// as settings, after the other arguments. It returns the body of the block.
+func AppendModuleBlock(rootBody *hclwrite.Body, name string, source string, attrs []ModuleAttr) *hclwrite.Body {
+	moduleBody := rootBody.AppendNewBlock("module",
+		[]string{name}).Body()
+	moduleBody.SetAttributeValue("source",
+		cty.StringVal(path.Join("..", ModulesDir, source)))
+	moduleBody.SetAttributeTraversal("for_each", hcl.Traversal{
+		hcl.TraverseRoot{
+			Name: "var",
+		},
+		hcl.TraverseAttr{
+			Name: name,
+		},
+	})
+	for _, attr := range attrs {
+		moduleBody.SetAttributeTraversal(attr.Name, attr.Value)
+	}
+	moduleBody.SetAttributeTraversal("name", hcl.Traversal{
+		hcl.TraverseRoot{
+			Name: "each",
+		},
+		hcl.TraverseAttr{
+			Name: "key",
+		},
+	})
+	moduleBody.SetAttributeTraversal("settings", hcl.Traversal{
+		hcl.TraverseRoot{
+			Name: "each",
+		},
+		hcl.TraverseAttr{
+			Name: "value",
+		},
+	})
+	return moduleBody
+}
+
+// ModuleAddress returns the address of the instance of module name for the entry key, e.g. module.s3_buckets["assets"].
+func ModuleAddress(name, key string) string {
+	return "module." + name + "[" + string(hclwrite.TokensForValue(cty.StringVal(key)).Bytes()) + "]"
+}
+
+// MapVar returns the variable of the map whose entries, by key, are the settings of the objects of a module.
+// Every entry has the type entryType.
+func MapVar(name string, entryType cty.Type, entries map[string]cty.Value, desc string) common.VarConfig {
+	value := cty.MapValEmpty(entryType)
+	if len(entries) > 0 {
+		value = cty.MapVal(entries)
+	}
+	return common.VarConfig{
+		Name:    name,
+		TypeVal: common.TypeString(cty.Map(entryType)),
+		Value:   &value,
+		DescVal: desc,
+	}
+}
@@ -0,0 +1,11 @@
+resource "duplocloud_s3_bucket" "this" {
+  tenant_id           = var.tenant_id
+  name                = var.name
+  allow_public_access = var.settings.allow_public_access
+  enable_access_logs  = var.settings.enable_access_logs
+  enable_versioning   = var.settings.enable_versioning
+  managed_policies    = var.settings.managed_policies
+  default_encryption {
+    method = var.settings.default_encryption
+  }
+}
@@ -0,0 +1,9 @@
+output "fullname" {
+  value       = duplocloud_s3_bucket.this.fullname
+  description = "The full name of the bucket, with the prefix of the tenant."
+}
+
+output "arn" {
+  value       = duplocloud_s3_bucket.this.arn
+  description = "The ARN of the bucket."
+}
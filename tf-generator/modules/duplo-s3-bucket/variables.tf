@@ -0,0 +1,18 @@
+variable "tenant_id" {
+  type = string
+}
+
+variable "name" {
+  type = string
+}
+
+variable "settings" {
+  description = "Settings of the bucket, an entry of the s3_buckets variable of the project."
+  type = object({
+    allow_public_access = bool
+    enable_access_logs  = bool
+    enable_versioning   = bool
+    managed_policies    = list(string)
+    default_encryption  = string
+  })
+}
@@ -0,0 +1,49 @@
+resource "duplocloud_duplo_service" "this" {
+  tenant_id                            = var.tenant_id
+  name                                 = var.name
+  replicas                             = var.settings.replicas
+  lb_synced_deployment                 = var.settings.lb_synced_deployment
+  cloud_creds_from_k8s_service_account = var.settings.cloud_creds_from_k8s_service_account
+  is_daemonset                         = var.settings.is_daemonset
+  replicas_matching_asg_name           = var.settings.replicas_matching_asg_name
+  agent_platform                       = var.settings.agent_platform
+  cloud                                = var.settings.cloud
+  allocation_tags                      = var.settings.allocation_tags
+  docker_image                         = var.settings.docker_image
+  commands                             = var.settings.commands
+  other_docker_config                  = try(var.documents.other_docker_config, null)
+  extra_config                         = try(var.documents.extra_config, null)
+  other_docker_host_config             = try(var.documents.other_docker_host_config, null)
+  hpa_specs                            = try(var.documents.hpa_specs, null)
+  volumes                              = try(var.documents.volumes, null)
+}
+
+resource "duplocloud_duplo_service_lbconfigs" "this" {
+  count                       = length(var.settings.lbconfigs) > 0 ? 1 : 0
+  tenant_id                   = duplocloud_duplo_service.this.tenant_id
+  replication_controller_name = duplocloud_duplo_service.this.name
+  dynamic "lbconfigs" {
+    for_each = var.settings.lbconfigs
+    content {
+      lb_type          = lbconfigs.value.lb_type
+      is_native        = lbconfigs.value.is_native
+      is_internal      = lbconfigs.value.is_internal
+      port             = lbconfigs.value.port
+      external_port    = lbconfigs.value.external_port
+      protocol         = lbconfigs.value.protocol
+      health_check_url = lbconfigs.value.health_check_url
+      certificate_arn  = lbconfigs.value.certificate ? var.cert_arn : null
+    }
+  }
+}
+
+resource "duplocloud_duplo_service_params" "this" {
+  count                       = var.settings.params == null ? 0 : 1
+  tenant_id                   = duplocloud_duplo_service_lbconfigs.this[0].tenant_id
+  replication_controller_name = duplocloud_duplo_service_lbconfigs.this[0].replication_controller_name
+  dns_prfx                    = var.settings.params.dns_prfx == null ? null : "${var.settings.params.dns_prfx}-${var.tenant_name}"
+  webaclid                    = var.settings.params.webaclid
+  enable_access_logs          = var.settings.params.enable_access_logs
+  drop_invalid_headers        = var.settings.params.drop_invalid_headers
+  http_to_https_redirect      = var.settings.params.http_to_https_redirect
+}
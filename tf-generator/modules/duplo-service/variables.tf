@@ -0,0 +1,62 @@
+variable "tenant_id" {
+  type = string
+}
+
+variable "tenant_name" {
+  description = "Name of the tenant, appended to the DNS prefix of the load balancer."
+  type        = string
+}
+
+variable "cert_arn" {
+  description = "Certificate of the load balancer listeners with certificate set."
+  type        = string
+}
+
+variable "name" {
+  type = string
+}
+
+variable "settings" {
+  description = "Settings of the service, an entry of the duplo_services variable of the project. lbconfigs is empty and params null without load balancer."
+  type = object({
+    replicas                             = number
+    lb_synced_deployment                 = bool
+    cloud_creds_from_k8s_service_account = bool
+    is_daemonset                         = bool
+    replicas_matching_asg_name           = string
+    agent_platform                       = number
+    cloud                                = number
+    allocation_tags                      = string
+    docker_image                         = string
+    commands                             = list(string)
+    lbconfigs = list(object({
+      lb_type          = number
+      is_native        = bool
+      is_internal      = bool
+      port             = number
+      external_port    = number
+      protocol         = string
+      health_check_url = string
+      certificate      = bool
+    }))
+    params = object({
+      dns_prfx               = string
+      webaclid               = string
+      enable_access_logs     = bool
+      drop_invalid_headers   = bool
+      http_to_https_redirect = bool
+    })
+  })
+}
+
+variable "documents" {
+  description = "JSON documents of the service, given by the module block of the project as they refer to other resources. null without any."
+  type = object({
+    other_docker_config      = string
+    extra_config             = string
+    other_docker_host_config = string
+    hpa_specs                = string
+    volumes                  = string
+  })
+  default = null
+}
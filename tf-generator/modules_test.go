@@ -0,0 +1,52 @@
+package tfgenerator
+
+import (
+	"os"
+	"strings"
+	"tenant-terraform-generator/tf-generator/common"
+	"testing"
+)
+
+func TestWriteModule(t *testing.T) {
+	// The modules are written from the executable, whatever the working directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	tests := []struct {
+		source string
+		// files are the files expected below terraform/modules, wantErr is part of the expected error.
+		files   []string
+		wantErr string
+	}{
+		{source: "duplo-service", files: []string{"duplo-service/main.tf", "duplo-service/variables.tf", "duplo-service/versions.tf"}},
+		{source: "duplo-s3-bucket", files: []string{"duplo-s3-bucket/main.tf", "duplo-s3-bucket/outputs.tf"}},
+		{source: "duplo-missing", wantErr: "error writing module duplo-missing: it is not embedded in the executable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			out := common.NewMemoryOutput()
+			config := &common.Config{Output: out, TFCodePath: "terraform"}
+			err := WriteModule(config, tt.source)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.files {
+				if f, ok := out.File("terraform/modules/" + name); !ok || len(f.Data) == 0 {
+					t.Errorf("%s is not written", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package tfgenerator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"tenant-terraform-generator/tf-generator/common"
+)
+
+// Terraform projects a generator can contribute to.
+const (
+	// ProjectInfra is the project of the infrastructure (plan) of the tenant, only generated when enabled.
+	ProjectInfra       = "infra"
+	ProjectTenant      = "tenant"
+	ProjectAwsServices = "aws-services"
+	ProjectApp         = "app"
+)
+
+// Projects lists the terraform projects in the order they are generated.
+var Projects = []string{ProjectInfra, ProjectTenant, ProjectAwsServices, ProjectApp}
+
+// Registration describes a generator registered with Register.
+type Registration struct {
+	// Name uniquely identifies the generator, it is used in DependsOn.
+	Name string
+	// Project is the terraform project the generator writes to.
+	Project string
+	// ResourceTypes lists the terraform resource and data source types the generator produces.
+	ResourceTypes []string
+	// DuploAPIs lists the duplosdk client methods the generator calls.
+	DuploAPIs []string
+	// DependsOn lists the generators of the same project whose resources this generator references.
+	DependsOn []string
+	// Enabled reports whether the generator runs for a config, nil means it always runs.
+	Enabled func(config *common.Config) bool
+	// New creates the generator.
+	New func() Generator
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]Registration{}
+)
+
+// Register adds a generator to the registry, it is meant to be called from the init function of the generator's file.
+func Register(r Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if len(r.Name) == 0 || r.New == nil {
+		panic("tfgenerator: generator registration needs a name and a constructor")
+	}
+	if !isProject(r.Project) {
+		panic(fmt.Sprintf("tfgenerator: generator %s has unknown project %q", r.Name, r.Project))
+	}
+	if _, ok := registry[r.Name]; ok {
+		panic(fmt.Sprintf("tfgenerator: generator %s is registered twice", r.Name))
+	}
+	registry[r.Name] = r
+}
+
+func isProject(project string) bool {
+	for _, p := range Projects {
+		if p == project {
+			return true
+		}
+	}
+	return false
+}
+
+// Registrations returns every registered generator, ordered by project and by dependencies within a project.
+func Registrations() ([]Registration, error) {
+	all := []Registration{}
+	for _, project := range Projects {
+		regs, err := projectRegistrations(project)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, regs...)
+	}
+	return all, nil
+}
+
+// EnabledRegistrations returns the generators enabled for a project, ordered so that every generator comes after its dependencies.
+func EnabledRegistrations(project string, config *common.Config) ([]Registration, error) {
+	regs, err := projectRegistrations(project)
+	if err != nil {
+		return nil, err
+	}
+	enabled := []Registration{}
+	for _, r := range regs {
+		if r.Enabled == nil || r.Enabled(config) {
+			enabled = append(enabled, r)
+		}
+	}
+	return enabled, nil
+}
+
+// projectRegistrations sorts the registrations of a project topologically, ties are broken by name to keep the order stable.
+func projectRegistrations(project string) ([]Registration, error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	pending := map[string]Registration{}
+	for name, r := range registry {
+		if r.Project == project {
+			pending[name] = r
+		}
+	}
+	for _, r := range pending {
+		for _, dep := range r.DependsOn {
+			if _, ok := pending[dep]; !ok {
+				return nil, fmt.Errorf("generator %s depends on %s which is not registered for project %s", r.Name, dep, project)
+			}
+		}
+	}
+
+	ordered := []Registration{}
+	done := map[string]bool{}
+	for len(pending) > 0 {
+		ready := []string{}
+		for name, r := range pending {
+			if dependenciesDone(r, done) {
+				ready = append(ready, name)
+			}
+		}
+		if len(ready) == 0 {
+			names := []string{}
+			for name := range pending {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("dependency cycle between generators %s", strings.Join(names, ", "))
+		}
+		sort.Strings(ready)
+		for _, name := range ready {
+			ordered = append(ordered, pending[name])
+			done[name] = true
+			delete(pending, name)
+		}
+	}
+	return ordered, nil
+}
+
+func dependenciesDone(r Registration, done map[string]bool) bool {
+	for _, dep := range r.DependsOn {
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
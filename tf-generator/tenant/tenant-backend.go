@@ -1,60 +1,32 @@
 package tenant
 
 import (
-	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"context"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
-
-	"github.com/hashicorp/hcl/v2/hclwrite"
-	"github.com/zclconf/go-cty/cty"
 )
 
 type TenantBackend struct {
 }
 
-func (tb *TenantBackend) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	log.Println("[TRACE] <====== Tenant backend TF generation started. =====>")
-	// create new empty hcl file object
-	hclFile := hclwrite.NewEmptyFile()
-
-	// create new file on system
-	path := filepath.Join(config.TFCodePath, config.TenantProject, "backend.tf")
-	tfFile, err := os.Create(path)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
-	}
-	// initialize the body of the new file object
-	rootBody := hclFile.Body()
-
-	// Add duplo terraform block
-	tfBlock := rootBody.AppendNewBlock("terraform",
-		nil)
-	tfBlockBody := tfBlock.Body()
-	s3Backend := tfBlockBody.AppendNewBlock("backend",
-		[]string{"s3"})
-
-	s3BackendBody := s3Backend.Body()
-
-	s3BackendBody.SetAttributeValue("region",
-		cty.StringVal("us-west-2")) // TODO - Take region from ENV VAR
-	s3BackendBody.SetAttributeValue("key",
-		cty.StringVal("tenant"))
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:    "tenant-backend",
+		Project: tfgenerator.ProjectTenant,
+		Enabled: tfgenerator.BackendEnabled,
+		New:     func() tfgenerator.Generator { return &TenantBackend{} },
+	})
+}
 
-	s3BackendBody.SetAttributeValue("workspace_key_prefix",
-		cty.StringVal("admin:"))
-	s3BackendBody.SetAttributeValue("encrypt",
-		cty.True)
+// Fetch does nothing, the backend only depends on the config.
+func (tb *TenantBackend) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
+	return nil
+}
 
-	fmt.Printf("%s", hclFile.Bytes())
-	_, err = tfFile.Write(hclFile.Bytes())
-	if err != nil {
-		fmt.Println(err)
+func (tb *TenantBackend) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	if err := tfgenerator.RenderBackend(config, config.TenantProject); err != nil {
 		return nil, err
 	}
-	log.Println("[TRACE] <====== Tenant backend TF generation done. =====>")
 	return nil, nil
 }
@@ -1,12 +1,12 @@
 package tenant
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"strconv"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -17,14 +17,32 @@ import (
 type TenantSGRule struct {
 }
 
-func (tsgrule *TenantSGRule) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.TenantProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "tenant-sg-rule",
+		Project:       tfgenerator.ProjectTenant,
+		ResourceTypes: []string{"duplocloud_tenant_network_security_rule"},
+		DuploAPIs:     []string{"TenantGetExtConnSecurityGroupRules"},
+		DependsOn:     []string{"tenant"},
+		New:           func() tfgenerator.Generator { return &TenantSGRule{} },
+	})
+}
 
+func (tsgrule *TenantSGRule) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	list, clientErr := client.TenantGetExtConnSecurityGroupRules(config.TenantId)
 	//Get tenant from duplo
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
+	}
+	return fetched.Set(list)
+}
+
+func (tsgrule *TenantSGRule) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.TenantProject)
+
+	list := &[]duplosdk.DuploTenantExtConnSecurityGroupRule{}
+	if err := fetched.Decode(list); err != nil {
+		return nil, err
 	}
 	tfContext := common.TFContext{}
 	if len(*list) > 0 {
@@ -34,11 +52,6 @@ func (tsgrule *TenantSGRule) Generate(config *common.Config, client *duplosdk.Cl
 
 		// create new file on system
 		path := filepath.Join(workingDir, "tenant-sg-rules.tf")
-		tfFile, err := os.Create(path)
-		if err != nil {
-			fmt.Println(err)
-			return nil, err
-		}
 
 		// initialize the body of the new file object
 		var rootBody *hclwrite.Body
@@ -84,7 +97,7 @@ func (tsgrule *TenantSGRule) Generate(config *common.Config, client *duplosdk.Cl
 					cty.StringVal(source.Description))
 				rootBody.AppendNewline()
 
-				if config.GenerateTfState {
+				if config.ImportsEnabled() {
 					importConfigs = append(importConfigs, common.ImportConfig{
 						ResourceAddress: "duplocloud_tenant_network_security_rule.tenant-sg-rule" + strconv.Itoa(counter),
 						ResourceId:      config.TenantId + "/" + strconv.Itoa(sgRule.Type) + "/" + sourceType + "/" + sgRule.Protocol + "/" + strconv.Itoa(sgRule.FromPort) + "/" + strconv.Itoa(sgRule.ToPort),
@@ -95,9 +108,7 @@ func (tsgrule *TenantSGRule) Generate(config *common.Config, client *duplosdk.Cl
 		}
 		tfContext.ImportConfigs = importConfigs
 		if rootBodyCreated {
-			_, err = tfFile.Write(hclFile.Bytes())
-			if err != nil {
-				fmt.Println(err)
+			if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 				return nil, err
 			}
 		}
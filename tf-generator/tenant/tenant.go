@@ -1,11 +1,11 @@
 package tenant
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"tenant-terraform-generator/duplosdk"
+	tfgenerator "tenant-terraform-generator/tf-generator"
 	"tenant-terraform-generator/tf-generator/common"
 
 	"github.com/hashicorp/hcl/v2"
@@ -16,21 +16,44 @@ import (
 type Tenant struct {
 }
 
-func (t *Tenant) Generate(config *common.Config, client *duplosdk.Client) (*common.TFContext, error) {
-	workingDir := filepath.Join(config.TFCodePath, config.TenantProject)
+func init() {
+	tfgenerator.Register(tfgenerator.Registration{
+		Name:          "tenant",
+		Project:       tfgenerator.ProjectTenant,
+		ResourceTypes: []string{"duplocloud_tenant", "duplocloud_tenant_config", "duplocloud_infrastructure"},
+		DuploAPIs:     []string{"TenantGet", "InfrastructureGetConfig"},
+		New:           func() tfgenerator.Generator { return &Tenant{} },
+	})
+}
 
-	log.Println("[TRACE] <====== Tenant TF generation started. =====>")
+// tenantSnapshot holds the duplo objects rendered by the tenant generator.
+type tenantSnapshot struct {
+	Tenant      *duplosdk.DuploTenant
+	InfraConfig *duplosdk.DuploInfrastructureConfig
+}
+
+func (t *Tenant) Fetch(ctx context.Context, config *common.Config, client *duplosdk.Client, fetched *common.Fetched) error {
 	duplo, clientErr := client.TenantGet(config.TenantId)
 	//Get tenant from duplo
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
 	}
 	infraConfig, clientErr := client.InfrastructureGetConfig(duplo.PlanID)
 	if clientErr != nil {
-		fmt.Println(clientErr)
-		return nil, clientErr
+		return clientErr
 	}
+	return fetched.Set(tenantSnapshot{Tenant: duplo, InfraConfig: infraConfig})
+}
+
+func (t *Tenant) Render(config *common.Config, fetched *common.Fetched) (*common.TFContext, error) {
+	workingDir := filepath.Join(config.TFCodePath, config.TenantProject)
+
+	log.Println("[TRACE] <====== Tenant TF generation started. =====>")
+	data := tenantSnapshot{}
+	if err := fetched.Decode(&data); err != nil {
+		return nil, err
+	}
+	duplo, infraConfig := data.Tenant, data.InfraConfig
 	tfContext := common.TFContext{}
 	//1. ==========================================================================================
 	// Generate variables
@@ -42,18 +65,11 @@ func (t *Tenant) Generate(config *common.Config, client *duplosdk.Client) (*comm
 	//2. ==========================================================================================
 	// Generate resoueces
 	log.Printf("[TRACE] Tenant Name : %s", duplo.AccountName)
-	// This is needed for all other resources.
-	config.TenantName = duplo.AccountName
 	// create new empty hcl file object
 	hclFile := hclwrite.NewEmptyFile()
 
 	// create new file on system
 	path := filepath.Join(workingDir, "main.tf")
-	tfFile, err := os.Create(path)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
-	}
 
 	// initialize the body of the new file object
 	rootBody := hclFile.Body()
@@ -161,10 +177,7 @@ func (t *Tenant) Generate(config *common.Config, client *duplosdk.Client) (*comm
 		cty.StringVal("true"))
 	rootBody.AppendNewline()
 
-	fmt.Printf("%s", hclFile.Bytes())
-	_, err = tfFile.Write(hclFile.Bytes())
-	if err != nil {
-		fmt.Println(err)
+	if err := config.Output.WriteFile(path, hclFile.Bytes(), 0644); err != nil {
 		return nil, err
 	}
 	log.Println("[TRACE] <====== Tenant TF generation done. =====>")
@@ -178,7 +191,7 @@ func (t *Tenant) Generate(config *common.Config, client *duplosdk.Client) (*comm
 
 	// 4. ==========================================================================================
 	// Import all created resources.
-	if config.GenerateTfState {
+	if config.ImportsEnabled() {
 		importConfigs := []common.ImportConfig{}
 		importConfigs = append(importConfigs, common.ImportConfig{
 			ResourceAddress: "duplocloud_tenant.tenant",